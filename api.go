@@ -7,12 +7,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/jpillora/backoff"
 )
 
 var defaultConfig = DefaultConfig()
@@ -23,10 +23,63 @@ type API struct {
 	Token       string // API client token
 	Secret      string // API client secret for this application
 
-	callLimit  int
-	callsMade  int
-	backoff    *backoff.Backoff
-	retryCount int
+	// Version is the Admin API version used for GraphQL requests, e.g.
+	// "2024-01". If empty, the unversioned endpoint is used.
+	Version string
+
+	// BaseURL overrides the https://<Shop> prefix of every request, e.g. to
+	// point the client at a local test server.
+	BaseURL string
+
+	// Client is used to send requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// RetryPolicy decides which failed requests are sent again. If nil,
+	// DefaultRetryPolicy() is used; set it to NoRetry to disable retries.
+	RetryPolicy RetryPolicy
+
+	// IdempotencyKey, if set, is called for every POST request. A non-empty
+	// result is sent in the Idempotency-Key header, which allows the request
+	// to be retried after server and network errors.
+	IdempotencyKey func(method, endpoint string, body []byte) string
+
+	// Cache, if set, stores the responses to GET requests of the resources
+	// listed in CacheTTLs, e.g. DefaultCacheTTLs(), for their TTL. Expired
+	// entries are revalidated with conditional requests, and every entry of
+	// a resource is invalidated by a POST, PUT or DELETE request to it.
+	Cache     Cache
+	CacheTTLs map[string]time.Duration
+
+	// Logger, if set, logs every attempt at a request, as configured by
+	// LogOptions.
+	Logger     *slog.Logger
+	LogOptions LogOptions
+
+	hooks []Hook
+
+	mu        sync.Mutex // guards callLimit and callsMade
+	callLimit int
+	callsMade int
+}
+
+const redactedSecret = "[REDACTED]"
+
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redactedSecret
+}
+
+// String implements fmt.Stringer, hiding the API credentials.
+func (api *API) String() string {
+	return fmt.Sprintf("API{Shop: %s, AccessToken: %s, Token: %s, Secret: %s}",
+		api.Shop, redactSecret(api.AccessToken), redactSecret(api.Token), redactSecret(api.Secret))
+}
+
+// GoString implements fmt.GoStringer, hiding the API credentials.
+func (api *API) GoString() string {
+	return "&shopify." + api.String()
 }
 
 // ErrorResponse is returned when an unexpected HTTP status code is received.
@@ -45,25 +98,39 @@ type ErrorResponse struct {
 	BodyErr error `json:"-"`
 }
 
+// newErrorResponse returns the error for an unexpected status code: a
+// *ValidationError for 422 responses, and an *ErrorResponse otherwise.
 func newErrorResponse(status int, reqBody []byte, body *bytes.Buffer) error {
+	r := decodeErrorResponse(status, reqBody, body)
+	if status == http.StatusUnprocessableEntity {
+		return &ValidationError{Fields: parseValidationErrors(r.Errors), Response: r}
+	}
+	return r
+}
+
+func decodeErrorResponse(status int, reqBody []byte, body *bytes.Buffer) *ErrorResponse {
 	var r ErrorResponse
 	r.StatusCode = status
 	r.ReqBody = reqBody
-	r.Body = body.Bytes()
-	r.BodyErr = json.NewDecoder(body).Decode(&r)
+	if body != nil {
+		r.Body = body.Bytes()
+		r.BodyErr = json.NewDecoder(body).Decode(&r)
+	}
 	return &r
 }
 
+// Error implements error. Secrets and customer data in the bodies are masked
+// by DefaultRedactor, and the bodies are truncated to MaxErrorBodyLength.
 func (e *ErrorResponse) Error() string {
-	ret := fmt.Sprintf("status %d: %v", e.StatusCode, e.Errors)
+	ret := fmt.Sprintf("status %d: %s", e.StatusCode, DefaultRedactor.RedactString(fmt.Sprint(e.Errors)))
 	if len(e.ReqBody) > 0 {
-		ret += "; request body: " + string(e.ReqBody)
+		ret += "; request body: " + truncate(DefaultRedactor.Redact(e.ReqBody), MaxErrorBodyLength)
 	}
 	if e.BodyErr != nil {
 		ret += "; error parsing body: " + e.BodyErr.Error()
 	}
 	if len(e.Body) > 0 {
-		ret += "; response body: " + string(e.Body)
+		ret += "; response body: " + truncate(DefaultRedactor.Redact(e.Body), MaxErrorBodyLength)
 	}
 	return ret
 }
@@ -74,29 +141,136 @@ func (e *ErrorResponse) Temporary() bool {
 	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
 }
 
-func (api *API) request(endpoint string, method string, params map[string]interface{}, body *bytes.Buffer) (result *bytes.Buffer, status int, err error) {
-	if api.backoff == nil {
-		api.backoff = &backoff.Backoff{
-			Min:    defaultConfig.MinBackoffValue,
-			Max:    defaultConfig.MaxBackoffValue,
-			Jitter: true,
+func (api *API) request(endpoint string, method string, params map[string]interface{}, body *bytes.Buffer) (*bytes.Buffer, int, error) {
+	result, _, status, err := api.requestWithHeader(endpoint, method, params, body)
+	return result, status, err
+}
+
+// requestWithHeader is request, also returning the headers of the response,
+// e.g. the Link header of paginated lists.
+func (api *API) requestWithHeader(endpoint string, method string, params map[string]interface{}, body *bytes.Buffer) (result *bytes.Buffer, header http.Header, status int, err error) {
+	// Keep a copy of body so that it can be sent again when retrying.
+	var reqBody []byte
+	if body != nil {
+		reqBody = body.Bytes()
+	}
+
+	uri := fmt.Sprintf("https://%s%s", api.Shop, endpoint)
+	if api.BaseURL != "" {
+		uri = strings.TrimSuffix(api.BaseURL, "/") + endpoint
+	}
+
+	policy := api.RetryPolicy
+	if policy == nil {
+		policy = DefaultRetryPolicy()
+	}
+
+	reqHeader := http.Header{}
+	if api.IdempotencyKey != nil && method == "POST" {
+		if key := api.IdempotencyKey(method, endpoint, reqBody); key != "" {
+			reqHeader.Set(IdempotencyKeyHeader, key)
 		}
 	}
-	if api.callLimit == 0 {
-		api.callLimit = defaultConfig.BucketLimit
+
+	resource := resourceFromEndpoint(endpoint)
+	if api.Cache != nil && method != "GET" {
+		defer api.invalidateCache(resource)
+	}
+	cacheTTL := api.cacheTTL(method, resource)
+	var cached *CacheEntry
+	if cacheTTL > 0 {
+		var revalidate http.Header
+		cached, revalidate = api.cachedResponse(resource, endpoint)
+		if cached != nil && cached.Fresh() {
+			return bytes.NewBuffer(cached.Body), cached.Header, http.StatusOK, nil
+		}
+		for name, values := range revalidate {
+			reqHeader[name] = values
+		}
 	}
 
-	// Keep a copy of body so that we can use it when retrying.
-	var bodyBackup *bytes.Buffer
-	if body != nil {
-		bodyBackup = new(bytes.Buffer)
-		*bodyBackup = *body
+	var first, event *RequestEvent
+	if len(api.hooks) > 0 {
+		defer func() {
+			if event != nil {
+				api.runAfterRequest(event, err)
+			}
+		}()
 	}
 
-	uri := fmt.Sprintf("https://%s%s", api.Shop, endpoint)
-	req, err := http.NewRequest(method, uri, body)
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		event = &RequestEvent{
+			Shop:     api.Shop,
+			Method:   method,
+			Endpoint: endpoint,
+			Resource: resource,
+			Body:     reqBody,
+			Attempt:  attempt,
+			First:    first,
+		}
+		if first == nil {
+			first = event
+			event.First = event
+		}
+		var sent bool
+		sent, err = api.send(event, uri, reqHeader)
+		if !sent {
+			return
+		}
+
+		if err == nil && event.StatusCode < 400 {
+			if cacheTTL > 0 {
+				body, code := api.storeResponse(resource, endpoint, cacheTTL, cached, event)
+				header = event.ResponseHeader
+				if code != event.StatusCode {
+					header = cached.Header
+				}
+				return bytes.NewBuffer(body), header, code, nil
+			}
+			return bytes.NewBuffer(event.ResponseBody), event.ResponseHeader, event.StatusCode, nil
+		}
+
+		retry := RetryAttempt{
+			Method:     method,
+			Endpoint:   endpoint,
+			Attempt:    attempt,
+			Elapsed:    time.Since(start),
+			StatusCode: event.StatusCode,
+			Header:     event.ResponseHeader,
+			Err:        err,
+			Idempotent: idempotent(event.Request),
+		}
+
+		wait, ok := policy.Retry(retry)
+		if !ok {
+			if err != nil {
+				return
+			}
+			result = bytes.NewBuffer(event.ResponseBody)
+			header = event.ResponseHeader
+			status = event.StatusCode
+			if status == http.StatusTooManyRequests {
+				err = newRateLimitError(event.ResponseHeader, reqBody, result)
+			}
+			return
+		}
+		time.Sleep(wait)
+	}
+}
+
+// send makes a single attempt at a request, filling in event and running
+// the hooks. sent is false if the request couldn't be built or was aborted
+// by a hook.
+func (api *API) send(event *RequestEvent, uri string, header http.Header) (sent bool, err error) {
+	// avoid passing a typed nil *bytes.Reader as the io.Reader
+	var reqBody io.Reader
+	if event.Body != nil {
+		reqBody = bytes.NewReader(event.Body)
+	}
+	req, err := http.NewRequest(event.Method, uri, reqBody)
 	if err != nil {
-		return
+		return false, err
 	}
 
 	if api.Secret == "" {
@@ -107,34 +281,57 @@ func (api *API) request(endpoint string, method string, params map[string]interf
 		req.SetBasicAuth(api.Token, hexSum)
 	}
 	req.Header.Add("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = append([]string{}, values...)
+	}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return
-	}
-	defer resp.Body.Close()
-
-	calls, total := parseAPICallLimit(resp.Header.Get("HTTP_X_SHOPIFY_SHOP_API_CALL_LIMIT"))
-	api.callsMade = calls
-	api.callLimit = total
-
-	status = resp.StatusCode
-	if status == 429 { // statusTooManyRequests
-		if api.retryCount < defaultConfig.MaxRetries {
-			api.retryCount = api.retryCount + 1
-			b := api.backoff.Duration()
-			time.Sleep(b)
-			// try again
-			return api.request(endpoint, method, params, bodyBackup)
+	event.Request = req
+	for _, hook := range api.hooks {
+		if hook.BeforeRequest != nil {
+			if err = hook.BeforeRequest(event); err != nil {
+				api.runHooks(event, err)
+				api.logEvent(event, err)
+				return false, err
+			}
 		}
-		// else just return
 	}
 
-	result = &bytes.Buffer{}
-	if _, err = io.Copy(result, resp.Body); err != nil {
-		return
+	client := api.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	start := time.Now()
+	resp, err := client.Do(event.Request)
+	if err == nil {
+		event.StatusCode = resp.StatusCode
+		event.ResponseHeader = resp.Header
+		event.ResponseBody, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if calls, total := parseAPICallLimit(resp.Header.Get("X-Shopify-Shop-Api-Call-Limit")); total > 0 {
+			api.mu.Lock()
+			api.callsMade = calls
+			api.callLimit = total
+			api.mu.Unlock()
+		}
+	}
+	event.Duration = time.Since(start)
+	event.CallsMade, event.CallLimit = api.CallLimit()
+
+	api.runHooks(event, err)
+	api.logEvent(event, err)
+	return true, err
+}
+
+// CallLimit returns the state of the REST call-limit bucket reported by the
+// last response: the calls made and the size of the bucket.
+func (api *API) CallLimit() (made int, limit int) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.callLimit == 0 {
+		return api.callsMade, defaultConfig.BucketLimit
 	}
-	return
+	return api.callsMade, api.callLimit
 }
 
 func parseAPICallLimit(str string) (int, int) {
@@ -123,9 +123,9 @@ func TestListCreateGetDeleteProduct(t *testing.T) {
 
 	// create
 	newProduct := api.NewProduct()
-	newProduct.Title = "T-shirt"
-	newProduct.PublishedAt = time.Now().String()
-	newProduct.ProductType = "shirts"
+	newProduct.Title = stringPtr("T-shirt")
+	newProduct.PublishedAt = stringPtr(time.Now().String())
+	newProduct.ProductType = stringPtr("shirts")
 	err = newProduct.Save(nil)
 	if err != nil {
 		t.Fatalf("Error saving product: %s", err)
@@ -187,12 +187,16 @@ func TestNewProduct(t *testing.T) {
 	}
 
 	product := api.NewProduct()
-	product.Title = "T-shirt"
-	product.PublishedAt = time.Now().String()
-	product.ProductType = "shirts"
+	product.Title = stringPtr("T-shirt")
+	product.PublishedAt = stringPtr(time.Now().String())
+	product.ProductType = stringPtr("shirts")
 	err := product.Save(nil)
 	if err != nil {
 		t.Errorf("Error saving product: %s", err)
 	}
 	fmt.Printf("New product ID is: %d\n", product.ID)
 }
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -2,27 +2,109 @@ package shopify
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"sort"
 	"strings"
+	"time"
 )
 
 type App struct {
-	APIKey          string
-	APISecret       string
-	RedirectURI     string
+	APIKey      string
+	APISecret   string
+	RedirectURI string
+
+	// IgnoreSignature skips the HMAC checks of OAuth, app proxy and webhook
+	// requests, for testing. Session tokens are always verified.
 	IgnoreSignature bool
+
+	// Transport is used for OAuth requests to Shopify. If nil, a new
+	// http.Transport is used.
+	Transport http.RoundTripper
+
+	// Logger, if set, logs OAuth requests and rejected session tokens and
+	// webhooks, as configured by LogOptions. API clients returned by App
+	// use the same logger.
+	Logger     *slog.Logger
+	LogOptions LogOptions
+}
+
+// AccessMode selects the kind of access token requested during the OAuth flow.
+type AccessMode string
+
+const (
+	// OfflineAccess requests a permanent, shop-wide access token.
+	OfflineAccess AccessMode = ""
+	// OnlineAccess requests a per-user access token that expires with the
+	// staff member's session and is limited to their permissions.
+	OnlineAccess AccessMode = "per-user"
+)
+
+// AssociatedUser is the staff member an online access token was issued for.
+type AssociatedUser struct {
+	ID            int64  `json:"id"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	AccountOwner  bool   `json:"account_owner"`
+	Locale        string `json:"locale"`
+	Collaborator  bool   `json:"collaborator"`
+}
+
+// AccessTokenResponse is the result of exchanging an authorization code for
+// an access token. ExpiresIn, AssociatedUserScope and AssociatedUser are only
+// set for online access tokens.
+type AccessTokenResponse struct {
+	AccessToken         string          `json:"access_token"`
+	Scope               string          `json:"scope"`
+	ExpiresIn           int64           `json:"expires_in,omitempty"`
+	AssociatedUserScope string          `json:"associated_user_scope,omitempty"`
+	AssociatedUser      *AssociatedUser `json:"associated_user,omitempty"`
+
+	// ExpiresAt is computed from ExpiresIn when the token is received.
+	// It is zero for offline tokens.
+	ExpiresAt time.Time `json:"-"`
+}
+
+// Online returns true if the token is a per-user online access token.
+func (t *AccessTokenResponse) Online() bool {
+	return t.AssociatedUser != nil || t.ExpiresIn > 0
+}
+
+// Expired returns true if an online access token has expired and the user
+// needs to go through the authorization flow again. Offline tokens never expire.
+func (t *AccessTokenResponse) Expired() bool {
+	return t.ExpiresWithin(0)
+}
+
+// ExpiresWithin returns true if the token expires within d, so that callers can
+// re-authorize before a request fails.
+func (t *AccessTokenResponse) ExpiresWithin(d time.Duration) bool {
+	if t.ExpiresAt.IsZero() {
+		return false
+	}
+	return !time.Now().Add(d).Before(t.ExpiresAt)
 }
 
 func (s *App) AuthorizeURL(shop string, scopes string) string {
+	return s.AuthorizeURLWithMode(shop, scopes, OfflineAccess)
+}
+
+// AuthorizeURLWithMode returns the OAuth authorize URL for shop, requesting
+// either an offline or an online (per-user) access token.
+func (s *App) AuthorizeURLWithMode(shop string, scopes string, mode AccessMode) string {
 	var u url.URL
 	u.Scheme = "https"
 	u.Host = shop
@@ -31,6 +113,9 @@ func (s *App) AuthorizeURL(shop string, scopes string) string {
 	q.Set("client_id", s.APIKey)
 	q.Set("scope", scopes)
 	q.Set("redirect_uri", s.RedirectURI)
+	if mode != OfflineAccess {
+		q.Set("grant_options[]", string(mode))
+	}
 	u.RawQuery = q.Encode()
 
 	return u.String()
@@ -59,6 +144,25 @@ func (s *App) VerifyHMACSignature(u *url.URL) bool {
 	return VerifyHMAC(hmac, message, s.APISecret)
 }
 
+// ErrInvalidWebhookSignature is returned by VerifyWebhook when a webhook
+// fails VerifyHookRequest.
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+// VerifyWebhook reads the body of a webhook request and checks its
+// signature, returning the body, or ErrInvalidWebhookSignature if it isn't
+// signed with the app's APISecret.
+func (s *App) VerifyWebhook(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !s.VerifyHookRequest(r, body) {
+		s.log(r.Context(), "invalid webhook signature", ErrInvalidWebhookSignature, slog.String("path", r.URL.Path))
+		return nil, ErrInvalidWebhookSignature
+	}
+	return body, nil
+}
+
 func (s *App) VerifyHookRequest(r *http.Request, body []byte) bool {
 	if s.IgnoreSignature {
 		return true
@@ -131,47 +235,140 @@ func (s *App) signatureString(u *url.URL, prependSig bool) string {
 }
 
 func (s *App) AccessToken(shop string, code string) (string, error) {
-	url := fmt.Sprintf("https://%s/admin/oauth/access_token.json", shop)
+	token, err := s.RequestAccessToken(shop, code)
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
 
+// RequestAccessToken exchanges an authorization code for an access token,
+// returning the associated user details for online tokens.
+func (s *App) RequestAccessToken(shop string, code string) (*AccessTokenResponse, error) {
 	data := map[string]string{
 		"client_id":     s.APIKey,
 		"client_secret": s.APISecret,
 		"code":          code,
 	}
+	return s.requestAccessToken(shop, data)
+}
+
+const (
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	idTokenType            = "urn:ietf:params:oauth:token-type:id_token"
+	onlineTokenType        = "urn:shopify:params:oauth:token-type:online-access-token"
+	offlineTokenType       = "urn:shopify:params:oauth:token-type:offline-access-token"
+)
+
+// ExchangeSessionToken exchanges an App Bridge session token for an online or
+// offline access token using the token exchange grant, without redirecting the
+// merchant through the authorization flow. It returns the token response and
+// an API client configured with the new access token.
+//
+// shop must be a myshopify.com domain, and the session token must be valid
+// and issued for shop: the client secret is sent to shop.
+func (s *App) ExchangeSessionToken(shop string, sessionToken string, mode AccessMode) (*AccessTokenResponse, *API, error) {
+	if !ValidShopDomain(shop) {
+		return nil, nil, fmt.Errorf("invalid shop domain %q", shop)
+	}
+	claims, err := s.VerifySessionToken(sessionToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if claims.Shop() != shop {
+		return nil, nil, fmt.Errorf("%w: issued for %s, not %s", ErrInvalidSessionToken, claims.Shop(), shop)
+	}
+
+	requestedTokenType := offlineTokenType
+	if mode == OnlineAccess {
+		requestedTokenType = onlineTokenType
+	}
+
+	data := map[string]string{
+		"client_id":            s.APIKey,
+		"client_secret":        s.APISecret,
+		"grant_type":           tokenExchangeGrantType,
+		"subject_token":        sessionToken,
+		"subject_token_type":   idTokenType,
+		"requested_token_type": requestedTokenType,
+	}
+
+	token, err := s.requestAccessToken(shop, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return token, &API{Shop: shop, AccessToken: token.AccessToken, Logger: s.Logger, LogOptions: s.LogOptions}, nil
+}
+
+func (s *App) requestAccessToken(shop string, data map[string]string) (_ *AccessTokenResponse, err error) {
+	grantType := data["grant_type"]
+	if grantType == "" {
+		grantType = "authorization_code"
+	}
+	start := time.Now()
+	defer func() {
+		message := "shopify access token request"
+		if err != nil {
+			message += " failed"
+		}
+		s.log(context.Background(), message, err,
+			slog.String("shop", shop),
+			slog.String("grant_type", grantType),
+			slog.Duration("duration", time.Since(start)))
+	}()
+
+	url := fmt.Sprintf("https://%s/admin/oauth/access_token.json", shop)
 
 	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(data)
+	err = json.NewEncoder(buf).Encode(data)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req, err := http.NewRequest("POST", url, buf)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
 
-	transport := &http.Transport{}
+	transport := s.Transport
+	if transport == nil {
+		transport = &http.Transport{}
+	}
 	response, err := transport.RoundTrip(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer response.Body.Close()
 
-	token := map[string]string{}
-	err = json.NewDecoder(response.Body).Decode(&token)
+	r := struct {
+		AccessTokenResponse
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	err = json.NewDecoder(response.Body).Decode(&r)
 
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if r.Error != "" {
+		if r.ErrorDescription != "" {
+			return nil, fmt.Errorf("%s: %s", r.Error, r.ErrorDescription)
+		}
+		return nil, fmt.Errorf("%s", r.Error)
 	}
 
-	if _, ok := token["error"]; ok {
-		return "", fmt.Errorf("%s", token["error"])
+	if r.AccessToken == "" {
+		return nil, fmt.Errorf("access_token not found in response")
 	}
 
-	if _, ok := token["access_token"]; !ok {
-		return "", fmt.Errorf("access_token not found in response")
+	token := r.AccessTokenResponse
+	if token.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
 	}
 
-	return token["access_token"], nil
+	return &token, nil
 }
@@ -1,8 +1,15 @@
 package shopify
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 )
 
 var app App
@@ -57,3 +64,186 @@ func TestIgnoreSignature(t *testing.T) {
 		t.Errorf("IgnoreSignature didn't work for AppProxy")
 	}
 }
+
+func TestAuthorizeURLWithMode(t *testing.T) {
+	redir := app.AuthorizeURLWithMode("burnsmod.myshopify.com", "read_orders", OnlineAccess)
+
+	expected := "https://burnsmod.myshopify.com/admin/oauth/authorize?client_id=asdf&grant_options%5B%5D=per-user&redirect_uri=http%3A%2F%2Flocalhost%3A4000&scope=read_orders"
+
+	if redir != expected {
+		t.Errorf("Expected %s, got %s", expected, redir)
+	}
+
+	if app.AuthorizeURLWithMode("burnsmod.myshopify.com", "read_orders", OfflineAccess) != app.AuthorizeURL("burnsmod.myshopify.com", "read_orders") {
+		t.Errorf("Expected offline mode to match AuthorizeURL")
+	}
+}
+
+func TestRequestAccessTokenOnline(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/oauth/access_token.json" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `{
+			"access_token": "f85632530bf277ec9ac6f649fc327f17",
+			"scope": "write_orders",
+			"expires_in": 86399,
+			"associated_user_scope": "write_orders",
+			"associated_user": {
+				"id": 902541635,
+				"first_name": "John",
+				"last_name": "Smith",
+				"email": "john@example.com",
+				"email_verified": true,
+				"account_owner": true,
+				"locale": "en",
+				"collaborator": false
+			}
+		}`)
+	}))
+	defer server.Close()
+
+	a := App{APIKey: "asdf", APISecret: "1234", Transport: server.Client().Transport}
+	shop := strings.TrimPrefix(server.URL, "https://")
+
+	token, err := a.RequestAccessToken(shop, "code")
+	if err != nil {
+		t.Fatalf("Error requesting access token: %v", err)
+	}
+
+	if !token.Online() {
+		t.Errorf("Expected an online token")
+	}
+	if token.AssociatedUser == nil || token.AssociatedUser.ID != 902541635 || !token.AssociatedUser.AccountOwner || token.AssociatedUser.Locale != "en" {
+		t.Errorf("Unexpected associated user %#v", token.AssociatedUser)
+	}
+	if token.Expired() {
+		t.Errorf("Expected token not to be expired")
+	}
+	if !token.ExpiresWithin(48 * time.Hour) {
+		t.Errorf("Expected token to expire within 48 hours")
+	}
+}
+
+func TestAccessTokenError(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"error": "invalid_request"}`)
+	}))
+	defer server.Close()
+
+	a := App{APIKey: "asdf", APISecret: "1234", Transport: server.Client().Transport}
+
+	_, err := a.AccessToken(strings.TrimPrefix(server.URL, "https://"), "code")
+	if err == nil || err.Error() != "invalid_request" {
+		t.Errorf("Expected invalid_request error, got %v", err)
+	}
+}
+
+func TestOfflineTokenNeverExpires(t *testing.T) {
+	token := AccessTokenResponse{AccessToken: "abc", Scope: "read_orders"}
+
+	if token.Online() || token.Expired() || token.ExpiresWithin(24*time.Hour) {
+		t.Errorf("Expected offline token never to expire")
+	}
+}
+
+// roundTripFunc is an http.RoundTripper calling itself.
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// shopTransport returns a transport sending the requests for any shop to
+// server.
+func shopTransport(server *httptest.Server) http.RoundTripper {
+	return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		r.URL.Host = server.Listener.Addr().String()
+		return server.Client().Transport.RoundTrip(r)
+	})
+}
+
+func TestExchangeSessionToken(t *testing.T) {
+	var body map[string]string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Error decoding request body: %v", err)
+		}
+		fmt.Fprint(w, `{"access_token": "shpat_offline", "scope": "read_products"}`)
+	}))
+	defer server.Close()
+
+	a := App{APIKey: "asdf", APISecret: "1234", Transport: shopTransport(server)}
+	shop := "burnsmod.myshopify.com"
+	sessionToken := signSessionToken("1234", validSessionTokenClaims())
+
+	token, api, err := a.ExchangeSessionToken(shop, sessionToken, OfflineAccess)
+	if err != nil {
+		t.Fatalf("Error exchanging session token: %v", err)
+	}
+
+	if body["grant_type"] != "urn:ietf:params:oauth:grant-type:token-exchange" ||
+		body["subject_token"] != sessionToken ||
+		body["subject_token_type"] != "urn:ietf:params:oauth:token-type:id_token" ||
+		body["requested_token_type"] != "urn:shopify:params:oauth:token-type:offline-access-token" {
+		t.Errorf("Unexpected token exchange request %#v", body)
+	}
+	if token.AccessToken != "shpat_offline" || token.Online() {
+		t.Errorf("Unexpected token %#v", token)
+	}
+	if api.Shop != shop || api.AccessToken != "shpat_offline" {
+		t.Errorf("Unexpected API %#v", api)
+	}
+}
+
+func TestExchangeSessionTokenError(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprint(w, `{"error": "invalid_subject_token", "error_description": "Session token is expired"}`)
+	}))
+	defer server.Close()
+
+	a := App{APIKey: "asdf", APISecret: "1234", Transport: shopTransport(server)}
+
+	_, _, err := a.ExchangeSessionToken("burnsmod.myshopify.com", signSessionToken("1234", validSessionTokenClaims()), OnlineAccess)
+	if err == nil || err.Error() != "invalid_subject_token: Session token is expired" {
+		t.Errorf("Expected invalid_subject_token error, got %v", err)
+	}
+}
+
+func TestExchangeSessionTokenRejected(t *testing.T) {
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"access_token": "shpat_offline"}`)
+	}))
+	defer server.Close()
+
+	a := App{APIKey: "asdf", APISecret: "1234", Transport: shopTransport(server)}
+	other := validSessionTokenClaims()
+	other.Issuer = "https://other.myshopify.com/admin"
+	other.Dest = "https://other.myshopify.com"
+
+	tests := []struct {
+		name         string
+		shop         string
+		sessionToken string
+		invalidToken bool
+	}{
+		{"shop not on myshopify.com", "attacker.example.com", signSessionToken("1234", validSessionTokenClaims()), false},
+		{"unsigned token", "burnsmod.myshopify.com", "session-token", true},
+		{"token for another shop", "burnsmod.myshopify.com", signSessionToken("1234", other), true},
+	}
+	for _, test := range tests {
+		_, _, err := a.ExchangeSessionToken(test.shop, test.sessionToken, OfflineAccess)
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		} else if errors.Is(err, ErrInvalidSessionToken) != test.invalidToken {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+	if requests != 0 {
+		t.Errorf("Expected no token exchange request, got %d", requests)
+	}
+}
@@ -1,12 +1,6 @@
 package shopify
 
 import (
-	"bytes"
-
-	"encoding/json"
-
-	"fmt"
-
 	"time"
 )
 
@@ -38,58 +32,16 @@ type Article struct {
 	api *API
 }
 
-func (api *API) Articles() ([]Article, error) {
-	res, status, err := api.request("/admin/articles.json", "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := &map[string][]Article{}
-	err = json.NewDecoder(res).Decode(r)
-
-	result := (*r)["articles"]
+var articleResource = newRESTResource[Article]("/admin/articles", "article", "articles")
 
-	if err != nil {
-		return nil, err
-	}
+func (obj *Article) setAPI(api *API) { obj.api = api }
 
-	for _, v := range result {
-		v.api = api
-	}
-
-	return result, nil
+func (api *API) Articles() ([]Article, error) {
+	return articleResource.listValues(api, nil)
 }
 
 func (api *API) Article(id int64) (*Article, error) {
-	endpoint := fmt.Sprintf("/admin/articles/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]Article{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["article"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+	return articleResource.get(api, id, nil)
 }
 
 func (api *API) NewArticle() *Article {
@@ -97,44 +49,5 @@ func (api *API) NewArticle() *Article {
 }
 
 func (obj *Article) Save() error {
-	endpoint := fmt.Sprintf("/admin/articles/%d.json", obj.Id)
-	method := "PUT"
-	expectedStatus := 201
-
-	if obj.Id == 0 {
-		endpoint = fmt.Sprintf("/admin/articles.json")
-		method = "POST"
-		expectedStatus = 201
-	}
-
-	body := map[string]*Article{}
-	body["article"] = obj
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
-
-	if err != nil {
-		return err
-	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	r := map[string]Article{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	if err != nil {
-		return err
-	}
-
-	*obj = r["article"]
-
-	return nil
+	return articleResource.save(obj.api, obj.Id, obj, nil)
 }
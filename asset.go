@@ -1,12 +1,8 @@
 package shopify
 
 import (
-	"bytes"
-
-	"encoding/json"
-
 	"fmt"
-
+	"net/url"
 	"time"
 )
 
@@ -36,104 +32,31 @@ type Asset struct {
 	api *API
 }
 
-func (api *API) Assets(themeId int64) ([]Asset, error) {
-
-	var endpoint string
-	if themeId == 0 {
-		endpoint = "/admin/assets.json"
-	} else {
-		endpoint = fmt.Sprintf("/admin/themes/%d/assets.json", themeId)
-	}
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
+var assetResource = newRESTResource[Asset]("/admin/assets", "asset", "assets")
 
-	r := &map[string][]Asset{}
-	err = json.NewDecoder(res).Decode(r)
+func (obj *Asset) setAPI(api *API) { obj.api = api }
 
-	result := (*r)["assets"]
-
-	if err != nil {
-		return nil, err
-	}
+func themeAssets(themeId int64) restResource[Asset, *Asset] {
+	return assetResource.nested(fmt.Sprintf("/admin/themes/%d", themeId))
+}
 
-	for _, v := range result {
-		v.api = api
+func (api *API) Assets(themeId int64) ([]Asset, error) {
+	if themeId == 0 {
+		return assetResource.listValues(api, nil)
 	}
-
-	return result, nil
+	return themeAssets(themeId).listValues(api, nil)
 }
 
 func (api *API) Asset(themeId int64, assetKey string) (*Asset, error) {
-	endpoint := fmt.Sprintf("/admin/themes/%d/assets.json?asset=%s&theme_id=%d", themeId, assetKey, themeId)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]Asset{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["asset"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+	endpoint := fmt.Sprintf("%s?asset[key]=%s", themeAssets(themeId).collection(), url.QueryEscape(assetKey))
+	return assetResource.getAt(api, endpoint)
 }
 
 func (api *API) NewAsset() *Asset {
 	return &Asset{api: api}
 }
 
+// Save creates or updates the asset with the key of obj in its theme.
 func (obj *Asset) Save() error {
-	endpoint := fmt.Sprintf("/admin/themes/%d/asset.json?asset=%s&theme_id=%d", obj.ThemeId, obj.Key, obj.ThemeId)
-	method := "PUT"
-	expectedStatus := 201
-
-	body := map[string]*Asset{}
-	body["asset"] = obj
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
-
-	if err != nil {
-		return err
-	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	r := map[string]Asset{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	if err != nil {
-		return err
-	}
-
-	*obj = r["asset"]
-
-	return nil
+	return assetResource.write(obj.api, themeAssets(obj.ThemeId).collection(), "PUT", 200, obj, nil)
 }
@@ -1,12 +1,6 @@
 package shopify
 
 import (
-	"bytes"
-
-	"encoding/json"
-
-	"fmt"
-
 	"time"
 )
 
@@ -34,58 +28,16 @@ type Blog struct {
 	api *API
 }
 
-func (api *API) Blogs() ([]Blog, error) {
-	res, status, err := api.request("/admin/blogs.json", "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := &map[string][]Blog{}
-	err = json.NewDecoder(res).Decode(r)
-
-	result := (*r)["blogs"]
+var blogResource = newRESTResource[Blog]("/admin/blogs", "blog", "blogs")
 
-	if err != nil {
-		return nil, err
-	}
+func (obj *Blog) setAPI(api *API) { obj.api = api }
 
-	for _, v := range result {
-		v.api = api
-	}
-
-	return result, nil
+func (api *API) Blogs() ([]Blog, error) {
+	return blogResource.listValues(api, nil)
 }
 
 func (api *API) Blog(id int64) (*Blog, error) {
-	endpoint := fmt.Sprintf("/admin/blogs/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]Blog{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["blog"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+	return blogResource.get(api, id, nil)
 }
 
 func (api *API) NewBlog() *Blog {
@@ -93,44 +45,5 @@ func (api *API) NewBlog() *Blog {
 }
 
 func (obj *Blog) Save() error {
-	endpoint := fmt.Sprintf("/admin/blogs/%d.json", obj.Id)
-	method := "PUT"
-	expectedStatus := 201
-
-	if obj.Id == 0 {
-		endpoint = fmt.Sprintf("/admin/blogs.json")
-		method = "POST"
-		expectedStatus = 201
-	}
-
-	body := map[string]*Blog{}
-	body["blog"] = obj
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
-
-	if err != nil {
-		return err
-	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	r := map[string]Blog{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	if err != nil {
-		return err
-	}
-
-	*obj = r["blog"]
-
-	return nil
+	return blogResource.save(obj.api, obj.Id, obj, nil)
 }
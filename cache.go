@@ -0,0 +1,263 @@
+package shopify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CacheEntry is a cached response to a GET request.
+type CacheEntry struct {
+	Body   []byte      `json:"body"`
+	Header http.Header `json:"header,omitempty"`
+	// ETag and LastModified are sent back in If-None-Match and
+	// If-Modified-Since headers to revalidate the entry once it expires.
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Expires      time.Time `json:"expires"`
+}
+
+// Fresh reports whether the entry can be used without revalidation.
+func (e *CacheEntry) Fresh() bool {
+	return time.Now().Before(e.Expires)
+}
+
+// Cache stores responses to GET requests, by shop and resource so that
+// every entry of a resource can be invalidated at once.
+type Cache interface {
+	// Get returns the entry stored for endpoint, or nil.
+	Get(shop, resource, endpoint string) (*CacheEntry, error)
+	Set(shop, resource, endpoint string, entry *CacheEntry) error
+	// Invalidate deletes every entry of resource.
+	Invalidate(shop, resource string) error
+}
+
+// DefaultCacheTTLs returns TTLs for reference data which rarely changes, for
+// use as API.CacheTTLs.
+func DefaultCacheTTLs() map[string]time.Duration {
+	return map[string]time.Duration{
+		"shop":               time.Hour,
+		"countries":          24 * time.Hour,
+		"locations":          time.Hour,
+		"themes":             time.Hour,
+		"custom_collections": 10 * time.Minute,
+		"smart_collections":  10 * time.Minute,
+	}
+}
+
+func (api *API) cacheTTL(method, resource string) time.Duration {
+	if api.Cache == nil || method != "GET" {
+		return 0
+	}
+	return api.CacheTTLs[resource]
+}
+
+// cachedResponse returns the entry to use for a GET request, and the
+// headers revalidating it when it has expired.
+func (api *API) cachedResponse(resource, endpoint string) (*CacheEntry, http.Header) {
+	entry, err := api.Cache.Get(api.Shop, resource, endpoint)
+	if err != nil {
+		api.logCacheError("reading cache failed", resource, err)
+		return nil, nil
+	}
+	if entry == nil || entry.Fresh() {
+		return entry, nil
+	}
+
+	header := http.Header{}
+	if entry.ETag != "" {
+		header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		header.Set("If-Modified-Since", entry.LastModified)
+	}
+	return entry, header
+}
+
+// storeResponse caches the response to a GET request, returning the body
+// and status to use: the cached ones if the response is 304 Not Modified.
+func (api *API) storeResponse(resource, endpoint string, ttl time.Duration, entry *CacheEntry, event *RequestEvent) ([]byte, int) {
+	body, status := event.ResponseBody, event.StatusCode
+	switch {
+	case status == http.StatusNotModified && entry != nil:
+		body, status = entry.Body, http.StatusOK
+	case status == http.StatusOK:
+		entry = &CacheEntry{
+			Body:         body,
+			Header:       event.ResponseHeader,
+			ETag:         event.ResponseHeader.Get("ETag"),
+			LastModified: event.ResponseHeader.Get("Last-Modified"),
+		}
+	default:
+		return body, status
+	}
+
+	entry.Expires = time.Now().Add(ttl)
+	if err := api.Cache.Set(api.Shop, resource, endpoint, entry); err != nil {
+		api.logCacheError("writing cache failed", resource, err)
+	}
+	return body, status
+}
+
+// dependentResources are the resources changed as a side effect of
+// changing another, e.g. the location of a fulfillment service.
+var dependentResources = map[string][]string{
+	"fulfillment_services": {"locations"},
+	// the products of collections are listed under /collections
+	"collects":           {"collections"},
+	"custom_collections": {"collections"},
+	"smart_collections":  {"collections"},
+}
+
+func (api *API) invalidateCache(resource string) {
+	for _, r := range append([]string{resource}, dependentResources[resource]...) {
+		if err := api.Cache.Invalidate(api.Shop, r); err != nil {
+			api.logCacheError("invalidating cache failed", r, err)
+		}
+	}
+}
+
+// invalidateCachedResources invalidates every resource with a cache TTL.
+func (api *API) invalidateCachedResources() {
+	for resource := range api.CacheTTLs {
+		if err := api.Cache.Invalidate(api.Shop, resource); err != nil {
+			api.logCacheError("invalidating cache failed", resource, err)
+		}
+	}
+}
+
+func (api *API) logCacheError(message, resource string, err error) {
+	if api.Logger != nil {
+		api.Logger.Warn(message, slog.String("shop", api.Shop), slog.String("resource", resource), slog.String("error", err.Error()))
+	}
+}
+
+// MemoryCache is a Cache keeping entries in memory.
+type MemoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]map[string]CacheEntry
+}
+
+// NewMemoryCache returns an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: map[string]map[string]CacheEntry{}}
+}
+
+func memoryCacheKey(shop, resource string) string {
+	return shop + "/" + resource
+}
+
+func (m *MemoryCache) Get(shop, resource, endpoint string) (*CacheEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[memoryCacheKey(shop, resource)][endpoint]
+	if !ok {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (m *MemoryCache) Set(shop, resource, endpoint string, entry *CacheEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memoryCacheKey(shop, resource)
+	if m.entries[key] == nil {
+		m.entries[key] = map[string]CacheEntry{}
+	}
+	m.entries[key][endpoint] = *entry
+	return nil
+}
+
+func (m *MemoryCache) Invalidate(shop, resource string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, memoryCacheKey(shop, resource))
+	return nil
+}
+
+// FileCache is a Cache keeping each entry in a JSON file, in a directory per
+// shop and resource.
+type FileCache struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+// NewFileCache returns a FileCache using dir, creating it if needed.
+func NewFileCache(dir string) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileCache{Dir: dir}, nil
+}
+
+func (f *FileCache) dir(shop, resource string) string {
+	// QueryEscape never leaves a path separator, so the files stay inside Dir.
+	return filepath.Join(f.Dir, url.QueryEscape(shop), url.QueryEscape(resource))
+}
+
+func (f *FileCache) path(shop, resource, endpoint string) string {
+	sum := sha256.Sum256([]byte(endpoint))
+	return filepath.Join(f.dir(shop, resource), hex.EncodeToString(sum[:])+".json")
+}
+
+func (f *FileCache) Get(shop, resource, endpoint string) (*CacheEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.path(shop, resource, endpoint))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entry := &CacheEntry{}
+	if err = json.NewDecoder(file).Decode(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (f *FileCache) Set(shop, resource, endpoint string, entry *CacheEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dir := f.dir(shop, resource)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".entry-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = json.NewEncoder(tmp).Encode(entry); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path(shop, resource, endpoint))
+}
+
+func (f *FileCache) Invalidate(shop, resource string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return os.RemoveAll(f.dir(shop, resource))
+}
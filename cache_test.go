@@ -0,0 +1,172 @@
+package shopify
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cacheServer serves versioned collections with an ETag, answering
+// conditional requests with 304 Not Modified.
+type cacheServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	version     int
+	requests    []*http.Request
+	notModified int
+}
+
+func newCacheServer(t *testing.T) *cacheServer {
+	s := &cacheServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, r)
+
+		if r.URL.Path == "/admin/api/2024-01/graphql.json" {
+			if body, _ := io.ReadAll(r.Body); strings.Contains(string(body), "mutation") {
+				s.version++
+			}
+			w.Write([]byte(`{"data":{}}`))
+			return
+		}
+		if r.Method == "POST" {
+			s.version++
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"custom_collection":{"id":2,"title":"New"}}`))
+			return
+		}
+
+		version := fmt.Sprintf("v%d", s.version)
+		etag := `"` + version + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			s.notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(`{"custom_collections":[{"id":1,"title":"Version ` + version + `"}],"products":[]}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *cacheServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func TestCache(t *testing.T) {
+	fileCache, err := NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Error creating file cache: %v", err)
+	}
+	caches := map[string]Cache{
+		"memory": NewMemoryCache(),
+		"file":   fileCache,
+	}
+
+	for name, cache := range caches {
+		srv := newCacheServer(t)
+		api := &API{Shop: "example.myshopify.com", BaseURL: srv.URL, Cache: cache, CacheTTLs: DefaultCacheTTLs()}
+
+		collections, err := api.CustomCollections()
+		if err != nil || len(collections) != 1 {
+			t.Fatalf("%s: error fetching collections: %v", name, err)
+		}
+		if collections, _ = api.CustomCollections(); len(collections) != 1 || srv.count() != 1 {
+			t.Errorf("%s: expected cached collections, got %d requests", name, srv.count())
+		}
+		if _, err = api.CustomCollectionsWithOptions(&CollectionOptions{Handle: "sale"}); err != nil || srv.count() != 2 {
+			t.Errorf("%s: expected other queries to be cached separately, got %d requests", name, srv.count())
+		}
+
+		// resources without a TTL aren't cached
+		api.Products(nil)
+		api.Products(nil)
+		if srv.count() != 4 {
+			t.Errorf("%s: expected products not to be cached, got %d requests", name, srv.count())
+		}
+
+		// saving a collection invalidates every cached collection
+		collection := api.NewCustomCollection()
+		collection.Title = "New"
+		if err = collection.Save(); err != nil {
+			t.Fatalf("%s: error saving collection: %v", name, err)
+		}
+		collections, err = api.CustomCollections()
+		if err != nil || srv.count() != 6 || collections[0].Title != "Version v1" {
+			t.Errorf("%s: expected cache to be invalidated, got %d requests, %v", name, srv.count(), collections)
+		}
+
+		// GraphQL queries leave the cache alone, and mutations invalidate it
+		api.Version = "2024-01"
+		if err = api.GraphQL("{ shop { id } }", nil, nil); err != nil {
+			t.Fatalf("%s: error running query: %v", name, err)
+		}
+		if api.CustomCollections(); srv.count() != 7 {
+			t.Errorf("%s: expected collections to stay cached after a query, got %d requests", name, srv.count())
+		}
+		if err = api.GraphQL("mutation { collectionUpdate(input: {}) { userErrors { message } } }", nil, nil); err != nil {
+			t.Fatalf("%s: error running mutation: %v", name, err)
+		}
+		collections, err = api.CustomCollections()
+		if err != nil || srv.count() != 9 || collections[0].Title != "Version v2" {
+			t.Errorf("%s: expected a mutation to invalidate the cache, got %d requests, %v", name, srv.count(), collections)
+		}
+	}
+}
+
+func TestCacheRevalidation(t *testing.T) {
+	srv := newCacheServer(t)
+	cache := NewMemoryCache()
+	api := &API{
+		Shop:      "example.myshopify.com",
+		BaseURL:   srv.URL,
+		Cache:     cache,
+		CacheTTLs: map[string]time.Duration{"custom_collections": time.Hour},
+	}
+
+	if _, err := api.CustomCollections(); err != nil {
+		t.Fatalf("Error fetching collections: %v", err)
+	}
+
+	// expire the entry
+	endpoint := srv.requests[0].URL.RequestURI()
+	entry, _ := cache.Get(api.Shop, "custom_collections", endpoint)
+	if entry == nil || entry.ETag != `"v0"` {
+		t.Fatalf("Expected an entry with an ETag for %s, got %#v", endpoint, entry)
+	}
+	entry.Expires = time.Now().Add(-time.Second)
+	cache.Set(api.Shop, "custom_collections", endpoint, entry)
+
+	collections, err := api.CustomCollections()
+	if err != nil || len(collections) != 1 || collections[0].Title != "Version v0" {
+		t.Fatalf("Expected cached collections after revalidation, got %v (%v)", collections, err)
+	}
+	if srv.count() != 2 || srv.notModified != 1 || srv.requests[1].Header.Get("If-None-Match") != `"v0"` {
+		t.Errorf("Expected a conditional request, got %d requests", srv.count())
+	}
+
+	if entry, _ = cache.Get(api.Shop, "custom_collections", endpoint); !entry.Fresh() {
+		t.Errorf("Expected the entry to be fresh again")
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	srv := newCacheServer(t)
+	api := &API{Shop: "example.myshopify.com", BaseURL: srv.URL, CacheTTLs: DefaultCacheTTLs()}
+
+	api.CustomCollections()
+	api.CustomCollections()
+	if srv.count() != 2 {
+		t.Errorf("Expected no caching without a Cache, got %d requests", srv.count())
+	}
+}
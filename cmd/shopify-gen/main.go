@@ -0,0 +1,401 @@
+// Command shopify-gen generates the structs, options types and CRUD methods
+// of resources from a schema, so that they can be regenerated for a new API
+// version rather than patched by hand. It's run by go generate in the root
+// package:
+//
+//	go run ./cmd/shopify-gen -schema schema/resources.json -out .
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Schema describes the generated types.
+type Schema struct {
+	Package string  `json:"package"`
+	Types   []*Type `json:"types"`
+}
+
+// Type is a struct, written to File. Several types can share a file.
+type Type struct {
+	Name   string   `json:"name"`
+	File   string   `json:"file"`
+	Doc    string   `json:"doc"`
+	Fields []*Field `json:"fields"`
+	// Resource, if set, makes the type a REST resource with an api pointer
+	// and the methods of its operations.
+	Resource *Resource `json:"resource"`
+}
+
+// Field is a field of a struct or options type.
+type Field struct {
+	// Name is the Go name, by default JSON in camel case.
+	Name string `json:"name"`
+	JSON string `json:"json"`
+	// Query is the query string parameter of an options field.
+	Query     string `json:"query"`
+	Type      string `json:"type"`
+	OmitEmpty bool   `json:"omitempty"`
+}
+
+// Resource is where a REST resource lives and what can be done with it.
+type Resource struct {
+	// Path is the collection's path without the .json suffix.
+	Path     string `json:"path"`
+	Singular string `json:"singular"`
+	Plural   string `json:"plural"`
+	// Operations are among list, count, get, save and delete.
+	Operations []string `json:"operations"`
+	Options    *Options `json:"options"`
+	// Parent, if set, nests the collection under a member of another
+	// collection, e.g. the transactions of an order under
+	// /admin/orders/{id}/transactions.
+	Parent *Parent `json:"parent"`
+}
+
+// Parent is the resource a nested resource belongs to.
+type Parent struct {
+	// Path is the parent's collection path, e.g. /admin/orders.
+	Path string `json:"path"`
+	// Field is the JSON name of the int64 field holding the parent's id,
+	// e.g. order_id.
+	Field string `json:"field"`
+}
+
+// Options is the options type filtering lists and counts.
+type Options struct {
+	Name   string   `json:"name"`
+	Fields []*Field `json:"fields"`
+}
+
+// goName returns the Go name of a snake case JSON name, e.g. SourceUrl for
+// source_url.
+func goName(name string) string {
+	parts := strings.Split(name, "_")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// Load reads and validates a schema.
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schema := &Schema{}
+	if err = json.Unmarshal(data, schema); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return schema, schema.validate()
+}
+
+func (s *Schema) validate() error {
+	if s.Package == "" {
+		return fmt.Errorf("schema has no package")
+	}
+	names := map[string]bool{}
+	for _, t := range s.Types {
+		if t.Name == "" || t.File == "" {
+			return fmt.Errorf("type %q needs a name and a file", t.Name)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("type %s is defined twice", t.Name)
+		}
+		names[t.Name] = true
+
+		for _, f := range t.Fields {
+			if f.Name == "" {
+				f.Name = goName(f.JSON)
+			}
+			if f.JSON == "" || f.Type == "" {
+				return fmt.Errorf("%s.%s needs a json name and a type", t.Name, f.Name)
+			}
+		}
+
+		r := t.Resource
+		if r == nil {
+			continue
+		}
+		if r.Path == "" || r.Singular == "" || r.Plural == "" {
+			return fmt.Errorf("resource %s needs a path, singular and plural", t.Name)
+		}
+		if t.IDField() == "" {
+			return fmt.Errorf("resource %s has no id field", t.Name)
+		}
+		if p := r.Parent; p != nil {
+			if p.Path == "" || !strings.HasSuffix(p.Field, "_id") {
+				return fmt.Errorf("resource %s needs a parent path and an _id field", t.Name)
+			}
+			if f := t.field(p.Field); f == nil || f.Type != "int64" {
+				return fmt.Errorf("resource %s has no int64 %s field", t.Name, p.Field)
+			}
+		}
+		for _, op := range r.Operations {
+			switch op {
+			case "list", "count":
+				if r.Options == nil || r.Options.Name == "" {
+					return fmt.Errorf("resource %s needs options to %s", t.Name, op)
+				}
+			case "get", "save", "delete":
+			default:
+				return fmt.Errorf("resource %s has unknown operation %q", t.Name, op)
+			}
+		}
+		if r.Options != nil {
+			for _, f := range r.Options.Fields {
+				if f.Name == "" || f.Query == "" || f.Type == "" {
+					return fmt.Errorf("%s.%s needs a name, query and type", r.Options.Name, f.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// field returns the field with the JSON name json, or nil.
+func (t *Type) field(json string) *Field {
+	for _, f := range t.Fields {
+		if f.JSON == json {
+			return f
+		}
+	}
+	return nil
+}
+
+// IDField returns the Go name of the field holding the id.
+func (t *Type) IDField() string {
+	if f := t.field("id"); f != nil {
+		return f.Name
+	}
+	return ""
+}
+
+// Has returns true if the resource supports op.
+func (r *Resource) Has(op string) bool {
+	for _, o := range r.Operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Var returns the name of the variable describing the resource.
+func (t *Type) Var() string {
+	return strings.ToLower(t.Name[:1]) + t.Name[1:] + "Resource"
+}
+
+// Methods returns the plural used in the method names, e.g. Orders.
+func (t *Type) Methods() string {
+	return goName(t.Resource.Plural)
+}
+
+// Noun returns the plural in words, e.g. "custom collections".
+func (t *Type) Noun() string {
+	return strings.ReplaceAll(t.Resource.Plural, "_", " ")
+}
+
+// lowerFirst returns name with its first letter in lower case.
+func lowerFirst(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// ParentParam returns the name of the parameter holding the parent's id,
+// e.g. orderId, or "" if the resource isn't nested.
+func (t *Type) ParentParam() string {
+	if t.Resource.Parent == nil {
+		return ""
+	}
+	return lowerFirst(t.field(t.Resource.Parent.Field).Name)
+}
+
+// ParentNoun returns the parent in words, e.g. "order".
+func (t *Type) ParentNoun() string {
+	return strings.ReplaceAll(strings.TrimSuffix(t.Resource.Parent.Field, "_id"), "_", " ")
+}
+
+// Nested returns the name of the function returning the resource under a
+// parent, e.g. orderTransactions.
+func (t *Type) Nested() string {
+	return lowerFirst(goName(strings.TrimSuffix(t.Resource.Parent.Field, "_id"))) + t.Methods()
+}
+
+// APIResource returns the expression of the resource in API methods,
+// which take the parent's id as their first parameter.
+func (t *Type) APIResource() string {
+	if t.Resource.Parent == nil {
+		return t.Var()
+	}
+	return fmt.Sprintf("%s(%s)", t.Nested(), t.ParentParam())
+}
+
+// ObjResource returns the expression of the resource in the methods of
+// the type.
+func (t *Type) ObjResource() string {
+	if t.Resource.Parent == nil {
+		return t.Var()
+	}
+	return fmt.Sprintf("%s(obj.%s)", t.Nested(), t.field(t.Resource.Parent.Field).Name)
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by shopify-gen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+{{if .Imports}}
+import (
+{{range .Imports}}	"{{.}}"
+{{end}})
+{{end}}
+{{range .Types}}{{$t := .}}
+// {{.Doc}}
+type {{.Name}} struct {
+{{range .Fields}}	{{.Name}} {{.Type}} ` + "`" + `json:"{{.JSON}}{{if .OmitEmpty}},omitempty{{end}}"` + "`" + `
+{{end}}{{if .Resource}}
+	api *API
+{{end}}}
+{{with .Resource}}{{with .Options}}
+// {{.Name}} filters the {{$t.Noun}} listed and counted.
+type {{.Name}} struct {
+{{range .Fields}}	{{.Name}} {{.Type}} ` + "`" + `url:"{{.Query}},omitempty"` + "`" + `
+{{end}}}
+{{end}}
+var {{$t.Var}} = newRESTResource[{{$t.Name}}]("{{.Path}}", "{{.Singular}}", "{{.Plural}}")
+
+func (obj *{{$t.Name}}) setAPI(api *API) { obj.api = api }
+{{with .Parent}}
+// {{$t.Nested}} returns the {{$t.Noun}} of the {{$t.ParentNoun}} with {{$t.ParentParam}}.
+func {{$t.Nested}}({{$t.ParentParam}} int64) restResource[{{$t.Name}}, *{{$t.Name}}] {
+	return {{$t.Var}}.nested(fmt.Sprintf("{{.Path}}/%d", {{$t.ParentParam}}))
+}
+{{end}}{{if .Has "list"}}
+// {{$t.Methods}} returns the {{$t.Noun}}{{with $t.ParentParam}} of the {{$t.ParentNoun}} with {{.}}{{end}}.
+func (api *API) {{$t.Methods}}({{with $t.ParentParam}}{{.}} int64{{end}}) ([]{{$t.Name}}, error) {
+	return api.{{$t.Methods}}WithOptions({{with $t.ParentParam}}{{.}}, {{end}}&{{.Options.Name}}{})
+}
+
+// {{$t.Methods}}WithOptions returns the {{$t.Noun}}{{with $t.ParentParam}} of the {{$t.ParentNoun}} with {{.}}{{end}} matching options.
+func (api *API) {{$t.Methods}}WithOptions({{with $t.ParentParam}}{{.}} int64, {{end}}options *{{.Options.Name}}) ([]{{$t.Name}}, error) {
+	return {{$t.APIResource}}.listValues(api, options)
+}
+{{end}}{{if .Has "count"}}
+// {{$t.Methods}}Count returns the number of {{$t.Noun}}{{with $t.ParentParam}} of the {{$t.ParentNoun}} with {{.}}{{end}} matching options.
+func (api *API) {{$t.Methods}}Count({{with $t.ParentParam}}{{.}} int64, {{end}}options *{{.Options.Name}}) (int, error) {
+	return {{$t.APIResource}}.count(api, options)
+}
+{{end}}{{if .Has "get"}}
+// {{$t.Name}} returns the {{.Singular}} with id{{with $t.ParentParam}} of the {{$t.ParentNoun}} with {{.}}{{end}}.
+func (api *API) {{$t.Name}}({{with $t.ParentParam}}{{.}}, {{end}}id int64) (*{{$t.Name}}, error) {
+	return {{$t.APIResource}}.get(api, id, nil)
+}
+{{end}}{{if .Has "save"}}
+// New{{$t.Name}} returns a new {{.Singular}}, to be saved with api{{with .Parent}} once its {{$t.ParentNoun}} is set{{end}}.
+func (api *API) New{{$t.Name}}() *{{$t.Name}} {
+	return &{{$t.Name}}{api: api}
+}
+
+// Save creates the {{.Singular}} if it has no id, and updates it otherwise.
+func (obj *{{$t.Name}}) Save() error {
+	return {{$t.ObjResource}}.save(obj.api, obj.{{$t.IDField}}, obj, nil)
+}
+{{end}}{{if .Has "delete"}}
+// Delete deletes the {{.Singular}}.
+func (obj *{{$t.Name}}) Delete() error {
+	return {{$t.ObjResource}}.delete(obj.api, obj.{{$t.IDField}})
+}
+{{end}}{{end}}{{end}}`))
+
+type fileData struct {
+	Source  string
+	Package string
+	Imports []string
+	Types   []*Type
+}
+
+// Generate returns the formatted source of every file of the schema, by file
+// name. source is the schema's path, named in the generated header.
+func Generate(schema *Schema, source string) (map[string][]byte, error) {
+	files := map[string]*fileData{}
+	order := []string{}
+	for _, t := range schema.Types {
+		d, ok := files[t.File]
+		if !ok {
+			d = &fileData{Source: filepath.ToSlash(source), Package: schema.Package}
+			files[t.File] = d
+			order = append(order, t.File)
+		}
+		d.Types = append(d.Types, t)
+	}
+
+	result := map[string][]byte{}
+	for _, name := range order {
+		d := files[name]
+		d.Imports = imports(d.Types)
+
+		buf := &bytes.Buffer{}
+		if err := fileTemplate.Execute(buf, d); err != nil {
+			return nil, err
+		}
+		src, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v\n%s", name, err, buf.Bytes())
+		}
+		result[name] = src
+	}
+	return result, nil
+}
+
+// imports returns the packages used by the fields and nested resources of
+// types.
+func imports(types []*Type) []string {
+	used := map[string]bool{}
+	for _, t := range types {
+		if t.Resource != nil && t.Resource.Parent != nil {
+			used["fmt"] = true
+		}
+		for _, f := range t.Fields {
+			if strings.Contains(f.Type, "time.") {
+				used["time"] = true
+			}
+		}
+	}
+	result := []string{}
+	for pkg := range used {
+		result = append(result, pkg)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func main() {
+	schemaPath := flag.String("schema", "schema/resources.json", "the schema to generate from")
+	out := flag.String("out", ".", "the directory to write the files to")
+	flag.Parse()
+
+	schema, err := Load(*schemaPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	files, err := Generate(schema, *schemaPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for name, src := range files {
+		if err = os.WriteFile(filepath.Join(*out, name), src, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedFilesUpToDate(t *testing.T) {
+	root := filepath.Join("..", "..")
+	schema, err := Load(filepath.Join(root, "schema", "resources.json"))
+	if err != nil {
+		t.Fatalf("Error loading schema: %v", err)
+	}
+	files, err := Generate(schema, "schema/resources.json")
+	if err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+
+	for name, src := range files {
+		current, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			t.Errorf("Error reading %s: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(current, src) {
+			t.Errorf("%s is out of date, run go generate", name)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	schema := &Schema{
+		Package: "shopify",
+		Types: []*Type{{
+			Name: "Gift",
+			File: "gift.go",
+			Doc:  "Gift is a gift.",
+			Fields: []*Field{
+				{JSON: "id", Type: "int64"},
+				{JSON: "sent_at", Type: "*time.Time", OmitEmpty: true},
+			},
+			Resource: &Resource{
+				Path:       "/admin/gifts",
+				Singular:   "gift",
+				Plural:     "gifts",
+				Operations: []string{"get", "delete"},
+			},
+		}},
+	}
+	if err := schema.validate(); err != nil {
+		t.Fatalf("Error validating schema: %v", err)
+	}
+	files, err := Generate(schema, "gifts.json")
+	if err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+
+	src := string(files["gift.go"])
+	for _, expected := range []string{
+		"// Code generated by shopify-gen from gifts.json. DO NOT EDIT.",
+		`import (` + "\n\t\"time\"\n)",
+		"SentAt *time.Time `json:\"sent_at,omitempty\"`",
+		`var giftResource = newRESTResource[Gift]("/admin/gifts", "gift", "gifts")`,
+		"func (api *API) Gift(id int64) (*Gift, error) {",
+		"return giftResource.delete(obj.api, obj.Id)",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("Expected %q in\n%s", expected, src)
+		}
+	}
+	for _, unexpected := range []string{"func (api *API) Gifts()", "func (obj *Gift) Save()"} {
+		if strings.Contains(src, unexpected) {
+			t.Errorf("Didn't expect %q for operations get and delete", unexpected)
+		}
+	}
+}
+
+func TestGenerateNested(t *testing.T) {
+	schema := &Schema{
+		Package: "shopify",
+		Types: []*Type{{
+			Name:   "Gift",
+			File:   "gift.go",
+			Doc:    "Gift is a gift.",
+			Fields: []*Field{{JSON: "id", Type: "int64"}, {JSON: "gift_card_id", Type: "int64"}},
+			Resource: &Resource{
+				Path:       "/admin/gifts",
+				Singular:   "gift",
+				Plural:     "gifts",
+				Operations: []string{"list", "get", "save"},
+				Options:    &Options{Name: "GiftsOptions"},
+				Parent:     &Parent{Path: "/admin/gift_cards", Field: "gift_card_id"},
+			},
+		}},
+	}
+	if err := schema.validate(); err != nil {
+		t.Fatalf("Error validating schema: %v", err)
+	}
+	files, err := Generate(schema, "gifts.json")
+	if err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+
+	src := string(files["gift.go"])
+	for _, expected := range []string{
+		`import (` + "\n\t\"fmt\"\n)",
+		"func giftCardGifts(giftCardId int64) restResource[Gift, *Gift] {",
+		`return giftResource.nested(fmt.Sprintf("/admin/gift_cards/%d", giftCardId))`,
+		"func (api *API) Gifts(giftCardId int64) ([]Gift, error) {",
+		"return api.GiftsWithOptions(giftCardId, &GiftsOptions{})",
+		"return giftCardGifts(giftCardId).listValues(api, options)",
+		"func (api *API) Gift(giftCardId, id int64) (*Gift, error) {",
+		"return giftCardGifts(obj.GiftCardId).save(obj.api, obj.Id, obj, nil)",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("Expected %q in\n%s", expected, src)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]*Schema{
+		"no package": {Types: []*Type{}},
+		"no options": {Package: "shopify", Types: []*Type{{
+			Name: "Gift", File: "gift.go",
+			Fields:   []*Field{{JSON: "id", Type: "int64"}},
+			Resource: &Resource{Path: "/admin/gifts", Singular: "gift", Plural: "gifts", Operations: []string{"list"}},
+		}}},
+		"no id": {Package: "shopify", Types: []*Type{{
+			Name: "Gift", File: "gift.go",
+			Fields:   []*Field{{JSON: "name", Type: "string"}},
+			Resource: &Resource{Path: "/admin/gifts", Singular: "gift", Plural: "gifts"},
+		}}},
+		"parent without id field": {Package: "shopify", Types: []*Type{{
+			Name: "Gift", File: "gift.go",
+			Fields:   []*Field{{JSON: "id", Type: "int64"}},
+			Resource: &Resource{Path: "/admin/gifts", Singular: "gift", Plural: "gifts", Parent: &Parent{Path: "/admin/gift_cards", Field: "gift_card_id"}},
+		}}},
+		"unknown operation": {Package: "shopify", Types: []*Type{{
+			Name: "Gift", File: "gift.go",
+			Fields:   []*Field{{JSON: "id", Type: "int64"}},
+			Resource: &Resource{Path: "/admin/gifts", Singular: "gift", Plural: "gifts", Operations: []string{"archive"}},
+		}}},
+	}
+
+	for name, schema := range tests {
+		if err := schema.validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
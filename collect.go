@@ -1,10 +1,7 @@
 package shopify
 
 import (
-	"encoding/json"
-
 	"fmt"
-
 	"time"
 )
 
@@ -29,10 +26,18 @@ type Collect struct {
 }
 
 type CollectOptions struct {
-	Limit int `url:"limit,omitempty"`
-	Page  int `url:"page,omitempty"`
+	Limit        int    `url:"limit,omitempty"`
+	Page         int    `url:"page,omitempty"`
+	SinceID      int64  `url:"since_id,omitempty"`
+	ProductID    int64  `url:"product_id,omitempty"`
+	CollectionID int64  `url:"collection_id,omitempty"`
+	Fields       string `url:"fields,omitempty"`
 }
 
+var collectResource = newRESTResource[Collect]("/admin/collects", "collect", "collects")
+
+func (obj *Collect) setAPI(api *API) { obj.api = api }
+
 func (api *API) NewCollect() *Collect {
 	return &Collect{api: api}
 }
@@ -42,55 +47,47 @@ func (api *API) Collects() ([]Collect, error) {
 }
 
 func (api *API) CollectsWithOptions(options *CollectOptions) ([]Collect, error) {
-	qs := encodeOptions(options)
-	endpoint := fmt.Sprintf("/admin/collects.json?%v", qs)
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := &map[string][]Collect{}
-	err = json.NewDecoder(res).Decode(r)
-	result := (*r)["collects"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	for _, v := range result {
-		v.api = api
-	}
+	return collectResource.listValues(api, options)
+}
 
-	return result, nil
+// CollectsCount returns the number of collects matching options.
+func (api *API) CollectsCount(options *CollectOptions) (int, error) {
+	return collectResource.count(api, options)
 }
 
 func (api *API) Collect(id int64) (*Collect, error) {
-	endpoint := fmt.Sprintf("/admin/collects/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
+	return collectResource.get(api, id, nil)
+}
 
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
+// Save creates the collect, adding its product to its custom collection.
+// Collects can't be updated, only deleted.
+func (obj *Collect) Save() error {
+	if obj.Id != 0 {
+		return fmt.Errorf("collect %d already exists, and can't be updated", obj.Id)
 	}
+	body := map[string]interface{}{"product_id": obj.ProductId, "collection_id": obj.CollectionId}
+	return collectResource.create(obj.api, obj, body)
+}
 
-	r := map[string]Collect{}
-	err = json.NewDecoder(res).Decode(&r)
-	result := r["collect"]
+// Delete deletes the collect, removing its product from its collection.
+func (obj *Collect) Delete() error {
+	return collectResource.delete(obj.api, obj.Id)
+}
 
-	if err != nil {
-		return nil, err
+// allCollects returns every collect matching options, following the pages
+// of results by id.
+func (api *API) allCollects(options CollectOptions) ([]*Collect, error) {
+	options.Limit = 250
+	result := []*Collect{}
+	for {
+		collects, err := collectResource.list(api, &options)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, collects...)
+		if len(collects) < options.Limit {
+			return result, nil
+		}
+		options.SinceID = collects[len(collects)-1].Id
 	}
-
-	result.api = api
-
-	return &result, nil
 }
@@ -0,0 +1,217 @@
+package shopify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCollectRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		call   func(api *API) error
+		method string
+		uri    string
+		// sent is the expected request body, if any
+		sent string
+	}{
+		{
+			name: "list", status: 200, body: `{"collects":[{"id":5,"product_id":2}]}`,
+			call: func(api *API) error {
+				collects, err := api.CollectsWithOptions(&CollectOptions{CollectionID: 4})
+				if err == nil && collects[0].api != api {
+					return errors.New("expected the api pointer to be kept")
+				}
+				return err
+			},
+			method: "GET", uri: "/admin/collects.json?collection_id=4",
+		},
+		{
+			name: "count", status: 200, body: `{"count":3}`,
+			call: func(api *API) error {
+				count, err := api.CollectsCount(&CollectOptions{ProductID: 2})
+				if err == nil && count != 3 {
+					return fmt.Errorf("expected 3 collects, got %d", count)
+				}
+				return err
+			},
+			method: "GET", uri: "/admin/collects/count.json?product_id=2",
+		},
+		{
+			name: "create", status: 201, body: `{"collect":{"id":5,"product_id":2,"collection_id":4}}`,
+			call: func(api *API) error {
+				collect := api.NewCollect()
+				collect.ProductId, collect.CollectionId = 2, 4
+				if err := collect.Save(); err != nil {
+					return err
+				}
+				if collect.Id != 5 {
+					return errors.New("expected the collect to be replaced")
+				}
+				return nil
+			},
+			method: "POST", uri: "/admin/collects.json",
+			sent: `{"collect":{"collection_id":4,"product_id":2}}`,
+		},
+		{
+			name: "delete", status: 200, body: `{}`,
+			call: func(api *API) error {
+				return (&Collect{Id: 5, api: api}).Delete()
+			},
+			method: "DELETE", uri: "/admin/collects/5.json",
+		},
+		{
+			name: "order", status: 200, body: `{}`,
+			call: func(api *API) error {
+				collection := &SmartCollection{ID: 3, SortOrder: "best-selling", api: api}
+				if err := collection.Order([]int64{2, 1}); err != nil {
+					return err
+				}
+				if collection.SortOrder != "manual" {
+					return errors.New("expected the collection to be sorted manually")
+				}
+				return nil
+			},
+			method: "PUT", uri: "/admin/smart_collections/3/order.json?products%5B%5D=2&products%5B%5D=1&sort_order=manual",
+		},
+	}
+
+	for _, test := range tests {
+		api, recorded := resourceServer(t, test.status, test.body)
+
+		if err := test.call(api); err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if recorded.Method != test.method || recorded.URI != test.uri {
+			t.Errorf("%s: expected %s %s, got %s %s", test.name, test.method, test.uri, recorded.Method, recorded.URI)
+		}
+		if test.sent != "" {
+			if sent, _ := json.Marshal(recorded.Body); string(sent) != test.sent {
+				t.Errorf("%s: expected %s to be sent, got %s", test.name, test.sent, sent)
+			}
+		}
+	}
+}
+
+func TestCollectSaveExisting(t *testing.T) {
+	api, recorded := resourceServer(t, 200, `{}`)
+
+	if err := (&Collect{Id: 5, api: api}).Save(); err == nil {
+		t.Errorf("Expected an error saving an existing collect")
+	}
+	if recorded.Method != "" {
+		t.Errorf("Expected no request, got %s %s", recorded.Method, recorded.URI)
+	}
+}
+
+func TestCollectionProducts(t *testing.T) {
+	mu := sync.Mutex{}
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.RequestURI())
+		mu.Unlock()
+		if r.URL.Query().Get("page_info") == "" {
+			w.Header().Set("Link", `<https://example.myshopify.com/admin/collections/4/products.json?limit=250&page_info=next>; rel="next"`)
+			w.Write([]byte(`{"products":[{"id":1},{"id":2}]}`))
+			return
+		}
+		w.Write([]byte(`{"products":[{"id":3}]}`))
+	}))
+	defer server.Close()
+	api := &API{Shop: "example.myshopify.com", BaseURL: server.URL, RetryPolicy: NoRetry}
+
+	// custom and smart collections list their products the same way
+	lists := map[string]func() ([]*Product, error){
+		"custom": (&CustomCollection{ID: 4, api: api}).Products,
+		"smart":  (&SmartCollection{ID: 4, api: api}).Products,
+	}
+	for name, list := range lists {
+		requests = nil
+		products, err := list()
+		if err != nil {
+			t.Fatalf("%s: error listing products: %v", name, err)
+		}
+		ids := []int64{}
+		for _, product := range products {
+			ids = append(ids, product.ID)
+		}
+		if !reflect.DeepEqual(ids, []int64{1, 2, 3}) || products[2].api != api {
+			t.Errorf("%s: expected products 1, 2 and 3 with the api, got %v", name, ids)
+		}
+		expected := []string{"/admin/collections/4/products.json?limit=250", "/admin/collections/4/products.json?limit=250&page_info=next"}
+		if !reflect.DeepEqual(requests, expected) {
+			t.Errorf("%s: expected requests %v, got %v", name, expected, requests)
+		}
+	}
+}
+
+func TestCustomCollectionSetProducts(t *testing.T) {
+	// the collection holds products 1 to 251, more than a page of collects
+	mu := sync.Mutex{}
+	current := map[int64]bool{}
+	for id := int64(1); id <= 251; id++ {
+		current[id] = true
+	}
+	lists, created, deleted := 0, []int64{}, []int64{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case "GET":
+			lists++
+			if r.URL.Query().Get("collection_id") != "7" {
+				t.Errorf("Expected the collects of collection 7, got %s", r.URL.RawQuery)
+			}
+			// collect ids are product ids plus 1000
+			sinceID, _ := strconv.ParseInt(r.URL.Query().Get("since_id"), 10, 64)
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			collects := []map[string]int64{}
+			for id := int64(1); id <= 251 && len(collects) < limit; id++ {
+				if current[id] && id+1000 > sinceID {
+					collects = append(collects, map[string]int64{"id": id + 1000, "product_id": id, "collection_id": 7})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"collects": collects})
+		case "POST":
+			body := map[string]map[string]int64{}
+			json.NewDecoder(r.Body).Decode(&body)
+			created = append(created, body["collect"]["product_id"])
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"collect":{"id":%d}}`, body["collect"]["product_id"]+1000)
+		case "DELETE":
+			var id int64
+			fmt.Sscanf(r.URL.Path, "/admin/collects/%d.json", &id)
+			deleted = append(deleted, id)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+	api := &API{Shop: "example.myshopify.com", BaseURL: server.URL, RetryPolicy: NoRetry}
+
+	wanted := []int64{}
+	for id := int64(2); id <= 252; id++ {
+		wanted = append(wanted, id)
+	}
+	wanted = append(wanted, 252)
+
+	added, removed, err := (&CustomCollection{ID: 7, api: api}).SetProducts(wanted)
+	if err != nil {
+		t.Fatalf("Error setting products: %v", err)
+	}
+	if !reflect.DeepEqual(added, []int64{252}) || !reflect.DeepEqual(removed, []int64{1}) {
+		t.Errorf("Expected 252 to be added and 1 removed, got %v and %v", added, removed)
+	}
+	if lists != 2 || !reflect.DeepEqual(created, []int64{252}) || !reflect.DeepEqual(deleted, []int64{1001}) {
+		t.Errorf("Expected 2 pages of collects, one created and collect 1001 deleted, got %d, %v and %v", lists, created, deleted)
+	}
+}
@@ -1,13 +1,5 @@
 package shopify
 
-import (
-	"bytes"
-
-	"encoding/json"
-
-	"fmt"
-)
-
 type Country struct {
 	Code string `json:"code"`
 
@@ -30,58 +22,16 @@ type Country struct {
 	api *API
 }
 
-func (api *API) Countries() ([]Country, error) {
-	res, status, err := api.request("/admin/countries.json", "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := &map[string][]Country{}
-	err = json.NewDecoder(res).Decode(r)
-
-	result := (*r)["countries"]
+var countryResource = newRESTResource[Country]("/admin/countries", "country", "countries")
 
-	if err != nil {
-		return nil, err
-	}
+func (obj *Country) setAPI(api *API) { obj.api = api }
 
-	for _, v := range result {
-		v.api = api
-	}
-
-	return result, nil
+func (api *API) Countries() ([]Country, error) {
+	return countryResource.listValues(api, nil)
 }
 
 func (api *API) Country(id int64) (*Country, error) {
-	endpoint := fmt.Sprintf("/admin/countries/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]Country{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["country"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+	return countryResource.get(api, id, nil)
 }
 
 func (api *API) NewCountry() *Country {
@@ -89,44 +39,5 @@ func (api *API) NewCountry() *Country {
 }
 
 func (obj *Country) Save() error {
-	endpoint := fmt.Sprintf("/admin/countries/%d.json", obj.Id)
-	method := "PUT"
-	expectedStatus := 201
-
-	if obj.Id == 0 {
-		endpoint = fmt.Sprintf("/admin/countries.json")
-		method = "POST"
-		expectedStatus = 201
-	}
-
-	body := map[string]*Country{}
-	body["country"] = obj
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
-
-	if err != nil {
-		return err
-	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	r := map[string]Country{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	if err != nil {
-		return err
-	}
-
-	*obj = r["country"]
-
-	return nil
+	return countryResource.save(obj.api, obj.Id, obj, nil)
 }
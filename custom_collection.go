@@ -1,12 +1,6 @@
 package shopify
 
 import (
-	"bytes"
-
-	"encoding/json"
-
-	"fmt"
-
 	"time"
 )
 
@@ -34,64 +28,20 @@ type CustomCollection struct {
 	api *API
 }
 
+var customCollectionResource = newRESTResource[CustomCollection]("/admin/custom_collections", "custom_collection", "custom_collections")
+
+func (obj *CustomCollection) setAPI(api *API) { obj.api = api }
+
 func (api *API) CustomCollections() ([]CustomCollection, error) {
 	return api.CustomCollectionsWithOptions(&CollectionOptions{})
 }
 
 func (api *API) CustomCollectionsWithOptions(options *CollectionOptions) ([]CustomCollection, error) {
-	qs := encodeOptions(options)
-	endpoint := fmt.Sprintf("/admin/custom_collections.json?%v", qs)
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := &map[string][]CustomCollection{}
-	err = json.NewDecoder(res).Decode(r)
-
-	result := (*r)["custom_collections"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	for _, v := range result {
-		v.api = api
-	}
-
-	return result, nil
+	return customCollectionResource.listValues(api, options)
 }
 
 func (api *API) CustomCollection(id int64) (*CustomCollection, error) {
-	endpoint := fmt.Sprintf("/admin/custom_collections/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]CustomCollection{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["custom_collection"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+	return customCollectionResource.get(api, id, nil)
 }
 
 func (api *API) NewCustomCollection() *CustomCollection {
@@ -99,44 +49,54 @@ func (api *API) NewCustomCollection() *CustomCollection {
 }
 
 func (obj *CustomCollection) Save() error {
-	endpoint := fmt.Sprintf("/admin/custom_collections/%d.json", obj.ID)
-	method := "PUT"
-	expectedStatus := 201
-
-	if obj.ID == 0 {
-		endpoint = fmt.Sprintf("/admin/custom_collections.json")
-		method = "POST"
-		expectedStatus = 201
-	}
-
-	body := map[string]*CustomCollection{}
-	body["custom_collection"] = obj
+	return customCollectionResource.save(obj.api, obj.ID, obj, nil)
+}
 
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
+// Products returns the products of the collection, in its sort order.
+func (obj *CustomCollection) Products() ([]*Product, error) {
+	return obj.api.collectionProducts(obj.ID)
+}
 
+// SetProducts makes the products of productIDs the members of the
+// collection, creating and deleting only the collects which differ. It
+// returns the products added and removed, which on error are the changes
+// made before it.
+func (obj *CustomCollection) SetProducts(productIDs []int64) (added, removed []int64, err error) {
+	collects, err := obj.api.allCollects(CollectOptions{CollectionID: obj.ID})
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
+	current := map[int64]bool{}
+	for _, collect := range collects {
+		current[collect.ProductId] = true
 	}
 
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
+	wanted := map[int64]bool{}
+	for _, id := range productIDs {
+		if wanted[id] {
+			continue
+		}
+		wanted[id] = true
+		if current[id] {
+			continue
+		}
+		collect := obj.api.NewCollect()
+		collect.CollectionId = obj.ID
+		collect.ProductId = id
+		if err := collect.Save(); err != nil {
+			return added, removed, err
+		}
+		added = append(added, id)
 	}
 
-	r := map[string]CustomCollection{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	if err != nil {
-		return err
+	for _, collect := range collects {
+		if wanted[collect.ProductId] {
+			continue
+		}
+		if err := collect.Delete(); err != nil {
+			return added, removed, err
+		}
+		removed = append(removed, collect.ProductId)
 	}
-
-	*obj = r["custom_collection"]
-
-	return nil
+	return added, removed, nil
 }
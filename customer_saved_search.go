@@ -1,12 +1,6 @@
 package shopify
 
 import (
-	"bytes"
-
-	"encoding/json"
-
-	"fmt"
-
 	"time"
 )
 
@@ -24,58 +18,16 @@ type CustomerSavedSearch struct {
 	api *API
 }
 
-func (api *API) CustomerSavedSearches() ([]CustomerSavedSearch, error) {
-	res, status, err := api.request("/admin/customer_saved_searches.json", "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := &map[string][]CustomerSavedSearch{}
-	err = json.NewDecoder(res).Decode(r)
+var customerSavedSearchResource = newRESTResource[CustomerSavedSearch]("/admin/customer_saved_searches", "customer_saved_search", "customer_saved_searches")
 
-	result := (*r)["customer_saved_searches"]
+func (obj *CustomerSavedSearch) setAPI(api *API) { obj.api = api }
 
-	if err != nil {
-		return nil, err
-	}
-
-	for _, v := range result {
-		v.api = api
-	}
-
-	return result, nil
+func (api *API) CustomerSavedSearches() ([]CustomerSavedSearch, error) {
+	return customerSavedSearchResource.listValues(api, nil)
 }
 
 func (api *API) CustomerSavedSearch(id int64) (*CustomerSavedSearch, error) {
-	endpoint := fmt.Sprintf("/admin/customer_saved_searches/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]CustomerSavedSearch{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["customer_saved_search"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+	return customerSavedSearchResource.get(api, id, nil)
 }
 
 func (api *API) NewCustomerSavedSearch() *CustomerSavedSearch {
@@ -83,42 +35,5 @@ func (api *API) NewCustomerSavedSearch() *CustomerSavedSearch {
 }
 
 func (obj *CustomerSavedSearch) Save() error {
-	endpoint := fmt.Sprintf("/admin/customer_saved_searches/%d.json", obj.Id)
-	method := "PUT"
-	expectedStatus := 201
-
-	if obj.Id == 0 {
-		endpoint = fmt.Sprintf("/admin/customer_saved_searches.json")
-		method = "POST"
-		expectedStatus = 201
-	}
-
-	body := map[string]*CustomerSavedSearch{}
-	body["customer_saved_search"] = obj
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
-
-	if err != nil {
-		return err
-	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	r := map[string]CustomerSavedSearch{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return customerSavedSearchResource.save(obj.api, obj.Id, obj, nil)
 }
@@ -0,0 +1,30 @@
+// Code generated by shopify-gen from schema/resources.json. DO NOT EDIT.
+
+package shopify
+
+// DiscountCode is a discount code applied to an order or checkout.
+type DiscountCode struct {
+	Amount string `json:"amount"`
+	Code   string `json:"code"`
+	Type   string `json:"type"`
+}
+
+// DiscountApplication records how a discount was applied to an order.
+type DiscountApplication struct {
+	AllocationMethod string `json:"allocation_method"`
+	Code             string `json:"code"`
+	Description      string `json:"description"`
+	TargetSelection  string `json:"target_selection"`
+	TargetType       string `json:"target_type"`
+	Title            string `json:"title"`
+	Type             string `json:"type"`
+	Value            string `json:"value"`
+	ValueType        string `json:"value_type"`
+}
+
+// DiscountAllocation is the part of a discount application allocated to a line.
+type DiscountAllocation struct {
+	Amount                   string    `json:"amount"`
+	AmountSet                *PriceSet `json:"amount_set,omitempty"`
+	DiscountApplicationIndex int64     `json:"discount_application_index"`
+}
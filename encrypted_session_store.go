@@ -0,0 +1,159 @@
+package shopify
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrSessionDecrypt is returned by EncryptedSessionStore when a stored access
+// token cannot be decrypted, either because it was tampered with or because
+// it was encrypted with a key that is no longer configured.
+var ErrSessionDecrypt = errors.New("unable to decrypt session access token")
+
+const encryptedTokenPrefix = "enc:v1:"
+
+// EncryptionKey is an AES key used by EncryptedSessionStore. Key must be 16,
+// 24 or 32 bytes long. ID is stored alongside each encrypted token so that the
+// right key can be found after rotation, and must not contain ':'.
+type EncryptionKey struct {
+	ID  string
+	Key []byte
+}
+
+// EncryptedSessionStore wraps a SessionStore and encrypts access tokens with
+// AES-GCM before they reach it. Tokens are bound to their shop and user, so an
+// encrypted token copied to another session fails to decrypt.
+type EncryptedSessionStore struct {
+	store   SessionStore
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewEncryptedSessionStore returns a store that encrypts tokens with primary
+// and can still decrypt tokens encrypted with any of the old keys. Sessions
+// encrypted with an old key are re-encrypted with primary when loaded.
+func NewEncryptedSessionStore(store SessionStore, primary EncryptionKey, old ...EncryptionKey) (*EncryptedSessionStore, error) {
+	e := &EncryptedSessionStore{
+		store:   store,
+		primary: primary.ID,
+		aeads:   map[string]cipher.AEAD{},
+	}
+
+	for _, key := range append([]EncryptionKey{primary}, old...) {
+		if key.ID == "" || strings.Contains(key.ID, ":") {
+			return nil, fmt.Errorf("invalid encryption key ID %q", key.ID)
+		}
+		if _, ok := e.aeads[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate encryption key ID %q", key.ID)
+		}
+		block, err := aes.NewCipher(key.Key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %v", key.ID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		e.aeads[key.ID] = aead
+	}
+
+	return e, nil
+}
+
+func sessionAdditionalData(shop string, userID int64) []byte {
+	return []byte(fmt.Sprintf("%s|%d", shop, userID))
+}
+
+func (e *EncryptedSessionStore) encrypt(session *Session) (string, error) {
+	aead := e.aeads[e.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(session.AccessToken), sessionAdditionalData(session.Shop, session.UserID))
+	return encryptedTokenPrefix + e.primary + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (e *EncryptedSessionStore) decrypt(session *Session) (token string, keyID string, err error) {
+	if !strings.HasPrefix(session.AccessToken, encryptedTokenPrefix) {
+		return "", "", ErrSessionDecrypt
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(session.AccessToken, encryptedTokenPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", "", ErrSessionDecrypt
+	}
+	keyID = parts[0]
+
+	aead, ok := e.aeads[keyID]
+	if !ok {
+		return "", "", ErrSessionDecrypt
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", "", ErrSessionDecrypt
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, sessionAdditionalData(session.Shop, session.UserID))
+	if err != nil {
+		return "", "", ErrSessionDecrypt
+	}
+
+	return string(plaintext), keyID, nil
+}
+
+func (e *EncryptedSessionStore) SaveSession(session *Session) error {
+	encrypted, err := e.encrypt(session)
+	if err != nil {
+		return err
+	}
+
+	stored := *session
+	stored.AccessToken = encrypted
+	return e.store.SaveSession(&stored)
+}
+
+func (e *EncryptedSessionStore) LoadSession(shop string, userID int64) (*Session, error) {
+	session, err := e.store.LoadSession(shop, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, keyID, err := e.decrypt(session)
+	if err != nil {
+		return nil, err
+	}
+	session.AccessToken = token
+
+	if keyID != e.primary {
+		if err = e.SaveSession(session); err != nil {
+			return nil, err
+		}
+	}
+
+	return session, nil
+}
+
+func (e *EncryptedSessionStore) DeleteSession(shop string, userID int64) error {
+	return e.store.DeleteSession(shop, userID)
+}
+
+func (e *EncryptedSessionStore) DeleteShopSessions(shop string) error {
+	return e.store.DeleteShopSessions(shop)
+}
+
+// Reencrypt re-encrypts a stored session with the primary key. Use it to
+// migrate every known session after rotating keys, so the old keys can be
+// removed.
+func (e *EncryptedSessionStore) Reencrypt(shop string, userID int64) error {
+	_, err := e.LoadSession(shop, userID)
+	return err
+}
@@ -0,0 +1,119 @@
+package shopify
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var (
+	testKeyOld = EncryptionKey{ID: "2023", Key: bytes.Repeat([]byte{1}, 32)}
+	testKeyNew = EncryptionKey{ID: "2024", Key: bytes.Repeat([]byte{2}, 32)}
+)
+
+func TestEncryptedSessionStore(t *testing.T) {
+	backend := NewMemorySessionStore()
+	store, err := NewEncryptedSessionStore(backend, testKeyNew)
+	if err != nil {
+		t.Fatalf("Error creating encrypted store: %v", err)
+	}
+
+	testSessionStore(t, store)
+
+	store.SaveSession(&Session{Shop: "burnsmod.myshopify.com", AccessToken: "shpat_secret"})
+
+	raw, _ := backend.LoadSession("burnsmod.myshopify.com", 0)
+	if strings.Contains(raw.AccessToken, "shpat_secret") {
+		t.Errorf("Expected access token to be encrypted at rest, got %s", raw.AccessToken)
+	}
+}
+
+func TestEncryptedSessionStoreTamperDetection(t *testing.T) {
+	backend := NewMemorySessionStore()
+	store, _ := NewEncryptedSessionStore(backend, testKeyNew)
+
+	store.SaveSession(&Session{Shop: "burnsmod.myshopify.com", AccessToken: "shpat_secret"})
+	store.SaveSession(&Session{Shop: "other.myshopify.com", AccessToken: "shpat_other"})
+
+	// change a byte of the ciphertext
+	raw, _ := backend.LoadSession("burnsmod.myshopify.com", 0)
+	tampered := []byte(raw.AccessToken)
+	i := len(tampered) - 10
+	if tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+	raw.AccessToken = string(tampered)
+	backend.SaveSession(raw)
+
+	if _, err := store.LoadSession("burnsmod.myshopify.com", 0); err != ErrSessionDecrypt {
+		t.Errorf("Expected ErrSessionDecrypt for tampered ciphertext, got %v", err)
+	}
+
+	// copy another shop's encrypted token into this session
+	other, _ := backend.LoadSession("other.myshopify.com", 0)
+	other.Shop = "burnsmod.myshopify.com"
+	backend.SaveSession(other)
+
+	if _, err := store.LoadSession("burnsmod.myshopify.com", 0); err != ErrSessionDecrypt {
+		t.Errorf("Expected ErrSessionDecrypt for swapped ciphertext, got %v", err)
+	}
+
+	// plaintext tokens are rejected too
+	backend.SaveSession(&Session{Shop: "burnsmod.myshopify.com", AccessToken: "shpat_plain"})
+
+	if _, err := store.LoadSession("burnsmod.myshopify.com", 0); err != ErrSessionDecrypt {
+		t.Errorf("Expected ErrSessionDecrypt for plaintext token, got %v", err)
+	}
+}
+
+func TestEncryptedSessionStoreKeyRotation(t *testing.T) {
+	backend := NewMemorySessionStore()
+
+	oldStore, _ := NewEncryptedSessionStore(backend, testKeyOld)
+	oldStore.SaveSession(&Session{Shop: "burnsmod.myshopify.com", AccessToken: "shpat_secret"})
+
+	newStore, err := NewEncryptedSessionStore(backend, testKeyNew, testKeyOld)
+	if err != nil {
+		t.Fatalf("Error creating encrypted store: %v", err)
+	}
+
+	if err = newStore.Reencrypt("burnsmod.myshopify.com", 0); err != nil {
+		t.Fatalf("Error re-encrypting session: %v", err)
+	}
+
+	raw, _ := backend.LoadSession("burnsmod.myshopify.com", 0)
+	if !strings.HasPrefix(raw.AccessToken, "enc:v1:2024:") {
+		t.Errorf("Expected session to be re-encrypted with the new key, got %s", raw.AccessToken)
+	}
+
+	// the old key can now be dropped
+	rotated, _ := NewEncryptedSessionStore(backend, testKeyNew)
+	session, err := rotated.LoadSession("burnsmod.myshopify.com", 0)
+	if err != nil {
+		t.Fatalf("Error loading rotated session: %v", err)
+	}
+	if session.AccessToken != "shpat_secret" {
+		t.Errorf("Expected shpat_secret, got %s", session.AccessToken)
+	}
+
+	if _, err = oldStore.LoadSession("burnsmod.myshopify.com", 0); err != ErrSessionDecrypt {
+		t.Errorf("Expected ErrSessionDecrypt with only the old key, got %v", err)
+	}
+}
+
+func TestSecretsNotFormatted(t *testing.T) {
+	session := &Session{Shop: "burnsmod.myshopify.com", AccessToken: "shpat_secret"}
+	a := &API{Shop: "burnsmod.myshopify.com", AccessToken: "shpat_secret", Secret: "api_secret"}
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		for _, v := range []interface{}{session, *session, a} {
+			out := fmt.Sprintf(format, v)
+			if strings.Contains(out, "shpat_secret") || strings.Contains(out, "api_secret") {
+				t.Errorf("Expected secrets to be hidden with %s, got %s", format, out)
+			}
+		}
+	}
+}
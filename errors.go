@@ -0,0 +1,160 @@
+package shopify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sentinel errors matched with errors.Is against any error returned by the
+// API methods, e.g.
+//
+//	product, err := api.Product(id)
+//	if errors.Is(err, shopify.ErrNotFound) {
+//		...
+//	}
+var (
+	// ErrNotFound is matched by 404 Not Found responses.
+	ErrNotFound = errors.New("shopify: not found")
+	// ErrUnauthorized is matched by 401 Unauthorized and 403 Forbidden
+	// responses, e.g. an invalid access token or missing scope.
+	ErrUnauthorized = errors.New("shopify: unauthorized")
+	// ErrRateLimited is matched by 429 Too Many Requests responses. Use
+	// errors.As with a *RateLimitError to find out when to retry.
+	ErrRateLimited = errors.New("shopify: rate limited")
+)
+
+// Is makes ErrorResponse match the sentinel error for its status code.
+func (e *ErrorResponse) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// RateLimitError is returned when a request is still throttled once retries
+// are exhausted. It wraps the 429 ErrorResponse.
+type RateLimitError struct {
+	// RetryAfter is the delay requested by the Retry-After header, or zero
+	// if it wasn't sent.
+	RetryAfter time.Duration
+	Response   *ErrorResponse
+}
+
+func newRateLimitError(header http.Header, reqBody []byte, body *bytes.Buffer) error {
+	return &RateLimitError{
+		RetryAfter: parseRetryAfter(header.Get("Retry-After")),
+		Response:   decodeErrorResponse(http.StatusTooManyRequests, reqBody, body),
+	}
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited, retry after %s: %s", e.RetryAfter, e.Response.Error())
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Response
+}
+
+// Temporary always returns true, the request can be retried after RetryAfter.
+func (e *RateLimitError) Temporary() bool {
+	return true
+}
+
+// ValidationError is returned for 422 Unprocessable Entity responses. It
+// wraps the ErrorResponse. Validations done without a response, such as
+// MetafieldDefinition.Validate, or the user errors of GraphQL mutations, have
+// no Response.
+type ValidationError struct {
+	// Fields maps each invalid field to its error messages. Errors which
+	// aren't about a specific field are stored under "base".
+	Fields   map[string][]string
+	Response *ErrorResponse
+}
+
+func (e *ValidationError) Error() string {
+	fields := []string{}
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := []string{}
+	for _, field := range fields {
+		for _, message := range e.Fields[field] {
+			if field == "base" {
+				messages = append(messages, message)
+			} else {
+				messages = append(messages, field+" "+message)
+			}
+		}
+	}
+	return "validation failed: " + strings.Join(messages, ", ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	if e.Response == nil {
+		return nil
+	}
+	return e.Response
+}
+
+// parseValidationErrors converts the errors field of a 422 response, which
+// can be a map of field names to messages, a list of messages or a single
+// message.
+func parseValidationErrors(errs interface{}) map[string][]string {
+	fields := map[string][]string{}
+
+	messages := func(v interface{}) []string {
+		switch m := v.(type) {
+		case []interface{}:
+			result := []string{}
+			for _, s := range m {
+				result = append(result, fmt.Sprint(s))
+			}
+			return result
+		case nil:
+			return nil
+		default:
+			return []string{fmt.Sprint(m)}
+		}
+	}
+
+	switch e := errs.(type) {
+	case map[string]interface{}:
+		for field, v := range e {
+			fields[field] = messages(v)
+		}
+	case nil:
+	default:
+		fields["base"] = messages(e)
+	}
+	return fields
+}
+
+// parseRetryAfter parses a Retry-After header, which Shopify sends as a
+// number of seconds such as "2.0". HTTP dates are accepted too.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
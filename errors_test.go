@@ -0,0 +1,146 @@
+package shopify
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func stubAPI(t *testing.T, status int, header http.Header, body string) *API {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return &API{Shop: "example.myshopify.com", BaseURL: server.URL}
+}
+
+func TestErrorSentinels(t *testing.T) {
+	tests := []struct {
+		status   int
+		sentinel error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrUnauthorized},
+	}
+
+	for _, test := range tests {
+		api := stubAPI(t, test.status, nil, `{"errors":"Not allowed"}`)
+
+		_, err := api.Product(1)
+		if !errors.Is(err, test.sentinel) {
+			t.Errorf("%d: expected %v, got %v", test.status, test.sentinel, err)
+		}
+		var errResp *ErrorResponse
+		if !errors.As(err, &errResp) || errResp.StatusCode != test.status {
+			t.Errorf("%d: expected *ErrorResponse, got %v", test.status, err)
+		}
+		if errors.Is(err, ErrRateLimited) {
+			t.Errorf("%d: didn't expect %v to match ErrRateLimited", test.status, err)
+		}
+	}
+}
+
+func TestErrorsFromEveryMethod(t *testing.T) {
+	api := stubAPI(t, http.StatusNotFound, nil, `{"errors":"Not Found"}`)
+
+	calls := map[string]func() error{
+		"Articles":      func() error { _, err := api.Articles(); return err },
+		"Collects":      func() error { _, err := api.Collects(); return err },
+		"Countries":     func() error { _, err := api.Countries(); return err },
+		"CurrentShop":   func() error { _, err := api.CurrentShop(); return err },
+		"Customer":      func() error { _, err := api.Customer(1); return err },
+		"Order":         func() error { _, err := api.Order(1); return err },
+		"ProductsCount": func() error { _, err := api.ProductsCount(nil); return err },
+		"Webhooks":      func() error { _, err := api.Webhooks(); return err },
+	}
+
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", name, err)
+		}
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	tests := []struct {
+		body     string
+		fields   map[string][]string
+		expected string
+	}{
+		{
+			`{"errors":{"title":["can't be blank"],"handle":["is taken","is too long"]}}`,
+			map[string][]string{"title": {"can't be blank"}, "handle": {"is taken", "is too long"}},
+			"validation failed: handle is taken, handle is too long, title can't be blank",
+		},
+		{
+			`{"errors":["Line items are required"]}`,
+			map[string][]string{"base": {"Line items are required"}},
+			"validation failed: Line items are required",
+		},
+		{
+			`{"errors":"Invalid shop"}`,
+			map[string][]string{"base": {"Invalid shop"}},
+			"validation failed: Invalid shop",
+		},
+	}
+
+	for _, test := range tests {
+		api := stubAPI(t, http.StatusUnprocessableEntity, nil, test.body)
+
+		err := api.NewProduct().Save(nil)
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("%s: expected *ValidationError, got %v", test.body, err)
+		}
+		if !reflect.DeepEqual(validationErr.Fields, test.fields) {
+			t.Errorf("%s: expected fields %v, got %v", test.body, test.fields, validationErr.Fields)
+		}
+		if err.Error() != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.body, test.expected, err.Error())
+		}
+		var errResp *ErrorResponse
+		if !errors.As(err, &errResp) || len(errResp.ReqBody) == 0 {
+			t.Errorf("%s: expected wrapped *ErrorResponse with request body, got %v", test.body, err)
+		}
+	}
+}
+
+func TestRateLimitError(t *testing.T) {
+	api := stubAPI(t, http.StatusTooManyRequests, http.Header{"Retry-After": {"2.0"}}, `{"errors":"Exceeded 2 calls per second for api client. Reduce request rates to resume uninterrupted service."}`)
+	api.RetryPolicy = NoRetry
+
+	_, err := api.CurrentShop()
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		t.Fatalf("Expected *RateLimitError, got %v", err)
+	}
+	if rateLimitErr.RetryAfter != 2*time.Second {
+		t.Errorf("Expected RetryAfter 2s, got %s", rateLimitErr.RetryAfter)
+	}
+	if !errors.Is(err, ErrRateLimited) || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected only ErrRateLimited to match %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":      0,
+		"2.0":   2 * time.Second,
+		"0.5":   500 * time.Millisecond,
+		"10":    10 * time.Second,
+		"bogus": 0,
+	}
+	for value, expected := range tests {
+		if d := parseRetryAfter(value); d != expected {
+			t.Errorf("%q: expected %s, got %s", value, expected, d)
+		}
+	}
+}
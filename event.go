@@ -1,10 +1,6 @@
 package shopify
 
 import (
-	"encoding/json"
-
-	"fmt"
-
 	"time"
 )
 
@@ -34,56 +30,14 @@ type Event struct {
 	api *API
 }
 
-func (api *API) Events() ([]Event, error) {
-	res, status, err := api.request("/admin/events.json", "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
+var eventResource = newRESTResource[Event]("/admin/events", "event", "events")
 
-	r := &map[string][]Event{}
-	err = json.NewDecoder(res).Decode(r)
+func (obj *Event) setAPI(api *API) { obj.api = api }
 
-	result := (*r)["events"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	for _, v := range result {
-		v.api = api
-	}
-
-	return result, nil
+func (api *API) Events() ([]Event, error) {
+	return eventResource.listValues(api, nil)
 }
 
 func (api *API) Event(id int64) (*Event, error) {
-	endpoint := fmt.Sprintf("/admin/events/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]Event{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["event"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+	return eventResource.get(api, id, nil)
 }
@@ -16,7 +16,7 @@ var app *shopify.App
 // Change these to actual secret keys before use
 var store = sessions.NewCookieStore([]byte("this-is-a-dummy-authentication-key32"), []byte("this-is-a-dummy-encryption-key32"))
 
-var tokens map[string]string
+var shopSessions shopify.SessionStore
 
 // set Callback URL to http://localhost:4000/installed
 
@@ -46,7 +46,7 @@ func init() {
 		APISecret:   secret,
 	}
 
-	tokens = map[string]string{}
+	shopSessions = shopify.NewMemorySessionStore()
 }
 
 func getSession(r *http.Request) *sessions.Session {
@@ -85,15 +85,20 @@ func serveInstall(w http.ResponseWriter, r *http.Request) {
 		}
 
 		shop := params["shop"][0]
-		token, _ := app.AccessToken(shop, params["code"][0])
+		token, err := app.RequestAccessToken(shop, params["code"][0])
+		if err != nil {
+			http.Error(w, "Error obtaining access token", 500)
+			log.Printf("Error obtaining access token: %s", err)
+			return
+		}
 
 		// persist this token
-		tokens[shop] = token
+		shopSessions.SaveSession(shopify.NewSession(shop, token))
 
 		// log in user
 		session := getSession(r)
 		session.Values["current_shop"] = shop
-		err := session.Save(r, w)
+		err = session.Save(r, w)
 		if err != nil {
 			panic(err)
 		}
@@ -139,7 +144,7 @@ func serveAdmin(w http.ResponseWriter, r *http.Request) {
 	shop, _ := session.Values["current_shop"].(string)
 
 	// if we don't have an access token for the shop, obtain one now.
-	if _, ok := tokens[shop]; !ok {
+	if _, err := shopSessions.LoadSession(shop, 0); err != nil {
 		http.Redirect(w, r, app.AuthorizeURL(shop, "read_themes,write_themes"), 302)
 		return
 	}
@@ -159,6 +164,7 @@ func main() {
 	http.HandleFunc("/install", serveInstall)
 	http.HandleFunc("/admin", serveAdmin)
 	http.HandleFunc("/app_proxy/", serveAppProxy)
+	http.Handle("/webhooks/app_uninstalled", app.UninstallHandler(shopSessions))
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "static/home.html")
@@ -0,0 +1,72 @@
+package shopify
+
+// FulfillmentService is a third-party warehouse which prepares and ships
+// orders, such as a 3PL. Creating one creates a legacy Location, identified by
+// LocationID, at which its inventory is stocked.
+type FulfillmentService struct {
+	Id     int64  `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Handle string `json:"handle,omitempty"`
+	Email  string `json:"email,omitempty"`
+	// CallbackURL is where Shopify sends fulfillment requests, and asks for
+	// stock levels and tracking numbers.
+	CallbackURL string `json:"callback_url,omitempty"`
+	// InventoryManagement is true if Shopify should ask the service for
+	// stock levels, and TrackingSupport if it should ask for tracking
+	// numbers.
+	InventoryManagement    bool   `json:"inventory_management"`
+	TrackingSupport        bool   `json:"tracking_support"`
+	RequiresShippingMethod bool   `json:"requires_shipping_method"`
+	IncludePendingStock    bool   `json:"include_pending_stock"`
+	PermitsSkuSharing      bool   `json:"permits_sku_sharing"`
+	FulfillmentOrdersOptIn bool   `json:"fulfillment_orders_opt_in"`
+	Format                 string `json:"format,omitempty"`
+	ServiceName            string `json:"service_name,omitempty"`
+	ProviderID             *int64 `json:"provider_id,omitempty"`
+	LocationID             int64  `json:"location_id,omitempty"`
+	AdminGraphqlAPIID      string `json:"admin_graphql_api_id,omitempty"`
+
+	api *API
+}
+
+// FulfillmentServicesOptions selects the fulfillment services listed: by
+// default those of the calling app, or every one with Scope "all".
+type FulfillmentServicesOptions struct {
+	Scope string `url:"scope,omitempty"`
+}
+
+var fulfillmentServiceResource = newRESTResource[FulfillmentService]("/admin/fulfillment_services", "fulfillment_service", "fulfillment_services")
+
+func (obj *FulfillmentService) setAPI(api *API) { obj.api = api }
+
+// FulfillmentServices returns the fulfillment services selected by options.
+func (api *API) FulfillmentServices(options *FulfillmentServicesOptions) ([]*FulfillmentService, error) {
+	return fulfillmentServiceResource.list(api, options)
+}
+
+// FulfillmentService returns the fulfillment service with id.
+func (api *API) FulfillmentService(id int64) (*FulfillmentService, error) {
+	return fulfillmentServiceResource.get(api, id, nil)
+}
+
+// NewFulfillmentService returns a new fulfillment service, to be saved with
+// api.
+func (api *API) NewFulfillmentService() *FulfillmentService {
+	return &FulfillmentService{api: api}
+}
+
+// Save creates the fulfillment service if it has no id, and updates it
+// otherwise.
+func (obj *FulfillmentService) Save() error {
+	return fulfillmentServiceResource.save(obj.api, obj.Id, obj, nil)
+}
+
+// Delete deletes the fulfillment service, and with it its location.
+func (obj *FulfillmentService) Delete() error {
+	return fulfillmentServiceResource.delete(obj.api, obj.Id)
+}
+
+// Location returns the location of the fulfillment service.
+func (obj *FulfillmentService) Location() (*Location, error) {
+	return obj.api.Location(obj.LocationID)
+}
@@ -0,0 +1,152 @@
+package shopify
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFulfillmentServiceRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		call   func(api *API) error
+		method string
+		uri    string
+		// sent are fields expected in the request body, even if false
+		sent []string
+	}{
+		{
+			name: "list", status: 200, body: `{"fulfillment_services":[{"id":3,"name":"Mars Fulfillment"}]}`,
+			call: func(api *API) error {
+				services, err := api.FulfillmentServices(&FulfillmentServicesOptions{Scope: "all"})
+				if err == nil && (len(services) != 1 || services[0].Name != "Mars Fulfillment") {
+					return errors.New("expected the service to be decoded")
+				}
+				return err
+			},
+			method: "GET", uri: "/admin/fulfillment_services.json?scope=all",
+		},
+		{
+			name: "get", status: 200, body: `{"fulfillment_service":{"id":3,"location_id":9}}`,
+			call: func(api *API) error {
+				service, err := api.FulfillmentService(3)
+				if err == nil && service.LocationID != 9 {
+					return errors.New("expected the location id to be decoded")
+				}
+				return err
+			},
+			method: "GET", uri: "/admin/fulfillment_services/3.json",
+		},
+		{
+			name: "create", status: 201, body: `{"fulfillment_service":{"id":3,"location_id":9}}`,
+			call: func(api *API) error {
+				service := api.NewFulfillmentService()
+				service.Name = "Mars Fulfillment"
+				service.CallbackURL = "https://example.com/fulfillment"
+				service.TrackingSupport = true
+				if err := service.Save(); err != nil {
+					return err
+				}
+				if service.Id != 3 || service.LocationID != 9 {
+					return errors.New("expected the service to be replaced")
+				}
+				return nil
+			},
+			method: "POST", uri: "/admin/fulfillment_services.json",
+			sent: []string{"name", "callback_url", "tracking_support", "inventory_management", "fulfillment_orders_opt_in"},
+		},
+		{
+			name: "update", status: 200, body: `{"fulfillment_service":{"id":3}}`,
+			call: func(api *API) error {
+				service := &FulfillmentService{Id: 3, InventoryManagement: true, api: api}
+				return service.Save()
+			},
+			method: "PUT", uri: "/admin/fulfillment_services/3.json",
+			sent: []string{"id", "inventory_management"},
+		},
+		{
+			name: "delete", status: 200, body: `{}`,
+			call: func(api *API) error {
+				return (&FulfillmentService{Id: 3, api: api}).Delete()
+			},
+			method: "DELETE", uri: "/admin/fulfillment_services/3.json",
+		},
+		{
+			name: "location", status: 200, body: `{"location":{"id":9,"legacy":true}}`,
+			call: func(api *API) error {
+				location, err := (&FulfillmentService{Id: 3, LocationID: 9, api: api}).Location()
+				if err == nil && !location.Legacy {
+					return errors.New("expected a legacy location")
+				}
+				return err
+			},
+			method: "GET", uri: "/admin/locations/9.json",
+		},
+	}
+
+	for _, test := range tests {
+		api, recorded := resourceServer(t, test.status, test.body)
+
+		if err := test.call(api); err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if recorded.Method != test.method || recorded.URI != test.uri {
+			t.Errorf("%s: expected %s %s, got %s %s", test.name, test.method, test.uri, recorded.Method, recorded.URI)
+		}
+		sent, _ := recorded.Body["fulfillment_service"].(map[string]interface{})
+		for _, field := range test.sent {
+			if _, ok := sent[field]; !ok {
+				t.Errorf("%s: expected %s to be sent, got %v", test.name, field, sent)
+			}
+		}
+	}
+}
+
+func TestActiveLocations(t *testing.T) {
+	api, _ := resourceServer(t, 200, `{"locations":[
+		{"id":1,"active":true},
+		{"id":2,"active":false},
+		{"id":3,"active":true,"legacy":true}
+	]}`)
+
+	tests := map[bool][]int64{false: {1}, true: {1, 3}}
+	for legacy, expected := range tests {
+		locations, err := api.ActiveLocations(legacy)
+		if err != nil {
+			t.Fatalf("Error listing locations: %v", err)
+		}
+		ids := []int64{}
+		for _, location := range locations {
+			ids = append(ids, location.Id)
+		}
+		if !reflect.DeepEqual(ids, expected) {
+			t.Errorf("legacy %v: expected locations %v, got %v", legacy, expected, ids)
+		}
+	}
+}
+
+func TestFulfillmentServiceInvalidatesLocations(t *testing.T) {
+	api, recorded := resourceServer(t, 200, `{"locations":[{"id":9,"active":true,"legacy":true}]}`)
+	api.Cache = NewMemoryCache()
+	api.CacheTTLs = DefaultCacheTTLs()
+
+	if _, err := api.Locations(); err != nil {
+		t.Fatalf("Error listing locations: %v", err)
+	}
+	recorded.Method = ""
+	if _, err := api.Locations(); err != nil || recorded.Method != "" {
+		t.Fatalf("Expected locations to be cached, got %v and a %s request", err, recorded.Method)
+	}
+
+	// deleting the service deletes its location
+	if err := (&FulfillmentService{Id: 3, api: api}).Delete(); err != nil {
+		t.Fatalf("Error deleting fulfillment service: %v", err)
+	}
+	recorded.Method = ""
+	if _, err := api.Locations(); err != nil || recorded.Method != "GET" {
+		t.Errorf("Expected locations to be fetched again, got %v and a %q request", err, recorded.Method)
+	}
+}
@@ -0,0 +1,224 @@
+package shopify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// GraphQLError is an entry of the errors field of a GraphQL response.
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Code returns the error code found in the extensions, e.g. THROTTLED.
+func (e GraphQLError) Code() string {
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+// GraphQLErrors is returned by API.GraphQL when the response has errors.
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	messages := []string{}
+	for _, err := range e {
+		messages = append(messages, err.Message)
+	}
+	return "graphql: " + DefaultRedactor.RedactString(strings.Join(messages, "; "))
+}
+
+// Is makes throttled queries match ErrRateLimited, and queries denied access
+// match ErrUnauthorized.
+func (e GraphQLErrors) Is(target error) bool {
+	for _, err := range e {
+		switch err.Code() {
+		case "THROTTLED":
+			if target == ErrRateLimited {
+				return true
+			}
+		case "ACCESS_DENIED":
+			if target == ErrUnauthorized {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (api *API) graphQLEndpoint() string {
+	if api.Version == "" {
+		return "/admin/api/graphql.json"
+	}
+	return fmt.Sprintf("/admin/api/%s/graphql.json", api.Version)
+}
+
+// GraphQL runs a query or mutation against the Admin GraphQL API, decoding
+// the data field of the response into out, which may be nil. Requests go
+// through the same retry policy and hooks as REST requests. Mutations may
+// change any resource, so they invalidate every cached REST response.
+func (api *API) GraphQL(query string, variables map[string]interface{}, out interface{}) error {
+	if api.Cache != nil && strings.HasPrefix(strings.TrimSpace(query), "mutation") {
+		defer api.invalidateCachedResources()
+	}
+
+	body := map[string]interface{}{
+		"query": query,
+	}
+	if len(variables) > 0 {
+		body["variables"] = variables
+	}
+
+	buf := &bytes.Buffer{}
+	err := json.NewEncoder(buf).Encode(body)
+
+	if err != nil {
+		return err
+	}
+
+	reqBody := buf.Bytes()
+	res, status, err := api.request(api.graphQLEndpoint(), "POST", nil, buf)
+
+	if err != nil {
+		return err
+	}
+
+	if status != 200 {
+		return newErrorResponse(status, reqBody, res)
+	}
+
+	r := struct {
+		Data   json.RawMessage `json:"data"`
+		Errors GraphQLErrors   `json:"errors"`
+	}{}
+	err = json.NewDecoder(res).Decode(&r)
+
+	if err != nil {
+		return err
+	}
+
+	if len(r.Errors) > 0 {
+		return r.Errors
+	}
+
+	if out == nil || len(r.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.Data, out)
+}
+
+// UserError is an entry of the userErrors field of a mutation's payload,
+// reporting invalid input.
+type UserError struct {
+	// Field is the path of the invalid input field, e.g. ["definition",
+	// "key"], or empty if the error isn't about a field.
+	Field   []string `json:"field"`
+	Message string   `json:"message"`
+	Code    string   `json:"code,omitempty"`
+}
+
+// UserErrors is returned by mutations whose payload has user errors. It
+// matches *ValidationError with errors.As, with the field paths joined by
+// dots, so that REST and GraphQL validation errors are handled alike.
+type UserErrors []UserError
+
+func (e UserErrors) Error() string {
+	return e.validationError().Error()
+}
+
+// As converts e to a *ValidationError.
+func (e UserErrors) As(target interface{}) bool {
+	if v, ok := target.(**ValidationError); ok {
+		*v = e.validationError()
+		return true
+	}
+	return false
+}
+
+func (e UserErrors) validationError() *ValidationError {
+	fields := map[string][]string{}
+	for _, err := range e {
+		field := strings.Join(err.Field, ".")
+		if field == "" {
+			field = "base"
+		}
+		fields[field] = append(fields[field], err.Message)
+	}
+	return &ValidationError{Fields: fields}
+}
+
+// mutate runs a mutation, decoding the payload in the name field of the
+// data into out, which may be nil. The user errors of the payload are
+// returned as UserErrors.
+func (api *API) mutate(mutation, name string, variables map[string]interface{}, out interface{}) error {
+	if api == nil {
+		return fmt.Errorf("graphql: %s has no API", name)
+	}
+	data := map[string]json.RawMessage{}
+	if err := api.GraphQL(mutation, variables, &data); err != nil {
+		return err
+	}
+	payload := data[name]
+	if len(payload) == 0 || string(payload) == "null" {
+		return fmt.Errorf("graphql: %s returned no payload", name)
+	}
+
+	errs := struct {
+		UserErrors UserErrors `json:"userErrors"`
+	}{}
+	if err := json.Unmarshal(payload, &errs); err != nil {
+		return err
+	}
+	if len(errs.UserErrors) > 0 {
+		return errs.UserErrors
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(payload, out)
+}
+
+// graphQLConnection is a page of the nodes of a connection.
+type graphQLConnection[T any] struct {
+	Nodes    []*T `json:"nodes"`
+	PageInfo struct {
+		HasNextPage bool   `json:"hasNextPage"`
+		EndCursor   string `json:"endCursor"`
+	} `json:"pageInfo"`
+}
+
+// graphQLNodes runs a query selecting a connection in the field of its data,
+// and returns the nodes of every page. The query takes the cursor of the
+// next page in the $after variable.
+func graphQLNodes[T any, PT resourcePtr[T]](api *API, query, field string, variables map[string]interface{}) ([]*T, error) {
+	if api == nil {
+		return nil, fmt.Errorf("graphql: %s has no API", field)
+	}
+	vars := map[string]interface{}{}
+	for k, v := range variables {
+		vars[k] = v
+	}
+
+	result := []*T{}
+	for {
+		data := map[string]*graphQLConnection[T]{}
+		if err := api.GraphQL(query, vars, &data); err != nil {
+			return nil, err
+		}
+		page := data[field]
+		if page == nil {
+			return nil, fmt.Errorf("graphql: no %s in response", field)
+		}
+		for _, node := range page.Nodes {
+			PT(node).setAPI(api)
+		}
+		result = append(result, page.Nodes...)
+		if !page.PageInfo.HasNextPage {
+			return result, nil
+		}
+		vars["after"] = page.PageInfo.EndCursor
+	}
+}
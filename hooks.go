@@ -0,0 +1,130 @@
+package shopify
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RequestEvent describes one attempt at a request to the Shopify API, REST
+// or GraphQL. It is passed to the hooks registered with API.Use.
+type RequestEvent struct {
+	Shop     string
+	Method   string
+	Endpoint string
+	// Resource is the resource the endpoint belongs to, e.g. "products"
+	// for /admin/products/1/variants.json, or "graphql".
+	Resource string
+	// Body is the request body, or nil.
+	Body []byte
+	// Attempt is 1 for the first attempt, and goes up with every retry.
+	Attempt int
+	// First is the event of the first attempt, shared by every attempt at
+	// the same request.
+	First *RequestEvent
+	// Request is the HTTP request about to be sent. BeforeRequest hooks may
+	// modify it, e.g. to add headers.
+	Request *http.Request
+
+	// The fields below are set once a response is received.
+
+	StatusCode     int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+	// Duration is the time spent waiting for the response.
+	Duration time.Duration
+	// CallsMade and CallLimit are the state of the REST call-limit bucket
+	// after the response.
+	CallsMade int
+	CallLimit int
+}
+
+// Hook is a set of callbacks run around every attempt at a request. Any of
+// them may be nil.
+type Hook struct {
+	// BeforeRequest is called before the request is sent. Returning an
+	// error aborts the request, which fails with that error.
+	BeforeRequest func(event *RequestEvent) error
+	// AfterResponse is called for every response received, whatever its
+	// status code.
+	AfterResponse func(event *RequestEvent)
+	// OnError is called when an attempt fails: because of a network error,
+	// an error returned by BeforeRequest, or a 4xx or 5xx response, for
+	// which err is the same typed error the API methods return.
+	OnError func(event *RequestEvent, err error)
+	// AfterRequest is called once a request is done, after its last
+	// attempt, with the event of that attempt and the error the request
+	// fails with, if any.
+	AfterRequest func(event *RequestEvent, err error)
+}
+
+// Use registers hooks, which are run in the order they were registered.
+// It must not be called concurrently with requests.
+func (api *API) Use(hooks ...Hook) {
+	api.hooks = append(api.hooks, hooks...)
+}
+
+// resourceFromEndpoint returns the first path segment after /admin, or after
+// /admin/api/<version>, without the .json extension.
+func resourceFromEndpoint(endpoint string) string {
+	path := strings.TrimPrefix(endpoint, "/admin/")
+	if strings.HasPrefix(path, "api/") {
+		path = strings.TrimPrefix(path, "api/")
+		if i := strings.Index(path, "/"); i >= 0 && path[:i] != "graphql.json" {
+			path = path[i+1:]
+		}
+	}
+	if i := strings.IndexAny(path, "/?"); i >= 0 {
+		path = path[:i]
+	}
+	return strings.TrimSuffix(path, ".json")
+}
+
+func (api *API) runHooks(event *RequestEvent, err error) {
+	if len(api.hooks) == 0 {
+		return
+	}
+	if err == nil {
+		for _, hook := range api.hooks {
+			if hook.AfterResponse != nil {
+				hook.AfterResponse(event)
+			}
+		}
+		if err = eventError(event); err == nil {
+			return
+		}
+	}
+
+	for _, hook := range api.hooks {
+		if hook.OnError != nil {
+			hook.OnError(event, err)
+		}
+	}
+}
+
+// runAfterRequest runs the AfterRequest hooks for the last attempt at a
+// request.
+func (api *API) runAfterRequest(event *RequestEvent, err error) {
+	if err == nil && event.StatusCode >= 400 {
+		err = eventError(event)
+	}
+	for _, hook := range api.hooks {
+		if hook.AfterRequest != nil {
+			hook.AfterRequest(event, err)
+		}
+	}
+}
+
+// eventError returns the typed error for the response of event, or nil if
+// it succeeded.
+func eventError(event *RequestEvent) error {
+	switch {
+	case event.StatusCode < 400:
+		return nil
+	case event.StatusCode == http.StatusTooManyRequests:
+		return newRateLimitError(event.ResponseHeader, event.Body, bytes.NewBuffer(event.ResponseBody))
+	default:
+		return newErrorResponse(event.StatusCode, event.Body, bytes.NewBuffer(event.ResponseBody))
+	}
+}
@@ -0,0 +1,165 @@
+package shopify
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func hooksServer(t *testing.T) *API {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Shopify-Shop-Api-Call-Limit", "3/40")
+		switch r.URL.Path {
+		case "/admin/shop.json":
+			if r.Header.Get("X-Audit-Id") != "audit-1" {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			w.Write([]byte(`{"shop":{"id":1}}`))
+		case "/admin/api/2024-01/graphql.json":
+			body, _ := io.ReadAll(r.Body)
+			var req struct {
+				Query string `json:"query"`
+			}
+			json.Unmarshal(body, &req)
+			if req.Query == "{ shop { name } }" {
+				w.Write([]byte(`{"data":{"shop":{"name":"Snowdevil"}}}`))
+			} else {
+				w.Write([]byte(`{"errors":[{"message":"Throttled","extensions":{"code":"THROTTLED"}}]}`))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":"Not Found"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return &API{Shop: "example.myshopify.com", BaseURL: server.URL, Version: "2024-01", RetryPolicy: NoRetry}
+}
+
+func TestHooks(t *testing.T) {
+	api := hooksServer(t)
+
+	calls := []string{}
+	var after *RequestEvent
+	var hookErr error
+	api.Use(Hook{
+		BeforeRequest: func(event *RequestEvent) error {
+			calls = append(calls, "before "+event.Method+" "+event.Endpoint)
+			event.Request.Header.Set("X-Audit-Id", "audit-1")
+			return nil
+		},
+		AfterResponse: func(event *RequestEvent) {
+			calls = append(calls, "after")
+			after = event
+		},
+	}, Hook{
+		OnError: func(event *RequestEvent, err error) {
+			calls = append(calls, "error")
+			hookErr = err
+		},
+		AfterRequest: func(event *RequestEvent, err error) {
+			calls = append(calls, "done")
+			if event.First != event || (err != nil) != (event.StatusCode >= 400) {
+				t.Errorf("Unexpected last attempt %#v (%v)", event, err)
+			}
+		},
+	})
+
+	if _, err := api.CurrentShop(); err != nil {
+		t.Fatalf("Error fetching shop: %v", err)
+	}
+	if after.Shop != "example.myshopify.com" || after.Resource != "shop" || after.StatusCode != 200 || after.CallsMade != 3 || after.CallLimit != 40 || after.Duration <= 0 || string(after.ResponseBody) != `{"shop":{"id":1}}` {
+		t.Errorf("Unexpected event %#v", after)
+	}
+
+	if _, err := api.Product(1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected not found error, got %v", err)
+	}
+	if !errors.Is(hookErr, ErrNotFound) {
+		t.Errorf("Expected OnError to receive a not found error, got %v", hookErr)
+	}
+
+	expected := []string{"before GET /admin/shop.json", "after", "done", "before GET /admin/products/1.json", "after", "error", "done"}
+	if len(calls) != len(expected) {
+		t.Fatalf("Expected calls %v, got %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("Expected calls %v, got %v", expected, calls)
+			break
+		}
+	}
+}
+
+func TestBeforeRequestAborts(t *testing.T) {
+	api := hooksServer(t)
+	abort := errors.New("read only")
+	var hookErr error
+	api.Use(Hook{
+		BeforeRequest: func(event *RequestEvent) error {
+			if event.Method != "GET" {
+				return abort
+			}
+			return nil
+		},
+		OnError: func(event *RequestEvent, err error) {
+			hookErr = err
+		},
+	})
+
+	if err := api.NewWebhook().Save(nil); err != abort {
+		t.Errorf("Expected request to be aborted, got %v", err)
+	}
+	if hookErr != abort {
+		t.Errorf("Expected OnError to receive the abort error, got %v", hookErr)
+	}
+}
+
+func TestGraphQLHooks(t *testing.T) {
+	api := hooksServer(t)
+	events := []*RequestEvent{}
+	api.Use(Hook{
+		AfterResponse: func(event *RequestEvent) {
+			events = append(events, event)
+		},
+	})
+
+	var data struct {
+		Shop struct {
+			Name string `json:"name"`
+		} `json:"shop"`
+	}
+	if err := api.GraphQL("{ shop { name } }", nil, &data); err != nil {
+		t.Fatalf("Error running query: %v", err)
+	}
+	if data.Shop.Name != "Snowdevil" {
+		t.Errorf("Unexpected data %#v", data)
+	}
+
+	err := api.GraphQL("{ products(first: 250) { nodes { id } } }", nil, nil)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected throttled error, got %v", err)
+	}
+
+	if len(events) != 2 || events[0].Method != "POST" || events[0].Endpoint != "/admin/api/2024-01/graphql.json" {
+		t.Errorf("Expected hooks to run for GraphQL requests, got %#v", events)
+	}
+}
+
+func TestResourceFromEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"/admin/shop.json":                         "shop",
+		"/admin/products/1/variants.json":          "products",
+		"/admin/products.json?limit=5":             "products",
+		"/admin/api/2024-01/inventory_levels.json": "inventory_levels",
+		"/admin/api/graphql.json":                  "graphql",
+		"/admin/api/2024-01/graphql.json":          "graphql",
+	}
+	for endpoint, expected := range tests {
+		if resource := resourceFromEndpoint(endpoint); resource != expected {
+			t.Errorf("%s: expected %s, got %s", endpoint, expected, resource)
+		}
+	}
+}
@@ -1,114 +1,64 @@
 package shopify
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
 	"time"
 )
 
 // InventoryItem a struct to represent Shopify's inventory_item.
+//
+// The writable fields are pointers, so that Update leaves them alone when
+// nil, and clears the string ones when set to "".
 type InventoryItem struct {
-	ID        int64     `json:"id"`
-	Sku       string    `json:"sku"`
-	Tracked   bool      `json:"tracked"`
-	CreatedAt time.Time `json:"created_at,omitempty"`
-	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	ID                           int64                         `json:"id"`
+	Sku                          *string                       `json:"sku,omitempty"`
+	Tracked                      *bool                         `json:"tracked,omitempty"`
+	RequiresShipping             *bool                         `json:"requires_shipping,omitempty"`
+	Cost                         *string                       `json:"cost,omitempty"`
+	CountryCodeOfOrigin          *string                       `json:"country_code_of_origin,omitempty"`
+	ProvinceCodeOfOrigin         *string                       `json:"province_code_of_origin,omitempty"`
+	HarmonizedSystemCode         *string                       `json:"harmonized_system_code,omitempty"`
+	CountryHarmonizedSystemCodes []CountryHarmonizedSystemCode `json:"country_harmonized_system_codes,omitempty"`
+	AdminGraphqlAPIID            string                        `json:"admin_graphql_api_id,omitempty"`
+	CreatedAt                    *time.Time                    `json:"created_at,omitempty"`
+	UpdatedAt                    *time.Time                    `json:"updated_at,omitempty"`
 
 	api *API
 }
 
-// InventoryItem Get one inventoryItem from api by inventory_item_id.
-func (api *API) InventoryItem(id int64) (*InventoryItem, error) {
-	endpoint := fmt.Sprintf("/admin/inventory_items/%d.json", id)
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
+// CountryHarmonizedSystemCode is the HS code of an inventory item when
+// shipping to a country.
+type CountryHarmonizedSystemCode struct {
+	HarmonizedSystemCode string `json:"harmonized_system_code"`
+	CountryCode          string `json:"country_code"`
+}
 
-	if status != 200 {
-		return nil, newErrorResponse(status, nil, res)
-	}
+// InventoryItemsOptions filters the inventory items listed. Shopify requires
+// IDs.
+type InventoryItemsOptions struct {
+	IDs   []int64 `url:"ids,comma,omitempty"`
+	Limit int     `url:"limit,omitempty"`
+}
 
-	r := &struct {
-		InventoryItem InventoryItem `json:"inventory_item"`
-	}{}
-	err = json.NewDecoder(res).Decode(r)
-	if err != nil {
-		return nil, err
-	}
+var inventoryItemResource = newRESTResource[InventoryItem]("/admin/inventory_items", "inventory_item", "inventory_items")
 
-	result := r.InventoryItem
-	result.api = api
+func (obj *InventoryItem) setAPI(api *API) { obj.api = api }
 
-	return &result, nil
+// InventoryItem Get one inventoryItem from api by inventory_item_id.
+func (api *API) InventoryItem(id int64) (*InventoryItem, error) {
+	return inventoryItemResource.get(api, id, nil)
 }
 
 // InventoryItems Get a list of inventoryItems from api, max 100 items.
 func (api *API) InventoryItems() ([]InventoryItem, error) {
-	res, status, err := api.request("/admin/inventory_items.json", "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, newErrorResponse(status, nil, res)
-	}
-
-	r := &struct {
-		InventoryItems []InventoryItem `json:"inventory_items"`
-	}{}
-	err = json.NewDecoder(res).Decode(r)
-
-	if err != nil {
-		return nil, err
-	}
-
-	for v := range r.InventoryItems {
-		r.InventoryItems[v].api = api
-	}
+	return inventoryItemResource.listValues(api, nil)
+}
 
-	return r.InventoryItems, nil
+// InventoryItemsWithOptions returns the inventory items matching options.
+func (api *API) InventoryItemsWithOptions(options *InventoryItemsOptions) ([]*InventoryItem, error) {
+	return inventoryItemResource.list(api, options)
 }
 
 //Update update an existing inventory item based on inventory_item_id
 func (obj *InventoryItem) Update() error {
-	endpoint := fmt.Sprintf("/admin/inventory_items/%d.json", obj.ID)
-	method := "PUT"
-	expectedStatus := 200
-
-	var buf bytes.Buffer
-	body := map[string]*InventoryItem{
-		"inventory_item": obj,
-	}
-	err := json.NewEncoder(&buf).Encode(body)
-	if err != nil {
-		return err
-	}
-	reqBody := buf.Bytes()
-
-	res, status, err := obj.api.request(endpoint, method, nil, &buf)
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, reqBody, res)
-	}
-
-	r := &struct {
-		InventoryItem InventoryItem `json:"inventory_item"`
-	}{}
-	err = json.NewDecoder(res).Decode(r)
-	if err != nil {
-		return err
-	}
-
-	api := obj.api
-	*obj = r.InventoryItem
-	obj.api = api
-
-	return nil
+	return inventoryItemResource.update(obj.api, obj.ID, obj, nil)
 }
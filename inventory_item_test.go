@@ -0,0 +1,32 @@
+package shopify
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestInventoryItemUpdateSendsSetFields(t *testing.T) {
+	api, recorded := resourceServer(t, 200, `{"inventory_item":{"id":3,"sku":"TS-1","cost":"4.50"}}`)
+
+	cost := "4.50"
+	item := &InventoryItem{ID: 3, Cost: &cost, api: api}
+	if err := item.Update(); err != nil {
+		t.Fatalf("Error updating inventory item: %v", err)
+	}
+
+	sent, _ := recorded.Body["inventory_item"].(map[string]interface{})
+	keys := []string{}
+	for key := range sent {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "cost" || keys[1] != "id" {
+		t.Errorf("Expected only the id and cost to be sent, got %v", sent)
+	}
+	if recorded.Method != "PUT" || recorded.URI != "/admin/inventory_items/3.json" {
+		t.Errorf("Unexpected request %s %s", recorded.Method, recorded.URI)
+	}
+	if item.Sku == nil || *item.Sku != "TS-1" {
+		t.Errorf("Expected the item to be replaced by the response, got %#v", item)
+	}
+}
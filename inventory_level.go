@@ -18,69 +18,102 @@ type InventoryLevel struct {
 	api *API
 }
 
+// InventoryLevelsOptions filters the inventory levels listed. At least one
+// inventory item or location is required.
+type InventoryLevelsOptions struct {
+	InventoryItemIDs []int64 `url:"inventory_item_ids,comma,omitempty"`
+	LocationIDs      []int64 `url:"location_ids,comma,omitempty"`
+	Limit            int     `url:"limit,omitempty"`
+	UpdatedAtMin     string  `url:"updated_at_min,omitempty"`
+	// PageInfo selects a page after the first, as returned by
+	// InventoryLevelsPage. The filters of the first page apply to the
+	// following ones, so only Limit may be set with it.
+	PageInfo string `url:"page_info,omitempty"`
+}
+
+var inventoryLevelResource = newRESTResource[InventoryLevel]("/admin/inventory_levels", "inventory_level", "inventory_levels")
+
+func (obj *InventoryLevel) setAPI(api *API) { obj.api = api }
+
+// NewInventoryLevel returns an inventory level to be connected, set or
+// adjusted with api.
+func (api *API) NewInventoryLevel() *InventoryLevel {
+	return &InventoryLevel{api: api}
+}
+
+// InventoryLevels returns every inventory level matching options, following
+// the pages of results.
+func (api *API) InventoryLevels(options *InventoryLevelsOptions) ([]*InventoryLevel, error) {
+	result := []*InventoryLevel{}
+	for {
+		levels, next, err := api.InventoryLevelsPage(options)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, levels...)
+		if next == nil {
+			return result, nil
+		}
+		options = next
+	}
+}
+
+// InventoryLevelsPage returns a page of the inventory levels matching
+// options, and the options selecting the next page, or nil on the last one.
+func (api *API) InventoryLevelsPage(options *InventoryLevelsOptions) ([]*InventoryLevel, *InventoryLevelsOptions, error) {
+	levels, pageInfo, err := inventoryLevelResource.listPage(api, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pageInfo == "" {
+		return levels, nil, nil
+	}
+	next := &InventoryLevelsOptions{PageInfo: pageInfo}
+	if options != nil {
+		next.Limit = options.Limit
+	}
+	return levels, next, nil
+}
+
 // Connect connects an inventory item to a location.
 func (obj *InventoryLevel) Connect() error {
-	return requestInvLevel("/admin/inventory_levels/connect.json", "POST", obj)
+	return obj.post("connect", 201, map[string]interface{}{})
 }
 
 // Set sets an inventory level for a variant w. location id.
 func (obj *InventoryLevel) Set() error {
-	return requestInvLevel("/admin/inventory_levels/set.json", "POST", obj)
+	return obj.post("set", 200, map[string]interface{}{"available": obj.Available})
 }
 
 // Adjust adjust an inventory level for a inventory item w. location id.
 func (obj *InventoryLevel) Adjust() error {
-	return requestInvLevel("/admin/inventory_levels/adjust.json", "POST", obj)
+	return obj.post("adjust", 200, map[string]interface{}{"available_adjustment": obj.AvailableAdjustment})
 }
 
 // Delete delete an inventory level for a inventory item w. location id.
 func (obj *InventoryLevel) Delete() error {
 	endpoint := fmt.Sprintf("/admin/inventory_levels.json?inventory_item_id=%d&location_id=%d", obj.InventoryItemID, obj.LocationID)
-	expectedStatus := 204
-	res, status, err := obj.api.request(endpoint, "DELETE", nil, nil)
-	if err != nil {
-		return err
-	}
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	return nil
+	return inventoryLevelResource.do(obj.api, endpoint, "DELETE", nil, 204, "", nil)
 }
 
-// requestInvLevel private func to make requests for inventory level.
-func requestInvLevel(endpoint, method string, obj *InventoryLevel) error {
-	expectedStatus := 200
-	var buf bytes.Buffer
-	body := map[string]*InventoryLevel{
-		"inventory_level": obj,
-	}
-	err := json.NewEncoder(&buf).Encode(body)
-	if err != nil {
-		return err
-	}
-	reqBody := buf.Bytes()
-
-	res, status, err := obj.api.request(endpoint, method, nil, &buf)
-	if err != nil {
+// post sends fields with the inventory item and location of obj to an
+// inventory_levels action, which unlike other resources takes them at the
+// top level of the body, and replaces obj with the level returned.
+func (obj *InventoryLevel) post(action string, expectedStatus int, fields map[string]interface{}) error {
+	fields["inventory_item_id"] = obj.InventoryItemID
+	fields["location_id"] = obj.LocationID
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(fields); err != nil {
 		return err
 	}
 
-	if status != expectedStatus {
-		return newErrorResponse(status, reqBody, res)
-	}
-
-	r := &struct {
-		InventoryLevel InventoryLevel `json:"inventory_level"`
-	}{}
-	err = json.NewDecoder(res).Decode(r)
-	if err != nil {
+	endpoint := fmt.Sprintf("/admin/inventory_levels/%s.json", action)
+	result := &InventoryLevel{}
+	if err := inventoryLevelResource.do(obj.api, endpoint, "POST", buf, expectedStatus, "inventory_level", result); err != nil {
 		return err
 	}
-
 	api := obj.api
-	*obj = r.InventoryLevel
+	*obj = *result
 	obj.api = api
-
 	return nil
 }
@@ -1,43 +1,37 @@
-package shopify
+// Code generated by shopify-gen from schema/resources.json. DO NOT EDIT.
 
-import (
-	"time"
-)
+package shopify
 
+// LineItem is a line of an order or checkout.
 type LineItem struct {
-	AppliedDiscounts []interface{} `json:"applied_discounts"`
-
-	CompareAtPrice string `json:"compare_at_price"`
-
-	FulfillmentService string `json:"fulfillment_service"`
-
-	GiftCard bool `json:"gift_card"`
-
-	Grams int64 `json:"grams"`
-
-	LinePrice time.Time `json:"line_price"`
-
-	Price string `json:"price"`
-
-	ProductId int64 `json:"product_id"`
-
-	Properties string `json:"properties"`
-
-	Quantity int64 `json:"quantity"`
-
-	RequiresShipping bool `json:"requires_shipping"`
-
-	Sku string `json:"sku"`
-
-	TaxLines []interface{} `json:"tax_lines"`
-
-	Taxable bool `json:"taxable"`
-
-	Title string `json:"title"`
-
-	VariantId int64 `json:"variant_id"`
-
-	VariantTitle string `json:"variant_title"`
-
-	Vendor string `json:"vendor"`
+	Id                         int64                `json:"id"`
+	AdminGraphqlApiId          string               `json:"admin_graphql_api_id"`
+	AppliedDiscounts           []interface{}        `json:"applied_discounts"`
+	CompareAtPrice             string               `json:"compare_at_price"`
+	CurrentQuantity            int64                `json:"current_quantity"`
+	DiscountAllocations        []DiscountAllocation `json:"discount_allocations"`
+	FulfillableQuantity        int64                `json:"fulfillable_quantity"`
+	FulfillmentService         string               `json:"fulfillment_service"`
+	FulfillmentStatus          string               `json:"fulfillment_status"`
+	GiftCard                   bool                 `json:"gift_card"`
+	Grams                      int64                `json:"grams"`
+	LinePrice                  string               `json:"line_price"`
+	Name                       string               `json:"name"`
+	Price                      string               `json:"price"`
+	PriceSet                   *PriceSet            `json:"price_set,omitempty"`
+	ProductExists              bool                 `json:"product_exists"`
+	ProductId                  int64                `json:"product_id"`
+	Properties                 []NoteAttribute      `json:"properties"`
+	Quantity                   int64                `json:"quantity"`
+	RequiresShipping           bool                 `json:"requires_shipping"`
+	Sku                        string               `json:"sku"`
+	TaxLines                   []TaxLine            `json:"tax_lines"`
+	Taxable                    bool                 `json:"taxable"`
+	Title                      string               `json:"title"`
+	TotalDiscount              string               `json:"total_discount"`
+	TotalDiscountSet           *PriceSet            `json:"total_discount_set,omitempty"`
+	VariantId                  int64                `json:"variant_id"`
+	VariantInventoryManagement string               `json:"variant_inventory_management"`
+	VariantTitle               string               `json:"variant_title"`
+	Vendor                     string               `json:"vendor"`
 }
@@ -1,13 +1,12 @@
 package shopify
 
 import (
-	"encoding/json"
-
-	"fmt"
-
 	"time"
 )
 
+// Location is a place where inventory is stocked: a shop's own warehouses
+// and stores, and the locations of fulfillment services, which are legacy.
+// Inactive locations are listed too; check Active.
 type Location struct {
 	Address1 string `json:"address1"`
 
@@ -41,59 +40,59 @@ type Location struct {
 
 	ProvinceCode string `json:"province_code"`
 
-	api *API
-}
-
-func (api *API) Locations() ([]Location, error) {
-	res, status, err := api.request("/admin/locations.json", "GET", nil, nil)
+	// Active is false for deactivated locations, which don't stock
+	// inventory or fulfill orders.
+	Active bool `json:"active"`
 
-	if err != nil {
-		return nil, err
-	}
+	// Legacy is true for the locations of fulfillment services.
+	Legacy bool `json:"legacy"`
 
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
+	// LocalizedCountryName and LocalizedProvinceName are in the shop's
+	// language.
+	LocalizedCountryName  string `json:"localized_country_name"`
+	LocalizedProvinceName string `json:"localized_province_name"`
 
-	r := &map[string][]Location{}
-	err = json.NewDecoder(res).Decode(r)
+	AdminGraphqlAPIID string `json:"admin_graphql_api_id"`
 
-	result := (*r)["locations"]
+	api *API
+}
 
-	if err != nil {
-		return nil, err
-	}
+var locationResource = newRESTResource[Location]("/admin/locations", "location", "locations")
 
-	for _, v := range result {
-		v.api = api
-	}
+func (obj *Location) setAPI(api *API) { obj.api = api }
 
-	return result, nil
+func (api *API) Locations() ([]Location, error) {
+	return locationResource.listValues(api, nil)
 }
 
-func (api *API) Location(id int64) (*Location, error) {
-	endpoint := fmt.Sprintf("/admin/locations/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
+// ActiveLocations returns the locations which are active, leaving out
+// deactivated ones and, unless legacy is true, the locations of fulfillment
+// services.
+func (api *API) ActiveLocations(legacy bool) ([]Location, error) {
+	locations, err := api.Locations()
 	if err != nil {
 		return nil, err
 	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
+	result := []Location{}
+	for _, location := range locations {
+		if location.Active && (legacy || !location.Legacy) {
+			result = append(result, location)
+		}
 	}
+	return result, nil
+}
 
-	r := map[string]Location{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["location"]
-
-	if err != nil {
-		return nil, err
-	}
+// LocationsCount returns the number of locations, active or not.
+func (api *API) LocationsCount() (int, error) {
+	return locationResource.count(api, nil)
+}
 
-	result.api = api
+func (api *API) Location(id int64) (*Location, error) {
+	return locationResource.get(api, id, nil)
+}
 
-	return &result, nil
+// InventoryLevels returns the inventory levels of the items stocked at the
+// location.
+func (obj *Location) InventoryLevels() ([]*InventoryLevel, error) {
+	return obj.api.InventoryLevels(&InventoryLevelsOptions{LocationIDs: []int64{obj.Id}})
 }
@@ -0,0 +1,100 @@
+package shopify
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogOptions configures the records logged by API and App.
+type LogOptions struct {
+	// Level is the level of successful requests. If nil, slog.LevelDebug
+	// is used.
+	Level slog.Leveler
+	// ErrorLevel is the level of failed requests and of rejected webhooks
+	// and session tokens. If nil, slog.LevelWarn is used.
+	ErrorLevel slog.Leveler
+	// Bodies adds the request and response bodies to the records, with
+	// secrets and customer data masked by DefaultRedactor.
+	Bodies bool
+	// MaxBodyLength, if positive, truncates the logged bodies.
+	MaxBodyLength int
+}
+
+func (o LogOptions) level(failed bool) slog.Level {
+	if failed {
+		if o.ErrorLevel == nil {
+			return slog.LevelWarn
+		}
+		return o.ErrorLevel.Level()
+	}
+	if o.Level == nil {
+		return slog.LevelDebug
+	}
+	return o.Level.Level()
+}
+
+func (o LogOptions) body(body []byte) string {
+	return truncate(DefaultRedactor.Redact(body), o.MaxBodyLength)
+}
+
+// logEvent logs an attempt at a request, if API.Logger is set.
+func (api *API) logEvent(event *RequestEvent, err error) {
+	if api.Logger == nil {
+		return
+	}
+
+	failed := err != nil || event.StatusCode >= 400
+	level := api.LogOptions.level(failed)
+	ctx := context.Background()
+	if event.Request != nil {
+		ctx = event.Request.Context()
+	}
+	if !api.Logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("shop", event.Shop),
+		slog.String("method", event.Method),
+		slog.String("endpoint", event.Endpoint),
+		slog.String("resource", event.Resource),
+		slog.Int("attempt", event.Attempt),
+	}
+	if event.StatusCode != 0 {
+		attrs = append(attrs,
+			slog.Int("status", event.StatusCode),
+			slog.Duration("duration", event.Duration),
+			slog.Int("calls_made", event.CallsMade),
+			slog.Int("call_limit", event.CallLimit),
+		)
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", DefaultRedactor.RedactString(err.Error())))
+	}
+	if api.LogOptions.Bodies {
+		if len(event.Body) > 0 {
+			attrs = append(attrs, slog.String("request_body", api.LogOptions.body(event.Body)))
+		}
+		if len(event.ResponseBody) > 0 {
+			attrs = append(attrs, slog.String("response_body", api.LogOptions.body(event.ResponseBody)))
+		}
+	}
+
+	message := "shopify request"
+	if failed {
+		message = "shopify request failed"
+	}
+	api.Logger.LogAttrs(ctx, level, message, attrs...)
+}
+
+// log logs a record for App, if App.Logger is set. err is redacted.
+func (s *App) log(ctx context.Context, message string, err error, attrs ...slog.Attr) {
+	if s.Logger == nil {
+		return
+	}
+	level := s.LogOptions.level(err != nil)
+	if err != nil {
+		attrs = append(attrs, slog.String("error", DefaultRedactor.RedactString(err.Error())))
+	}
+	s.Logger.LogAttrs(ctx, level, message, attrs...)
+}
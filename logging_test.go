@@ -0,0 +1,102 @@
+package shopify
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	records := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		record := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Error decoding log record %s: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAPILogging(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Shopify-Shop-Api-Call-Limit", "1/40")
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"errors":{"email":["has already been taken"]}}`))
+			return
+		}
+		w.Write([]byte(`{"customer":{"id":1,"email":"bob@example.com"}}`))
+	}))
+	defer server.Close()
+
+	buf := &bytes.Buffer{}
+	api := &API{
+		Shop:        "example.myshopify.com",
+		AccessToken: "shpat_secret",
+		BaseURL:     server.URL,
+		Logger:      slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo})),
+		LogOptions:  LogOptions{Level: slog.LevelInfo, Bodies: true},
+	}
+
+	if _, err := api.Customer(1); err != nil {
+		t.Fatalf("Error fetching customer: %v", err)
+	}
+	customer := api.NewCustomer()
+	customer.Email = "bob@example.com"
+	if err := customer.Save(); err == nil {
+		t.Fatalf("Expected validation error")
+	}
+
+	if strings.Contains(buf.String(), "bob@example.com") || strings.Contains(buf.String(), "shpat_secret") {
+		t.Errorf("Expected secrets and emails to be redacted, got %s", buf.String())
+	}
+
+	records := logRecords(t, buf)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d: %s", len(records), buf.String())
+	}
+	if records[0]["level"] != "INFO" || records[0]["method"] != "GET" || records[0]["resource"] != "customers" || records[0]["status"] != float64(200) || records[0]["calls_made"] != float64(1) {
+		t.Errorf("Unexpected record %v", records[0])
+	}
+	if records[1]["level"] != "WARN" || records[1]["msg"] != "shopify request failed" || records[1]["status"] != float64(422) || records[1]["request_body"] == nil {
+		t.Errorf("Unexpected record %v", records[1])
+	}
+
+	// below the handler's level
+	buf.Reset()
+	api.LogOptions = LogOptions{}
+	if _, err := api.Customer(1); err != nil {
+		t.Fatalf("Error fetching customer: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected debug records to be discarded, got %s", buf.String())
+	}
+}
+
+func TestAppLogging(t *testing.T) {
+	buf := &bytes.Buffer{}
+	app := &App{
+		APIKey:     "api-key",
+		APISecret:  "hush",
+		Logger:     slog.New(slog.NewJSONHandler(buf, nil)),
+		LogOptions: LogOptions{ErrorLevel: slog.LevelError},
+	}
+
+	handler := app.SessionTokenMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/admin/orders", nil)
+	req.Header.Set("Authorization", "Bearer not.a.jwt")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	records := logRecords(t, buf)
+	if len(records) != 1 || records[0]["level"] != "ERROR" || records[0]["msg"] != "invalid session token" || records[0]["path"] != "/admin/orders" {
+		t.Errorf("Unexpected records %v", records)
+	}
+}
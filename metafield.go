@@ -1,173 +1,180 @@
 package shopify
 
 import (
-	"bytes"
-
 	"encoding/json"
-
+	"errors"
 	"fmt"
-
+	"strings"
 	"time"
 )
 
 type Metafield struct {
-	CreatedAt     time.Time `json:"created_at"`
-	Description   string    `json:"description"`
-	Id            int64     `json:"id"`
-	Key           string    `json:"key"`
-	Namespace     string    `json:"namespace"`
-	OwnerId       int64     `json:"owner_id"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	Value         string    `json:"value"`
-	ValueType     string    `json:"value_type"`
-	OwnerResource string    `json:"owner_resource"`
+	CreatedAt   time.Time `json:"created_at"`
+	Description string    `json:"description"`
+	Id          int64     `json:"id"`
+	Key         string    `json:"key"`
+	Namespace   string    `json:"namespace"`
+	OwnerId     int64     `json:"owner_id"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// Value is the value encoded as a string, whatever its Type; see
+	// SetValue and DecodeValue.
+	Value string `json:"value"`
+	// Type is the type of the value, e.g. single_line_text_field or
+	// list.product_reference.
+	Type string `json:"type,omitempty"`
+	// ValueType is the type of older API versions: string, integer or
+	// json_string.
+	ValueType     string `json:"value_type,omitempty"`
+	OwnerResource string `json:"owner_resource"`
 	api           *API
+	// blogID is the blog of the article owning a new metafield.
+	blogID int64
 }
 
-func (api *API) Metafields() ([]*Metafield, error) {
-	res, status, err := api.request("/admin/metafields.json", "GET", nil, nil)
+// MetafieldsOptions filters the metafields listed and counted.
+type MetafieldsOptions struct {
+	Limit        int    `url:"limit,omitempty"`
+	SinceID      int64  `url:"since_id,omitempty"`
+	CreatedAtMin string `url:"created_at_min,omitempty"`
+	CreatedAtMax string `url:"created_at_max,omitempty"`
+	UpdatedAtMin string `url:"updated_at_min,omitempty"`
+	UpdatedAtMax string `url:"updated_at_max,omitempty"`
+	Namespace    string `url:"namespace,omitempty"`
+	Key          string `url:"key,omitempty"`
+	Type         string `url:"type,omitempty"`
+	Fields       string `url:"fields,omitempty"`
+}
 
-	if err != nil {
-		return nil, err
-	}
+// MetafieldOwner identifies the resource metafields belong to.
+type MetafieldOwner struct {
+	// Resource is the owner_resource of the metafields: shop, product,
+	// variant, customer, order, collection, page, blog, article or
+	// location.
+	Resource string
+	ID       int64
+	// BlogID is the blog of an article, whose metafields are nested under
+	// it.
+	BlogID int64
+}
 
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
+// ShopMetafieldOwner owns the metafields of the shop itself.
+var ShopMetafieldOwner = MetafieldOwner{Resource: "shop"}
+
+// metafieldOwnerPaths are the paths of the owners under which their
+// metafields are nested, by owner_resource.
+var metafieldOwnerPaths = map[string]string{
+	"product":    "/admin/products/%d",
+	"variant":    "/admin/variants/%d",
+	"customer":   "/admin/customers/%d",
+	"order":      "/admin/orders/%d",
+	"collection": "/admin/collections/%d",
+	"page":       "/admin/pages/%d",
+	"blog":       "/admin/blogs/%d",
+	"location":   "/admin/locations/%d",
+}
 
-	r := map[string][]*Metafield{}
-	err = json.NewDecoder(res).Decode(&r)
+// resource returns the metafields resource of the owner.
+func (o MetafieldOwner) resource() (restResource[Metafield, *Metafield], error) {
+	switch o.Resource {
+	case "", "shop":
+		return metafieldResource, nil
+	case "article":
+		if o.BlogID == 0 {
+			return metafieldResource, errors.New("the metafields of an article need its BlogID")
+		}
+		return metafieldResource.nested(fmt.Sprintf("/admin/blogs/%d/articles/%d", o.BlogID, o.ID)), nil
+	}
+	format, ok := metafieldOwnerPaths[o.Resource]
+	if !ok {
+		return metafieldResource, fmt.Errorf("metafields can't be owned by %q", o.Resource)
+	}
+	return metafieldResource.nested(fmt.Sprintf(format, o.ID)), nil
+}
 
-	result := r["metafields"]
+var metafieldResource = newRESTResource[Metafield]("/admin/metafields", "metafield", "metafields")
 
-	if err != nil {
-		return nil, err
-	}
+func (obj *Metafield) setAPI(api *API) { obj.api = api }
 
-	for _, v := range result {
-		v.api = api
+// UnmarshalJSON decodes a metafield, keeping a value sent as a JSON number
+// or boolean, as some types are, in its string form.
+func (obj *Metafield) UnmarshalJSON(data []byte) error {
+	type metafield Metafield
+	aux := struct {
+		*metafield
+		Value json.RawMessage `json:"value"`
+	}{metafield: (*metafield)(obj)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
 	}
 
-	return result, nil
+	switch raw := strings.TrimSpace(string(aux.Value)); {
+	case raw == "" || raw == "null":
+		obj.Value = ""
+	case strings.HasPrefix(raw, `"`):
+		return json.Unmarshal(aux.Value, &obj.Value)
+	default:
+		obj.Value = raw
+	}
+	return nil
 }
 
-func (api *API) Metafield(id int64) (*Metafield, error) {
-	endpoint := fmt.Sprintf("/admin/metafields/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
+// Metafields returns the metafields of the shop.
+func (api *API) Metafields() ([]*Metafield, error) {
+	return metafieldResource.list(api, nil)
+}
 
+// OwnerMetafields returns the metafields of owner matching options.
+func (api *API) OwnerMetafields(owner MetafieldOwner, options *MetafieldsOptions) ([]*Metafield, error) {
+	r, err := owner.resource()
 	if err != nil {
 		return nil, err
 	}
+	return r.list(api, options)
+}
 
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]Metafield{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["metafield"]
-
+// OwnerMetafieldsCount returns the number of metafields of owner matching
+// options.
+func (api *API) OwnerMetafieldsCount(owner MetafieldOwner, options *MetafieldsOptions) (int, error) {
+	r, err := owner.resource()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
+	return r.count(api, options)
+}
 
-	result.api = api
-
-	return &result, nil
+func (api *API) Metafield(id int64) (*Metafield, error) {
+	return metafieldResource.get(api, id, nil)
 }
 
+// NewMetafield returns a new metafield of the shop.
 func (api *API) NewMetafield() *Metafield {
 	return &Metafield{api: api}
 }
 
-func (obj *Metafield) Save() error {
-	endpoint := fmt.Sprintf("/admin/metafields/%d.json", obj.Id)
-	method := "PUT"
-	expectedStatus := 201
-
-	if obj.Id == 0 {
-		endpoint = fmt.Sprintf("/admin/metafields.json")
-		method = "POST"
-		expectedStatus = 201
-	}
-
-	body := map[string]*Metafield{}
-	body["metafield"] = obj
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
-
-	if err != nil {
-		return err
-	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
-	}
+// NewOwnerMetafield returns a new metafield of owner, to be saved with api.
+func (api *API) NewOwnerMetafield(owner MetafieldOwner) *Metafield {
+	return &Metafield{api: api, OwnerResource: owner.Resource, OwnerId: owner.ID, blogID: owner.BlogID}
+}
 
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
+// Save creates the metafield under its owner if it has no id, and updates
+// it otherwise.
+func (obj *Metafield) Save() error {
+	if obj.Id != 0 {
+		return metafieldResource.update(obj.api, obj.Id, obj, nil)
 	}
-
-	r := map[string]Metafield{}
-	err = json.NewDecoder(res).Decode(&r)
-
+	r, err := MetafieldOwner{Resource: obj.OwnerResource, ID: obj.OwnerId, BlogID: obj.blogID}.resource()
 	if err != nil {
 		return err
 	}
-
-	api := obj.api
-	*obj = r["metafield"]
-	obj.api = api
-
-	return nil
+	return r.create(obj.api, obj, nil)
 }
 
 func (obj *Metafield) SaveForProduct(productId int64) error {
-	endpoint := fmt.Sprintf("/admin/products/%d/metafields/%d.json", productId, obj.Id)
-	method := "PUT"
-	expectedStatus := 200
-
-	if obj.Id == 0 {
-		endpoint = fmt.Sprintf("/admin/products/%d/metafields.json", productId)
-		method = "POST"
-		expectedStatus = 201
-	}
-
-	body := map[string]*Metafield{}
-	body["metafield"] = obj
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
-
-	if err != nil {
-		return err
-	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	r := map[string]Metafield{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	if err != nil {
-		return err
-	}
-
-	api := obj.api
-	*obj = r["metafield"]
-	obj.api = api
+	products := metafieldResource.nested(fmt.Sprintf("/admin/products/%d", productId))
+	return products.save(obj.api, obj.Id, obj, nil)
+}
 
-	return nil
+// Delete deletes the metafield.
+func (obj *Metafield) Delete() error {
+	return metafieldResource.delete(obj.api, obj.Id)
 }
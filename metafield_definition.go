@@ -0,0 +1,415 @@
+package shopify
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The owner types of metafield definitions.
+const (
+	MetafieldOwnerTypeProduct    = "PRODUCT"
+	MetafieldOwnerTypeVariant    = "PRODUCTVARIANT"
+	MetafieldOwnerTypeCollection = "COLLECTION"
+	MetafieldOwnerTypeCustomer   = "CUSTOMER"
+	MetafieldOwnerTypeOrder      = "ORDER"
+	MetafieldOwnerTypePage       = "PAGE"
+	MetafieldOwnerTypeBlog       = "BLOG"
+	MetafieldOwnerTypeArticle    = "ARTICLE"
+	MetafieldOwnerTypeLocation   = "LOCATION"
+	MetafieldOwnerTypeShop       = "SHOP"
+)
+
+// MetafieldDefinition declares the type and validations of the metafields
+// with a namespace and key, on resources of an owner type. Definitions are
+// only available through the GraphQL API, and their ID is a GID.
+type MetafieldDefinition struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"`
+	Key         string `json:"key"`
+	Description string `json:"description,omitempty"`
+	// Type is the type of the metafields, e.g. MetafieldTypeNumberInteger.
+	Type string `json:"type"`
+	// OwnerType is one of the MetafieldOwnerType constants.
+	OwnerType   string                `json:"ownerType"`
+	Validations []MetafieldValidation `json:"validations,omitempty"`
+	// PinnedPosition is the position of a definition pinned in the admin,
+	// nil if it isn't pinned. Use Pin and Unpin to change it.
+	PinnedPosition *int `json:"pinnedPosition,omitempty"`
+	api            *API
+}
+
+// MetafieldValidation is a constraint on the values of a definition's
+// metafields, e.g. {"max", "10"}, or {"choices", `["S","M","L"]`}.
+type MetafieldValidation struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// MetafieldDefinitionsOptions filters the definitions listed.
+type MetafieldDefinitionsOptions struct {
+	Namespace string
+	Key       string
+	// PinnedStatus is ANY, PINNED or UNPINNED.
+	PinnedStatus string
+}
+
+const metafieldDefinitionFields = `id name namespace key description ownerType pinnedPosition
+	type { name }
+	validations { name value }`
+
+func (obj *MetafieldDefinition) setAPI(api *API) { obj.api = api }
+
+// UnmarshalJSON decodes a definition, whose type is an object in GraphQL
+// responses.
+func (obj *MetafieldDefinition) UnmarshalJSON(data []byte) error {
+	type metafieldDefinition MetafieldDefinition
+	aux := struct {
+		*metafieldDefinition
+		Type json.RawMessage `json:"type"`
+	}{metafieldDefinition: (*metafieldDefinition)(obj)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	typ, err := graphQLTypeName(aux.Type)
+	obj.Type = typ
+	return err
+}
+
+// graphQLTypeName decodes a type given as its name, or as an object with a
+// name field.
+func graphQLTypeName(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	name := ""
+	if strings.HasPrefix(string(raw), `"`) {
+		err := json.Unmarshal(raw, &name)
+		return name, err
+	}
+	typ := struct {
+		Name string `json:"name"`
+	}{}
+	err := json.Unmarshal(raw, &typ)
+	return typ.Name, err
+}
+
+// MetafieldDefinitions returns the definitions of the metafields of
+// resources of ownerType matching options, which may be nil.
+func (api *API) MetafieldDefinitions(ownerType string, options *MetafieldDefinitionsOptions) ([]*MetafieldDefinition, error) {
+	query := `query($ownerType: MetafieldOwnerType!, $namespace: String, $key: String, $pinnedStatus: MetafieldDefinitionPinnedStatus, $after: String) {
+  metafieldDefinitions(first: 250, after: $after, ownerType: $ownerType, namespace: $namespace, key: $key, pinnedStatus: $pinnedStatus) {
+    nodes { ` + metafieldDefinitionFields + ` }
+    pageInfo { hasNextPage endCursor }
+  }
+}`
+	variables := map[string]interface{}{"ownerType": ownerType}
+	if options != nil {
+		if options.Namespace != "" {
+			variables["namespace"] = options.Namespace
+		}
+		if options.Key != "" {
+			variables["key"] = options.Key
+		}
+		if options.PinnedStatus != "" {
+			variables["pinnedStatus"] = options.PinnedStatus
+		}
+	}
+	return graphQLNodes[MetafieldDefinition](api, query, "metafieldDefinitions", variables)
+}
+
+// MetafieldDefinition returns the definition with the GID id.
+func (api *API) MetafieldDefinition(id string) (*MetafieldDefinition, error) {
+	query := `query($id: ID!) { metafieldDefinition(id: $id) { ` + metafieldDefinitionFields + ` } }`
+	data := struct {
+		Definition *MetafieldDefinition `json:"metafieldDefinition"`
+	}{}
+	if err := api.GraphQL(query, map[string]interface{}{"id": id}, &data); err != nil {
+		return nil, err
+	}
+	if data.Definition == nil {
+		return nil, fmt.Errorf("metafield definition %s: %w", id, ErrNotFound)
+	}
+	data.Definition.api = api
+	return data.Definition, nil
+}
+
+// NewMetafieldDefinition returns a new definition of the metafields of
+// resources of ownerType.
+func (api *API) NewMetafieldDefinition(ownerType string) *MetafieldDefinition {
+	return &MetafieldDefinition{OwnerType: ownerType, api: api}
+}
+
+// Save creates the definition if it has no ID, and updates its name,
+// description and validations otherwise. Its namespace, key, type and owner
+// type can't be changed.
+func (obj *MetafieldDefinition) Save() error {
+	validations := obj.Validations
+	if validations == nil {
+		validations = []MetafieldValidation{}
+	}
+	definition := map[string]interface{}{
+		"name":        obj.Name,
+		"namespace":   obj.Namespace,
+		"key":         obj.Key,
+		"ownerType":   obj.OwnerType,
+		"description": obj.Description,
+		"validations": validations,
+	}
+
+	if obj.ID == "" {
+		definition["type"] = obj.Type
+		mutation := `mutation($definition: MetafieldDefinitionInput!) {
+  metafieldDefinitionCreate(definition: $definition) {
+    createdDefinition { ` + metafieldDefinitionFields + ` }
+    userErrors { field message code }
+  }
+}`
+		return obj.mutate(mutation, "metafieldDefinitionCreate", "createdDefinition", map[string]interface{}{"definition": definition})
+	}
+
+	mutation := `mutation($definition: MetafieldDefinitionUpdateInput!) {
+  metafieldDefinitionUpdate(definition: $definition) {
+    updatedDefinition { ` + metafieldDefinitionFields + ` }
+    userErrors { field message code }
+  }
+}`
+	return obj.mutate(mutation, "metafieldDefinitionUpdate", "updatedDefinition", map[string]interface{}{"definition": definition})
+}
+
+// Pin pins the definition, showing its metafields on the resources' pages
+// in the admin.
+func (obj *MetafieldDefinition) Pin() error {
+	mutation := `mutation($definitionId: ID!) {
+  metafieldDefinitionPin(definitionId: $definitionId) {
+    pinnedDefinition { ` + metafieldDefinitionFields + ` }
+    userErrors { field message code }
+  }
+}`
+	return obj.mutate(mutation, "metafieldDefinitionPin", "pinnedDefinition", map[string]interface{}{"definitionId": obj.ID})
+}
+
+// Unpin unpins the definition.
+func (obj *MetafieldDefinition) Unpin() error {
+	mutation := `mutation($definitionId: ID!) {
+  metafieldDefinitionUnpin(definitionId: $definitionId) {
+    unpinnedDefinition { ` + metafieldDefinitionFields + ` }
+    userErrors { field message code }
+  }
+}`
+	return obj.mutate(mutation, "metafieldDefinitionUnpin", "unpinnedDefinition", map[string]interface{}{"definitionId": obj.ID})
+}
+
+// Delete deletes the definition. The metafields it defined are kept, without
+// a definition, unless deleteMetafields is true.
+func (obj *MetafieldDefinition) Delete(deleteMetafields bool) error {
+	mutation := `mutation($id: ID!, $deleteAllAssociatedMetafields: Boolean) {
+  metafieldDefinitionDelete(id: $id, deleteAllAssociatedMetafields: $deleteAllAssociatedMetafields) {
+    deletedDefinitionId
+    userErrors { field message code }
+  }
+}`
+	variables := map[string]interface{}{"id": obj.ID, "deleteAllAssociatedMetafields": deleteMetafields}
+	return obj.api.mutate(mutation, "metafieldDefinitionDelete", variables, nil)
+}
+
+// mutate runs a mutation returning the definition in the field of its
+// payload, and replaces obj with it.
+func (obj *MetafieldDefinition) mutate(mutation, name, field string, variables map[string]interface{}) error {
+	payload := map[string]json.RawMessage{}
+	if err := obj.api.mutate(mutation, name, variables, &payload); err != nil {
+		return err
+	}
+	var result *MetafieldDefinition
+	if err := json.Unmarshal(payload[field], &result); err != nil || result == nil {
+		return fmt.Errorf("graphql: %s returned no %s", name, field)
+	}
+	api := obj.api
+	*obj = *result
+	obj.api = api
+	return nil
+}
+
+// Validate checks the value of m against the type and validations of the
+// definition, as Shopify does when it's saved, returning a
+// *ValidationError listing the problems under "value". Validations which
+// can't be checked locally, such as file types, are ignored.
+func (obj *MetafieldDefinition) Validate(m *Metafield) error {
+	if m.Type != "" && m.Type != obj.Type {
+		return &ValidationError{Fields: map[string][]string{
+			"type": {fmt.Sprintf("must be %s, as defined for %s.%s", obj.Type, obj.Namespace, obj.Key)},
+		}}
+	}
+
+	typ := obj.Type
+	items := []string{m.Value}
+	if item, ok := listItemType(typ); ok {
+		typ = item
+		if err := DecodeMetafieldValue(obj.Type, m.Value, &items); err != nil {
+			return &ValidationError{Fields: map[string][]string{"value": {err.Error()}}}
+		}
+	}
+
+	messages := []string{}
+	for _, item := range items {
+		if err := checkMetafieldItem(typ, item); err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+	if len(messages) > 0 {
+		return &ValidationError{Fields: map[string][]string{"value": messages}}
+	}
+
+	for _, validation := range obj.Validations {
+		switch validation.Name {
+		case "list.min", "list.max":
+			bound, err := strconv.Atoi(validation.Value)
+			if err != nil {
+				continue
+			}
+			if validation.Name == "list.min" && len(items) < bound {
+				messages = append(messages, fmt.Sprintf("must have at least %d items", bound))
+			}
+			if validation.Name == "list.max" && len(items) > bound {
+				messages = append(messages, fmt.Sprintf("must have at most %d items", bound))
+			}
+		default:
+			for _, item := range items {
+				if message := validateMetafieldItem(typ, item, validation); message != "" {
+					messages = append(messages, message)
+				}
+			}
+		}
+	}
+	if len(messages) > 0 {
+		return &ValidationError{Fields: map[string][]string{"value": messages}}
+	}
+	return nil
+}
+
+// checkMetafieldItem checks that item is a well-formed value of typ.
+func checkMetafieldItem(typ, item string) error {
+	var dst interface{}
+	switch typ {
+	case MetafieldTypeNumberInteger:
+		dst = new(int64)
+	case MetafieldTypeNumberDecimal:
+		dst = new(float64)
+	case MetafieldTypeBoolean:
+		dst = new(bool)
+	case MetafieldTypeDate, MetafieldTypeDateTime:
+		dst = new(time.Time)
+	case MetafieldTypeJSON:
+		if !json.Valid([]byte(item)) {
+			return fmt.Errorf("%q isn't valid JSON", item)
+		}
+		return nil
+	default:
+		if strings.HasSuffix(typ, "_reference") && !strings.HasPrefix(item, "gid://shopify/") {
+			return fmt.Errorf("%q isn't a GID", item)
+		}
+		return nil
+	}
+	return decodeScalar(typ, item, reflect.ValueOf(dst).Elem())
+}
+
+// validateMetafieldItem returns the message of the validation item fails,
+// or "" if it passes.
+func validateMetafieldItem(typ, item string, validation MetafieldValidation) string {
+	switch validation.Name {
+	case "min", "max":
+		cmp, ok := compareMetafieldItem(typ, item, validation.Value)
+		if !ok {
+			return ""
+		}
+		unit := ""
+		if isTextMetafieldType(typ) {
+			unit = " characters"
+		}
+		if validation.Name == "min" && cmp < 0 {
+			return fmt.Sprintf("must be at least %s%s", validation.Value, unit)
+		}
+		if validation.Name == "max" && cmp > 0 {
+			return fmt.Sprintf("must be at most %s%s", validation.Value, unit)
+		}
+	case "regex":
+		re, err := regexp.Compile(validation.Value)
+		if err == nil && !re.MatchString(item) {
+			return fmt.Sprintf("%q doesn't match %s", item, validation.Value)
+		}
+	case "choices":
+		choices := []string{}
+		if err := json.Unmarshal([]byte(validation.Value), &choices); err != nil {
+			return ""
+		}
+		for _, choice := range choices {
+			if item == choice {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%q isn't one of %s", item, strings.Join(choices, ", "))
+	case "max_precision":
+		precision, err := strconv.Atoi(validation.Value)
+		if err != nil {
+			return ""
+		}
+		if i := strings.IndexByte(item, '.'); i >= 0 && len(item)-i-1 > precision {
+			return fmt.Sprintf("must have at most %d decimal places", precision)
+		}
+	}
+	return ""
+}
+
+// compareMetafieldItem compares item with bound: by length for text, by
+// value for numbers and dates. ok is false if they can't be compared.
+func compareMetafieldItem(typ, item, bound string) (cmp int, ok bool) {
+	switch {
+	case isTextMetafieldType(typ):
+		n, err := strconv.Atoi(bound)
+		if err != nil {
+			return 0, false
+		}
+		return utf8.RuneCountInString(item) - n, true
+	case typ == MetafieldTypeNumberInteger || typ == MetafieldTypeNumberDecimal:
+		a, errA := strconv.ParseFloat(item, 64)
+		b, errB := strconv.ParseFloat(bound, 64)
+		if errA != nil || errB != nil {
+			return 0, false
+		}
+		switch {
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	case typ == MetafieldTypeDate || typ == MetafieldTypeDateTime:
+		layout := time.RFC3339
+		if typ == MetafieldTypeDate {
+			layout = metafieldDateLayout
+		}
+		a, errA := time.Parse(layout, item)
+		b, errB := time.Parse(layout, bound)
+		if errA != nil || errB != nil {
+			return 0, false
+		}
+		switch {
+		case a.Before(b):
+			return -1, true
+		case a.After(b):
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func isTextMetafieldType(typ string) bool {
+	return typ == MetafieldTypeSingleLineText || typ == MetafieldTypeMultiLineText
+}
@@ -0,0 +1,146 @@
+package shopify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// graphQLRequest is a request received by a graphQLServer.
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// graphQLServer returns an API whose GraphQL requests are answered by
+// respond with the data of the response, and the requests it received.
+func graphQLServer(t *testing.T, respond func(req graphQLRequest) string) (*API, *[]graphQLRequest) {
+	requests := []graphQLRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := graphQLRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Error decoding GraphQL request: %v", err)
+		}
+		requests = append(requests, req)
+		w.Write([]byte(`{"data":` + respond(req) + `}`))
+	}))
+	t.Cleanup(server.Close)
+	return &API{Shop: "example.myshopify.com", BaseURL: server.URL, RetryPolicy: NoRetry}, &requests
+}
+
+const sizeDefinition = `{"id":"gid://shopify/MetafieldDefinition/1","name":"Size","namespace":"custom","key":"size",
+	"ownerType":"PRODUCT","pinnedPosition":null,"type":{"name":"single_line_text_field"},
+	"validations":[{"name":"choices","value":"[\"S\",\"M\",\"L\"]"}]}`
+
+func TestMetafieldDefinitions(t *testing.T) {
+	api, requests := graphQLServer(t, func(req graphQLRequest) string {
+		switch {
+		case strings.Contains(req.Query, "metafieldDefinitions(") && req.Variables["after"] == nil:
+			return `{"metafieldDefinitions":{"nodes":[` + sizeDefinition + `],"pageInfo":{"hasNextPage":true,"endCursor":"c1"}}}`
+		case strings.Contains(req.Query, "metafieldDefinitions("):
+			return `{"metafieldDefinitions":{"nodes":[{"id":"gid://shopify/MetafieldDefinition/2","key":"care","type":{"name":"multi_line_text_field"}}],"pageInfo":{"hasNextPage":false}}}`
+		case strings.Contains(req.Query, "metafieldDefinitionCreate"):
+			definition := req.Variables["definition"].(map[string]interface{})
+			if definition["key"] == "size" {
+				return `{"metafieldDefinitionCreate":{"createdDefinition":null,"userErrors":[{"field":["definition","key"],"message":"Key is in use","code":"TAKEN"}]}}`
+			}
+			return `{"metafieldDefinitionCreate":{"createdDefinition":{"id":"gid://shopify/MetafieldDefinition/3","key":"` + definition["key"].(string) + `","type":{"name":"` + definition["type"].(string) + `"}},"userErrors":[]}}`
+		case strings.Contains(req.Query, "metafieldDefinitionPin"):
+			return `{"metafieldDefinitionPin":{"pinnedDefinition":{"id":"gid://shopify/MetafieldDefinition/3","key":"fabric","type":{"name":"single_line_text_field"},"pinnedPosition":1},"userErrors":[]}}`
+		case strings.Contains(req.Query, "metafieldDefinitionDelete"):
+			return `{"metafieldDefinitionDelete":{"deletedDefinitionId":"gid://shopify/MetafieldDefinition/3","userErrors":[]}}`
+		}
+		return `{"metafieldDefinition":null}`
+	})
+
+	definitions, err := api.MetafieldDefinitions(MetafieldOwnerTypeProduct, &MetafieldDefinitionsOptions{Namespace: "custom"})
+	if err != nil {
+		t.Fatalf("Error listing definitions: %v", err)
+	}
+	if len(definitions) != 2 || definitions[0].Type != MetafieldTypeSingleLineText || definitions[0].Validations[0].Name != "choices" || definitions[1].Key != "care" {
+		t.Fatalf("Expected the definitions of both pages, got %#v", definitions)
+	}
+	if vars := (*requests)[1].Variables; vars["after"] != "c1" || vars["ownerType"] != "PRODUCT" || vars["namespace"] != "custom" {
+		t.Errorf("Expected the second page to be requested after c1, got %v", vars)
+	}
+
+	taken := api.NewMetafieldDefinition(MetafieldOwnerTypeProduct)
+	taken.Namespace, taken.Key, taken.Name, taken.Type = "custom", "size", "Size", MetafieldTypeSingleLineText
+	err = taken.Save()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Fields["definition.key"][0] != "Key is in use" {
+		t.Errorf("Expected the user errors as a ValidationError, got %v", err)
+	}
+
+	definition := api.NewMetafieldDefinition(MetafieldOwnerTypeProduct)
+	definition.Namespace, definition.Key, definition.Name, definition.Type = "custom", "fabric", "Fabric", MetafieldTypeSingleLineText
+	if err := definition.Save(); err != nil {
+		t.Fatalf("Error creating definition: %v", err)
+	}
+	if definition.ID != "gid://shopify/MetafieldDefinition/3" || definition.api != api {
+		t.Errorf("Expected the created definition, got %#v", definition)
+	}
+	if err := definition.Pin(); err != nil || definition.PinnedPosition == nil || *definition.PinnedPosition != 1 {
+		t.Errorf("Expected the definition to be pinned, got %v, %#v", err, definition)
+	}
+	if err := definition.Delete(true); err != nil {
+		t.Errorf("Error deleting definition: %v", err)
+	}
+	if last := (*requests)[len(*requests)-1]; last.Variables["deleteAllAssociatedMetafields"] != true {
+		t.Errorf("Expected the metafields to be deleted with the definition, got %v", last.Variables)
+	}
+
+	if _, err := api.MetafieldDefinition("gid://shopify/MetafieldDefinition/9"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing definition, got %v", err)
+	}
+}
+
+func TestMetafieldDefinitionValidate(t *testing.T) {
+	definition := func(typ string, validations ...string) *MetafieldDefinition {
+		d := &MetafieldDefinition{Namespace: "custom", Key: "k", Type: typ}
+		for i := 0; i < len(validations); i += 2 {
+			d.Validations = append(d.Validations, MetafieldValidation{validations[i], validations[i+1]})
+		}
+		return d
+	}
+	tests := []struct {
+		definition *MetafieldDefinition
+		value      string
+		valid      bool
+	}{
+		{definition(MetafieldTypeNumberInteger, "min", "1", "max", "10"), "10", true},
+		{definition(MetafieldTypeNumberInteger, "min", "1", "max", "10"), "11", false},
+		{definition(MetafieldTypeNumberInteger), "1.5", false},
+		{definition(MetafieldTypeNumberDecimal, "max_precision", "2"), "1.25", true},
+		{definition(MetafieldTypeNumberDecimal, "max_precision", "2"), "1.255", false},
+		{definition(MetafieldTypeSingleLineText, "max", "3"), "Größe", false},
+		{definition(MetafieldTypeSingleLineText, "regex", "^[A-Z]+$"), "XL", true},
+		{definition(MetafieldTypeSingleLineText, "choices", `["S","M"]`), "L", false},
+		{definition(MetafieldTypeDate, "min", "2024-01-01"), "2023-12-31", false},
+		{definition(MetafieldTypeBoolean), "yes", false},
+		{definition("list."+MetafieldTypeSingleLineText, "choices", `["S","M"]`, "list.max", "2"), `["S","M"]`, true},
+		{definition("list."+MetafieldTypeSingleLineText, "choices", `["S","M"]`, "list.max", "2"), `["S","M","S"]`, false},
+		{definition("list."+MetafieldTypeNumberInteger, "min", "0"), `[1,-1]`, false},
+		{definition(MetafieldTypeProductReference), "12", false},
+	}
+
+	for _, test := range tests {
+		err := test.definition.Validate(&Metafield{Value: test.value})
+		if test.valid && err != nil {
+			t.Errorf("%s %v: expected %q to be valid, got %v", test.definition.Type, test.definition.Validations, test.value, err)
+		}
+		var validationErr *ValidationError
+		if !test.valid && (!errors.As(err, &validationErr) || len(validationErr.Fields["value"]) == 0) {
+			t.Errorf("%s %v: expected %q to be invalid, got %v", test.definition.Type, test.definition.Validations, test.value, err)
+		}
+	}
+
+	err := definition(MetafieldTypeNumberInteger).Validate(&Metafield{Type: MetafieldTypeSingleLineText, Value: "1"})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Fields["type"]) != 1 || errors.Unwrap(err) != nil {
+		t.Errorf("Expected a type mismatch without a response, got %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package shopify
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetafieldValues(t *testing.T) {
+	date := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		typ     string
+		value   interface{}
+		encoded string
+		// decoded is a pointer to decode into, holding value afterwards
+		decoded interface{}
+	}{
+		{MetafieldTypeSingleLineText, "Blue", "Blue", new(string)},
+		{MetafieldTypeNumberInteger, int64(-12), "-12", new(int64)},
+		{MetafieldTypeNumberInteger, 7, "7", new(int)},
+		{MetafieldTypeNumberDecimal, 2.5, "2.5", new(float64)},
+		{MetafieldTypeNumberDecimal, float32(0.1), "0.1", new(float32)},
+		{MetafieldTypeBoolean, true, "true", new(bool)},
+		{MetafieldTypeDate, date, "2024-03-09", new(time.Time)},
+		{MetafieldTypeDateTime, date.Add(90 * time.Minute), "2024-03-09T01:30:00Z", new(time.Time)},
+		{MetafieldTypeProductReference, "gid://shopify/Product/1", "gid://shopify/Product/1", new(string)},
+		{MetafieldTypeVariantReference, int64(42), "gid://shopify/ProductVariant/42", new(int64)},
+		{MetafieldTypeJSON, map[string]interface{}{"size": "M"}, `{"size":"M"}`, &map[string]interface{}{}},
+		{"list." + MetafieldTypeSingleLineText, []string{"a", `b"c`}, `["a","b\"c"]`, &[]string{}},
+		{"list." + MetafieldTypeNumberInteger, []int{1, 2}, `[1,2]`, &[]int{}},
+		{"list." + MetafieldTypeNumberDecimal, []float64{1.5}, `["1.5"]`, &[]float64{}},
+		{"list." + MetafieldTypeDate, []time.Time{date}, `["2024-03-09"]`, &[]time.Time{}},
+		{"list." + MetafieldTypeCollectionReference, []int64{3, 4}, `["gid://shopify/Collection/3","gid://shopify/Collection/4"]`, &[]int64{}},
+	}
+
+	for _, test := range tests {
+		metafield := &Metafield{ValueType: "string"}
+		if err := metafield.SetValue(test.typ, test.value); err != nil {
+			t.Errorf("%s: error setting %v: %v", test.typ, test.value, err)
+			continue
+		}
+		if metafield.Value != test.encoded || metafield.Type != test.typ || metafield.ValueType != "" {
+			t.Errorf("%s: expected %s, got %#v", test.typ, test.encoded, metafield)
+		}
+		if err := metafield.DecodeValue(test.decoded); err != nil {
+			t.Errorf("%s: error decoding %s: %v", test.typ, metafield.Value, err)
+			continue
+		}
+		if decoded := reflect.ValueOf(test.decoded).Elem().Interface(); !reflect.DeepEqual(decoded, test.value) {
+			t.Errorf("%s: expected to decode %#v, got %#v", test.typ, test.value, decoded)
+		}
+	}
+}
+
+func TestMetafieldValueMismatch(t *testing.T) {
+	metafield := &Metafield{}
+	for typ, value := range map[string]interface{}{
+		MetafieldTypeNumberInteger:           "12",
+		MetafieldTypeNumberDecimal:           "twelve",
+		MetafieldTypeBoolean:                 1,
+		MetafieldTypeSingleLineText:          12,
+		MetafieldTypeFileReference:           int64(12),
+		"list." + MetafieldTypeNumberInteger: 12,
+	} {
+		if err := metafield.SetValue(typ, value); err == nil {
+			t.Errorf("%s: expected an error setting %#v", typ, value)
+		}
+	}
+
+	metafield = &Metafield{Type: MetafieldTypeNumberInteger, Value: "12"}
+	var b bool
+	if err := metafield.DecodeValue(&b); err == nil {
+		t.Errorf("Expected an error decoding an integer into a bool")
+	}
+	var n int8
+	metafield.Value = "300"
+	if err := metafield.DecodeValue(&n); err == nil {
+		t.Errorf("Expected an error decoding 300 into an int8")
+	}
+	if err := metafield.DecodeValue(n); err == nil {
+		t.Errorf("Expected an error decoding into a non-pointer")
+	}
+}
+
+func TestMetafieldUnmarshalValue(t *testing.T) {
+	metafields := []Metafield{}
+	err := json.Unmarshal([]byte(`[{"id":1,"value":"Blue"},{"id":2,"value":12,"type":"number_integer"},{"id":3,"value":null}]`), &metafields)
+	if err != nil {
+		t.Fatalf("Error decoding metafields: %v", err)
+	}
+	for i, expected := range []string{"Blue", "12", ""} {
+		if metafields[i].Id != int64(i+1) || metafields[i].Value != expected {
+			t.Errorf("Expected value %q, got %#v", expected, metafields[i])
+		}
+	}
+}
+
+func TestOwnerMetafieldRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		call   func(api *API) error
+		method string
+		uri    string
+	}{
+		{
+			name: "list", status: 200, body: `{"metafields":[{"id":1,"value":42}]}`,
+			call: func(api *API) error {
+				metafields, err := api.OwnerMetafields(MetafieldOwner{Resource: "variant", ID: 3}, &MetafieldsOptions{Namespace: "specs"})
+				if err == nil && metafields[0].Value != "42" {
+					return fmt.Errorf("expected the value 42, got %q", metafields[0].Value)
+				}
+				return err
+			},
+			method: "GET", uri: "/admin/variants/3/metafields.json?namespace=specs",
+		},
+		{
+			name: "list of the shop", status: 200, body: `{"metafields":[]}`,
+			call: func(api *API) error {
+				_, err := api.OwnerMetafields(ShopMetafieldOwner, nil)
+				return err
+			},
+			method: "GET", uri: "/admin/metafields.json",
+		},
+		{
+			name: "count", status: 200, body: `{"count":2}`,
+			call: func(api *API) error {
+				count, err := api.OwnerMetafieldsCount(MetafieldOwner{Resource: "article", ID: 5, BlogID: 4}, nil)
+				if err == nil && count != 2 {
+					return fmt.Errorf("expected 2 metafields, got %d", count)
+				}
+				return err
+			},
+			method: "GET", uri: "/admin/blogs/4/articles/5/metafields/count.json",
+		},
+		{
+			name: "create", status: 201, body: `{"metafield":{"id":8}}`,
+			call: func(api *API) error {
+				metafield := api.NewOwnerMetafield(MetafieldOwner{Resource: "article", ID: 5, BlogID: 4})
+				metafield.Namespace, metafield.Key = "specs", "author"
+				return metafield.Save()
+			},
+			method: "POST", uri: "/admin/blogs/4/articles/5/metafields.json",
+		},
+		{
+			name: "update", status: 200, body: `{"metafield":{"id":8}}`,
+			call: func(api *API) error {
+				metafield := api.NewOwnerMetafield(MetafieldOwner{Resource: "location", ID: 6})
+				metafield.Id = 8
+				return metafield.Save()
+			},
+			method: "PUT", uri: "/admin/metafields/8.json",
+		},
+		{
+			name: "delete", status: 200, body: `{}`,
+			call: func(api *API) error {
+				return (&Metafield{Id: 8, api: api}).Delete()
+			},
+			method: "DELETE", uri: "/admin/metafields/8.json",
+		},
+	}
+
+	for _, test := range tests {
+		api, recorded := resourceServer(t, test.status, test.body)
+
+		if err := test.call(api); err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if recorded.Method != test.method || recorded.URI != test.uri {
+			t.Errorf("%s: expected %s %s, got %s %s", test.name, test.method, test.uri, recorded.Method, recorded.URI)
+		}
+	}
+}
+
+func TestMetafieldOwnerInvalid(t *testing.T) {
+	api, recorded := resourceServer(t, 200, `{"metafields":[]}`)
+
+	owners := []MetafieldOwner{
+		{Resource: "article", ID: 5},
+		{Resource: "gift_card", ID: 1},
+	}
+	for _, owner := range owners {
+		if _, err := api.OwnerMetafields(owner, nil); err == nil {
+			t.Errorf("%s: expected an error", owner.Resource)
+		}
+		if err := api.NewOwnerMetafield(owner).Save(); err == nil {
+			t.Errorf("%s: expected an error saving", owner.Resource)
+		}
+	}
+	if recorded.Method != "" {
+		t.Errorf("Expected no request, got %s %s", recorded.Method, recorded.URI)
+	}
+}
@@ -0,0 +1,279 @@
+package shopify
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The types of metafield values. Any of them but json can be made a list by
+// prefixing it with MetafieldListPrefix, e.g. list.single_line_text_field.
+const (
+	MetafieldTypeSingleLineText      = "single_line_text_field"
+	MetafieldTypeMultiLineText       = "multi_line_text_field"
+	MetafieldTypeNumberInteger       = "number_integer"
+	MetafieldTypeNumberDecimal       = "number_decimal"
+	MetafieldTypeBoolean             = "boolean"
+	MetafieldTypeJSON                = "json"
+	MetafieldTypeDate                = "date"
+	MetafieldTypeDateTime            = "date_time"
+	MetafieldTypeURL                 = "url"
+	MetafieldTypeColor               = "color"
+	MetafieldTypeProductReference    = "product_reference"
+	MetafieldTypeVariantReference    = "variant_reference"
+	MetafieldTypeCollectionReference = "collection_reference"
+	MetafieldTypePageReference       = "page_reference"
+	MetafieldTypeFileReference       = "file_reference"
+	MetafieldTypeCustomerReference   = "customer_reference"
+	MetafieldTypeMetaobjectReference = "metaobject_reference"
+
+	MetafieldListPrefix = "list."
+)
+
+const metafieldDateLayout = "2006-01-02"
+
+// referenceTypes are the GraphQL types of the resources referenced by
+// metafields, for references given as numeric IDs.
+var referenceTypes = map[string]string{
+	MetafieldTypeProductReference:    "Product",
+	MetafieldTypeVariantReference:    "ProductVariant",
+	MetafieldTypeCollectionReference: "Collection",
+	MetafieldTypePageReference:       "Page",
+	MetafieldTypeCustomerReference:   "Customer",
+	MetafieldTypeMetaobjectReference: "Metaobject",
+}
+
+// SetValue sets the Type of the metafield to typ, and its Value to v encoded
+// for that type:
+//
+//	text, url, color          string
+//	number_integer            any integer
+//	number_decimal            float32, float64, or a string holding a number
+//	boolean                   bool
+//	date, date_time           time.Time, or a string in Shopify's format
+//	*_reference               the GID as a string, or the numeric ID of a
+//	                          product, variant, collection, page, customer
+//	                          or metaobject
+//	json                      anything encoding/json can marshal
+//	list.*                    a slice of the values of the item type
+func (obj *Metafield) SetValue(typ string, v interface{}) error {
+	value, err := EncodeMetafieldValue(typ, v)
+	if err != nil {
+		return err
+	}
+	obj.Type = typ
+	obj.ValueType = ""
+	obj.Value = value
+	return nil
+}
+
+// DecodeValue decodes the Value of the metafield according to its Type into
+// v, a pointer to a value of a type SetValue accepts for it. References may
+// be decoded into a string for the GID, or an integer for the numeric ID.
+func (obj *Metafield) DecodeValue(v interface{}) error {
+	return DecodeMetafieldValue(obj.Type, obj.Value, v)
+}
+
+// EncodeMetafieldValue returns v encoded as a metafield value of type typ.
+func EncodeMetafieldValue(typ string, v interface{}) (string, error) {
+	if typ == MetafieldTypeJSON {
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+
+	if item, ok := listItemType(typ); ok {
+		list := reflect.ValueOf(v)
+		if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+			return "", fmt.Errorf("metafield type %s needs a slice, got %T", typ, v)
+		}
+		items := make([]interface{}, list.Len())
+		for i := range items {
+			s, err := encodeScalar(item, list.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+			if item == MetafieldTypeNumberInteger || item == MetafieldTypeBoolean {
+				items[i] = json.RawMessage(s)
+			}
+		}
+		data, err := json.Marshal(items)
+		return string(data), err
+	}
+
+	return encodeScalar(typ, v)
+}
+
+// DecodeMetafieldValue decodes value, a metafield value of type typ, into v.
+func DecodeMetafieldValue(typ, value string, v interface{}) error {
+	dst := reflect.ValueOf(v)
+	if dst.Kind() != reflect.Ptr || dst.IsNil() {
+		return fmt.Errorf("metafield values decode into a non-nil pointer, got %T", v)
+	}
+	if typ == MetafieldTypeJSON {
+		return json.Unmarshal([]byte(value), v)
+	}
+
+	if item, ok := listItemType(typ); ok {
+		list := dst.Elem()
+		if list.Kind() != reflect.Slice {
+			return fmt.Errorf("metafield type %s decodes into a slice, got %T", typ, v)
+		}
+		raws := []json.RawMessage{}
+		if err := json.Unmarshal([]byte(value), &raws); err != nil {
+			return fmt.Errorf("metafield type %s: %v", typ, err)
+		}
+		result := reflect.MakeSlice(list.Type(), len(raws), len(raws))
+		for i, raw := range raws {
+			s := string(raw)
+			if strings.HasPrefix(s, `"`) {
+				if err := json.Unmarshal(raw, &s); err != nil {
+					return err
+				}
+			}
+			if err := decodeScalar(item, s, result.Index(i)); err != nil {
+				return err
+			}
+		}
+		list.Set(result)
+		return nil
+	}
+
+	return decodeScalar(typ, value, dst.Elem())
+}
+
+// listItemType returns the type of the items of a list type.
+func listItemType(typ string) (string, bool) {
+	if !strings.HasPrefix(typ, MetafieldListPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(typ, MetafieldListPrefix), true
+}
+
+func encodeScalar(typ string, v interface{}) (string, error) {
+	rv := reflect.ValueOf(v)
+	mismatch := fmt.Errorf("metafield type %s can't hold %T", typ, v)
+
+	switch {
+	case typ == MetafieldTypeNumberInteger:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return strconv.FormatInt(rv.Int(), 10), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return strconv.FormatUint(rv.Uint(), 10), nil
+		}
+	case typ == MetafieldTypeNumberDecimal:
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return strconv.FormatFloat(rv.Float(), 'f', -1, rv.Type().Bits()), nil
+		case reflect.String:
+			if _, err := strconv.ParseFloat(rv.String(), 64); err != nil {
+				return "", fmt.Errorf("metafield type %s: %q isn't a number", typ, rv.String())
+			}
+			return rv.String(), nil
+		}
+	case typ == MetafieldTypeBoolean:
+		if rv.Kind() == reflect.Bool {
+			return strconv.FormatBool(rv.Bool()), nil
+		}
+	case typ == MetafieldTypeDate || typ == MetafieldTypeDateTime:
+		if t, ok := v.(time.Time); ok {
+			if typ == MetafieldTypeDate {
+				return t.Format(metafieldDateLayout), nil
+			}
+			return t.Format(time.RFC3339), nil
+		}
+		if rv.Kind() == reflect.String {
+			return rv.String(), nil
+		}
+	case strings.HasSuffix(typ, "_reference"):
+		switch rv.Kind() {
+		case reflect.String:
+			return rv.String(), nil
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			gidType, ok := referenceTypes[typ]
+			if !ok {
+				return "", fmt.Errorf("metafield type %s needs a GID", typ)
+			}
+			return fmt.Sprintf("gid://shopify/%s/%d", gidType, rv.Int()), nil
+		}
+	default:
+		if rv.Kind() == reflect.String {
+			return rv.String(), nil
+		}
+	}
+	return "", mismatch
+}
+
+func decodeScalar(typ, s string, dst reflect.Value) error {
+	mismatch := fmt.Errorf("metafield type %s can't be decoded into %s", typ, dst.Type())
+
+	if dst.Kind() == reflect.String {
+		dst.SetString(s)
+		return nil
+	}
+
+	switch {
+	case typ == MetafieldTypeNumberInteger:
+		switch dst.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(s, 10, dst.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("metafield type %s: %v", typ, err)
+			}
+			dst.SetInt(n)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, err := strconv.ParseUint(s, 10, dst.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("metafield type %s: %v", typ, err)
+			}
+			dst.SetUint(n)
+			return nil
+		}
+	case typ == MetafieldTypeNumberDecimal:
+		if dst.Kind() == reflect.Float32 || dst.Kind() == reflect.Float64 {
+			f, err := strconv.ParseFloat(s, dst.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("metafield type %s: %v", typ, err)
+			}
+			dst.SetFloat(f)
+			return nil
+		}
+	case typ == MetafieldTypeBoolean:
+		if dst.Kind() == reflect.Bool {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("metafield type %s: %v", typ, err)
+			}
+			dst.SetBool(b)
+			return nil
+		}
+	case typ == MetafieldTypeDate || typ == MetafieldTypeDateTime:
+		if dst.Type() == reflect.TypeOf(time.Time{}) {
+			layout := time.RFC3339
+			if typ == MetafieldTypeDate {
+				layout = metafieldDateLayout
+			}
+			t, err := time.Parse(layout, s)
+			if err != nil {
+				return fmt.Errorf("metafield type %s: %v", typ, err)
+			}
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		}
+	case strings.HasSuffix(typ, "_reference"):
+		if dst.Kind() == reflect.Int || dst.Kind() == reflect.Int64 {
+			n, err := strconv.ParseInt(s[strings.LastIndex(s, "/")+1:], 10, 64)
+			if err != nil {
+				return fmt.Errorf("metafield type %s: %q has no numeric ID", typ, s)
+			}
+			dst.SetInt(n)
+			return nil
+		}
+	}
+	return mismatch
+}
@@ -0,0 +1,502 @@
+package shopify
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// MetaobjectDefinition declares a type of metaobjects, such as size charts,
+// and the fields of its entries. Metaobjects are only available through the
+// GraphQL API, and their IDs are GIDs.
+type MetaobjectDefinition struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	// Type identifies the definition in the metaobjects of the shop, e.g.
+	// size_chart.
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	// DisplayNameKey is the key of the field used as the display name of
+	// the entries.
+	DisplayNameKey   string                      `json:"displayNameKey,omitempty"`
+	FieldDefinitions []MetaobjectFieldDefinition `json:"fieldDefinitions"`
+	api              *API
+}
+
+// MetaobjectFieldDefinition declares a field of the entries of a metaobject
+// definition. Its Type is one of the metafield types.
+type MetaobjectFieldDefinition struct {
+	Key         string                `json:"key"`
+	Name        string                `json:"name,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Type        string                `json:"type"`
+	Required    bool                  `json:"required"`
+	Validations []MetafieldValidation `json:"validations,omitempty"`
+}
+
+// Metaobject is an entry of a metaobject definition.
+type Metaobject struct {
+	ID string `json:"id,omitempty"`
+	// Type is the type of the definition of the entry.
+	Type string `json:"type"`
+	// Handle is unique among the entries of the type, and generated from
+	// the display name if empty.
+	Handle      string            `json:"handle,omitempty"`
+	DisplayName string            `json:"displayName,omitempty"`
+	Fields      []MetaobjectField `json:"fields"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+	api         *API
+}
+
+// MetaobjectField is the value of a field of a metaobject, encoded as a
+// metafield value of its Type.
+type MetaobjectField struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type,omitempty"`
+}
+
+const metaobjectDefinitionFields = `id name type description displayNameKey
+	fieldDefinitions { key name description required type { name } validations { name value } }`
+
+const metaobjectFields = `id type handle displayName updatedAt
+	fields { key value type }`
+
+func (obj *MetaobjectDefinition) setAPI(api *API) { obj.api = api }
+
+func (obj *Metaobject) setAPI(api *API) { obj.api = api }
+
+// UnmarshalJSON decodes a field definition, whose type is an object in
+// GraphQL responses.
+func (obj *MetaobjectFieldDefinition) UnmarshalJSON(data []byte) error {
+	type metaobjectFieldDefinition MetaobjectFieldDefinition
+	aux := struct {
+		*metaobjectFieldDefinition
+		Type json.RawMessage `json:"type"`
+	}{metaobjectFieldDefinition: (*metaobjectFieldDefinition)(obj)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	typ, err := graphQLTypeName(aux.Type)
+	obj.Type = typ
+	return err
+}
+
+// UnmarshalJSON decodes a metaobject field, whose value is null when unset.
+func (obj *MetaobjectField) UnmarshalJSON(data []byte) error {
+	type metaobjectField MetaobjectField
+	aux := struct {
+		*metaobjectField
+		Value *string `json:"value"`
+	}{metaobjectField: (*metaobjectField)(obj)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	obj.Value = ""
+	if aux.Value != nil {
+		obj.Value = *aux.Value
+	}
+	return nil
+}
+
+// MetaobjectDefinitions returns the metaobject definitions of the shop.
+func (api *API) MetaobjectDefinitions() ([]*MetaobjectDefinition, error) {
+	query := `query($after: String) {
+  metaobjectDefinitions(first: 250, after: $after) {
+    nodes { ` + metaobjectDefinitionFields + ` }
+    pageInfo { hasNextPage endCursor }
+  }
+}`
+	return graphQLNodes[MetaobjectDefinition](api, query, "metaobjectDefinitions", nil)
+}
+
+// MetaobjectDefinition returns the metaobject definition with the GID id.
+func (api *API) MetaobjectDefinition(id string) (*MetaobjectDefinition, error) {
+	query := `query($id: ID!) { metaobjectDefinition(id: $id) { ` + metaobjectDefinitionFields + ` } }`
+	return api.metaobjectDefinition(query, "metaobjectDefinition", map[string]interface{}{"id": id})
+}
+
+// MetaobjectDefinitionByType returns the metaobject definition of typ.
+func (api *API) MetaobjectDefinitionByType(typ string) (*MetaobjectDefinition, error) {
+	query := `query($type: String!) { metaobjectDefinitionByType(type: $type) { ` + metaobjectDefinitionFields + ` } }`
+	return api.metaobjectDefinition(query, "metaobjectDefinitionByType", map[string]interface{}{"type": typ})
+}
+
+func (api *API) metaobjectDefinition(query, field string, variables map[string]interface{}) (*MetaobjectDefinition, error) {
+	data := map[string]*MetaobjectDefinition{}
+	if err := api.GraphQL(query, variables, &data); err != nil {
+		return nil, err
+	}
+	definition := data[field]
+	if definition == nil {
+		return nil, fmt.Errorf("metaobject definition %v: %w", variables, ErrNotFound)
+	}
+	definition.api = api
+	return definition, nil
+}
+
+// NewMetaobjectDefinition returns a new metaobject definition of typ.
+func (api *API) NewMetaobjectDefinition(typ string) *MetaobjectDefinition {
+	return &MetaobjectDefinition{Type: typ, api: api}
+}
+
+// Save creates the definition with its field definitions if it has no ID.
+// Otherwise it updates its name, description and display name key; use
+// AddFieldDefinition and RemoveFieldDefinition to change its fields.
+func (obj *MetaobjectDefinition) Save() error {
+	definition := map[string]interface{}{
+		"name":        obj.Name,
+		"description": obj.Description,
+	}
+	if obj.DisplayNameKey != "" {
+		definition["displayNameKey"] = obj.DisplayNameKey
+	}
+
+	if obj.ID == "" {
+		fields := []map[string]interface{}{}
+		for _, field := range obj.FieldDefinitions {
+			fields = append(fields, field.input())
+		}
+		definition["type"] = obj.Type
+		definition["fieldDefinitions"] = fields
+		mutation := `mutation($definition: MetaobjectDefinitionCreateInput!) {
+  metaobjectDefinitionCreate(definition: $definition) {
+    metaobjectDefinition { ` + metaobjectDefinitionFields + ` }
+    userErrors { field message code }
+  }
+}`
+		return obj.mutate(mutation, "metaobjectDefinitionCreate", map[string]interface{}{"definition": definition})
+	}
+	return obj.update(definition)
+}
+
+// AddFieldDefinition adds field to the fields of the saved definition.
+func (obj *MetaobjectDefinition) AddFieldDefinition(field MetaobjectFieldDefinition) error {
+	return obj.update(map[string]interface{}{
+		"fieldDefinitions": []interface{}{map[string]interface{}{"create": field.input()}},
+	})
+}
+
+// RemoveFieldDefinition removes the field with key from the fields of the
+// saved definition, and from its entries.
+func (obj *MetaobjectDefinition) RemoveFieldDefinition(key string) error {
+	return obj.update(map[string]interface{}{
+		"fieldDefinitions": []interface{}{map[string]interface{}{"delete": map[string]interface{}{"key": key}}},
+	})
+}
+
+// Delete deletes the definition and all its entries.
+func (obj *MetaobjectDefinition) Delete() error {
+	mutation := `mutation($id: ID!) {
+  metaobjectDefinitionDelete(id: $id) {
+    deletedId
+    userErrors { field message code }
+  }
+}`
+	return obj.api.mutate(mutation, "metaobjectDefinitionDelete", map[string]interface{}{"id": obj.ID}, nil)
+}
+
+// FieldDefinition returns the definition of the field with key, or nil.
+func (obj *MetaobjectDefinition) FieldDefinition(key string) *MetaobjectFieldDefinition {
+	for i := range obj.FieldDefinitions {
+		if obj.FieldDefinitions[i].Key == key {
+			return &obj.FieldDefinitions[i]
+		}
+	}
+	return nil
+}
+
+func (obj *MetaobjectDefinition) update(definition map[string]interface{}) error {
+	mutation := `mutation($id: ID!, $definition: MetaobjectDefinitionUpdateInput!) {
+  metaobjectDefinitionUpdate(id: $id, definition: $definition) {
+    metaobjectDefinition { ` + metaobjectDefinitionFields + ` }
+    userErrors { field message code }
+  }
+}`
+	return obj.mutate(mutation, "metaobjectDefinitionUpdate", map[string]interface{}{"id": obj.ID, "definition": definition})
+}
+
+// mutate runs a mutation returning the definition, and replaces obj with it.
+func (obj *MetaobjectDefinition) mutate(mutation, name string, variables map[string]interface{}) error {
+	payload := struct {
+		Definition *MetaobjectDefinition `json:"metaobjectDefinition"`
+	}{}
+	if err := obj.api.mutate(mutation, name, variables, &payload); err != nil {
+		return err
+	}
+	if payload.Definition == nil {
+		return fmt.Errorf("graphql: %s returned no metaobjectDefinition", name)
+	}
+	api := obj.api
+	*obj = *payload.Definition
+	obj.api = api
+	return nil
+}
+
+func (field MetaobjectFieldDefinition) input() map[string]interface{} {
+	input := map[string]interface{}{
+		"key":      field.Key,
+		"type":     field.Type,
+		"required": field.Required,
+	}
+	if field.Name != "" {
+		input["name"] = field.Name
+	}
+	if field.Description != "" {
+		input["description"] = field.Description
+	}
+	if len(field.Validations) > 0 {
+		input["validations"] = field.Validations
+	}
+	return input
+}
+
+// Metaobjects returns the entries of the metaobject definition of typ.
+func (api *API) Metaobjects(typ string) ([]*Metaobject, error) {
+	query := `query($type: String!, $after: String) {
+  metaobjects(type: $type, first: 250, after: $after) {
+    nodes { ` + metaobjectFields + ` }
+    pageInfo { hasNextPage endCursor }
+  }
+}`
+	return graphQLNodes[Metaobject](api, query, "metaobjects", map[string]interface{}{"type": typ})
+}
+
+// Metaobject returns the metaobject with the GID id.
+func (api *API) Metaobject(id string) (*Metaobject, error) {
+	query := `query($id: ID!) { metaobject(id: $id) { ` + metaobjectFields + ` } }`
+	return api.metaobject(query, "metaobject", map[string]interface{}{"id": id})
+}
+
+// MetaobjectByHandle returns the metaobject of typ with handle.
+func (api *API) MetaobjectByHandle(typ, handle string) (*Metaobject, error) {
+	query := `query($handle: MetaobjectHandleInput!) { metaobjectByHandle(handle: $handle) { ` + metaobjectFields + ` } }`
+	return api.metaobject(query, "metaobjectByHandle", map[string]interface{}{
+		"handle": map[string]interface{}{"type": typ, "handle": handle},
+	})
+}
+
+func (api *API) metaobject(query, field string, variables map[string]interface{}) (*Metaobject, error) {
+	data := map[string]*Metaobject{}
+	if err := api.GraphQL(query, variables, &data); err != nil {
+		return nil, err
+	}
+	metaobject := data[field]
+	if metaobject == nil {
+		return nil, fmt.Errorf("metaobject %v: %w", variables, ErrNotFound)
+	}
+	metaobject.api = api
+	return metaobject, nil
+}
+
+// NewMetaobject returns a new entry of the metaobject definition of typ.
+func (api *API) NewMetaobject(typ string) *Metaobject {
+	return &Metaobject{Type: typ, api: api}
+}
+
+// Save creates the metaobject if it has no ID, and updates its handle and
+// fields otherwise.
+func (obj *Metaobject) Save() error {
+	fields := []map[string]interface{}{}
+	for _, field := range obj.Fields {
+		fields = append(fields, map[string]interface{}{"key": field.Key, "value": field.Value})
+	}
+	metaobject := map[string]interface{}{"fields": fields}
+	if obj.Handle != "" {
+		metaobject["handle"] = obj.Handle
+	}
+
+	if obj.ID == "" {
+		metaobject["type"] = obj.Type
+		mutation := `mutation($metaobject: MetaobjectCreateInput!) {
+  metaobjectCreate(metaobject: $metaobject) {
+    metaobject { ` + metaobjectFields + ` }
+    userErrors { field message code }
+  }
+}`
+		return obj.mutate(mutation, "metaobjectCreate", map[string]interface{}{"metaobject": metaobject})
+	}
+
+	mutation := `mutation($id: ID!, $metaobject: MetaobjectUpdateInput!) {
+  metaobjectUpdate(id: $id, metaobject: $metaobject) {
+    metaobject { ` + metaobjectFields + ` }
+    userErrors { field message code }
+  }
+}`
+	return obj.mutate(mutation, "metaobjectUpdate", map[string]interface{}{"id": obj.ID, "metaobject": metaobject})
+}
+
+// Delete deletes the metaobject.
+func (obj *Metaobject) Delete() error {
+	mutation := `mutation($id: ID!) {
+  metaobjectDelete(id: $id) {
+    deletedId
+    userErrors { field message code }
+  }
+}`
+	return obj.api.mutate(mutation, "metaobjectDelete", map[string]interface{}{"id": obj.ID}, nil)
+}
+
+// mutate runs a mutation returning the metaobject, and replaces obj with it.
+func (obj *Metaobject) mutate(mutation, name string, variables map[string]interface{}) error {
+	payload := struct {
+		Metaobject *Metaobject `json:"metaobject"`
+	}{}
+	if err := obj.api.mutate(mutation, name, variables, &payload); err != nil {
+		return err
+	}
+	if payload.Metaobject == nil {
+		return fmt.Errorf("graphql: %s returned no metaobject", name)
+	}
+	api := obj.api
+	*obj = *payload.Metaobject
+	obj.api = api
+	return nil
+}
+
+// Field returns the field with key, or nil if the metaobject has none.
+func (obj *Metaobject) Field(key string) *MetaobjectField {
+	for i := range obj.Fields {
+		if obj.Fields[i].Key == key {
+			return &obj.Fields[i]
+		}
+	}
+	return nil
+}
+
+// SetField sets the field with key to v encoded as typ, as
+// Metafield.SetValue does.
+func (obj *Metaobject) SetField(key, typ string, v interface{}) error {
+	value, err := EncodeMetafieldValue(typ, v)
+	if err != nil {
+		return fmt.Errorf("metaobject field %s: %w", key, err)
+	}
+	if field := obj.Field(key); field != nil {
+		field.Value, field.Type = value, typ
+		return nil
+	}
+	obj.Fields = append(obj.Fields, MetaobjectField{Key: key, Value: value, Type: typ})
+	return nil
+}
+
+// SetFields sets the fields of the metaobject from the struct v, or a
+// pointer to it. Only the struct fields tagged with the key of a metaobject
+// field are set:
+//
+//	type SizeChart struct {
+//		Title string   `metaobject:"title"`
+//		Sizes []string `metaobject:"sizes"`
+//		Chest float64  `metaobject:"chest_cm,number_decimal,omitempty"`
+//	}
+//
+// The metafield type after the key is optional. Without it, the type of the
+// metaobject's existing field is used, or else one is inferred from the Go
+// type: text for strings, integers, decimals, booleans, date_time for
+// time.Time, lists for slices of those, and json for anything else. With
+// omitempty, zero values are left out.
+func (obj *Metaobject) SetFields(v interface{}) error {
+	return forMetaobjectFields(v, func(tag metaobjectTag, value reflect.Value) error {
+		if tag.omitEmpty && value.IsZero() {
+			return nil
+		}
+		typ := tag.typ
+		if field := obj.Field(tag.key); typ == "" && field != nil && field.Type != "" {
+			typ = field.Type
+		}
+		if typ == "" {
+			typ = inferMetafieldType(value.Type())
+		}
+		return obj.SetField(tag.key, typ, value.Interface())
+	})
+}
+
+// DecodeFields decodes the fields of the metaobject into v, a pointer to a
+// struct tagged as for SetFields. Struct fields without a metaobject field
+// are left unchanged.
+func (obj *Metaobject) DecodeFields(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("metaobject fields decode into a pointer to a struct, got %T", v)
+	}
+	return forMetaobjectFields(v, func(tag metaobjectTag, value reflect.Value) error {
+		field := obj.Field(tag.key)
+		if field == nil || field.Value == "" {
+			return nil
+		}
+		typ := field.Type
+		if typ == "" {
+			typ = tag.typ
+		}
+		if typ == "" {
+			typ = inferMetafieldType(value.Type())
+		}
+		if err := DecodeMetafieldValue(typ, field.Value, value.Addr().Interface()); err != nil {
+			return fmt.Errorf("metaobject field %s: %w", tag.key, err)
+		}
+		return nil
+	})
+}
+
+type metaobjectTag struct {
+	key       string
+	typ       string
+	omitEmpty bool
+}
+
+// forMetaobjectFields calls f with the tag and value of each tagged field of
+// the struct v, or of the struct v points to.
+func forMetaobjectFields(v interface{}, f func(tag metaobjectTag, value reflect.Value) error) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("metaobject fields map to a struct, got %T", v)
+	}
+
+	for i := 0; i < rv.NumField(); i++ {
+		sf := rv.Type().Field(i)
+		name, ok := sf.Tag.Lookup("metaobject")
+		if !ok || name == "-" || sf.PkgPath != "" {
+			continue
+		}
+		parts := strings.Split(name, ",")
+		tag := metaobjectTag{key: parts[0]}
+		for _, part := range parts[1:] {
+			if part == "omitempty" {
+				tag.omitEmpty = true
+			} else {
+				tag.typ = part
+			}
+		}
+		if err := f(tag, rv.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inferMetafieldType returns the metafield type of values of the Go type t.
+func inferMetafieldType(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Time{}) {
+		return MetafieldTypeDateTime
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return MetafieldTypeSingleLineText
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return MetafieldTypeNumberInteger
+	case reflect.Float32, reflect.Float64:
+		return MetafieldTypeNumberDecimal
+	case reflect.Bool:
+		return MetafieldTypeBoolean
+	case reflect.Slice:
+		if item := inferMetafieldType(t.Elem()); item != MetafieldTypeJSON {
+			return MetafieldListPrefix + item
+		}
+	}
+	return MetafieldTypeJSON
+}
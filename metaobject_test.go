@@ -0,0 +1,133 @@
+package shopify
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sizeChart struct {
+	Title     string    `metaobject:"title"`
+	Sizes     []string  `metaobject:"sizes"`
+	Chest     float64   `metaobject:"chest_cm,omitempty"`
+	Stretch   bool      `metaobject:"stretch"`
+	Product   int64     `metaobject:"product,product_reference,omitempty"`
+	CheckedAt time.Time `metaobject:"checked_at,date"`
+	Notes     string
+}
+
+func TestMetaobjectFields(t *testing.T) {
+	checked := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	metaobject := &Metaobject{Type: "size_chart", Fields: []MetaobjectField{{Key: "title", Type: MetafieldTypeMultiLineText}}}
+	err := metaobject.SetFields(sizeChart{Title: "Tops", Sizes: []string{"S", "M"}, Stretch: true, Product: 7, CheckedAt: checked, Notes: "ignored"})
+	if err != nil {
+		t.Fatalf("Error setting fields: %v", err)
+	}
+
+	expected := []MetaobjectField{
+		{Key: "title", Value: "Tops", Type: MetafieldTypeMultiLineText},
+		{Key: "sizes", Value: `["S","M"]`, Type: "list." + MetafieldTypeSingleLineText},
+		{Key: "stretch", Value: "true", Type: MetafieldTypeBoolean},
+		{Key: "product", Value: "gid://shopify/Product/7", Type: MetafieldTypeProductReference},
+		{Key: "checked_at", Value: "2024-05-01", Type: MetafieldTypeDate},
+	}
+	if !reflect.DeepEqual(metaobject.Fields, expected) {
+		t.Errorf("Expected fields %#v, got %#v", expected, metaobject.Fields)
+	}
+
+	metaobject.Fields = append(metaobject.Fields, MetaobjectField{Key: "chest_cm", Value: "96.5", Type: MetafieldTypeNumberDecimal})
+	chart := sizeChart{Notes: "kept"}
+	if err := metaobject.DecodeFields(&chart); err != nil {
+		t.Fatalf("Error decoding fields: %v", err)
+	}
+	want := sizeChart{Title: "Tops", Sizes: []string{"S", "M"}, Chest: 96.5, Stretch: true, Product: 7, CheckedAt: checked, Notes: "kept"}
+	if !reflect.DeepEqual(chart, want) {
+		t.Errorf("Expected %#v, got %#v", want, chart)
+	}
+
+	if err := metaobject.DecodeFields(chart); err == nil {
+		t.Errorf("Expected an error decoding into a struct value")
+	}
+	if err := metaobject.SetField("sizes", MetafieldTypeNumberInteger, "M"); err == nil || !strings.Contains(err.Error(), "sizes") {
+		t.Errorf("Expected an error naming the field, got %v", err)
+	}
+}
+
+func TestMetaobjects(t *testing.T) {
+	api, requests := graphQLServer(t, func(req graphQLRequest) string {
+		switch {
+		case strings.Contains(req.Query, "metaobjectDefinitionCreate"):
+			return `{"metaobjectDefinitionCreate":{"metaobjectDefinition":{"id":"gid://shopify/MetaobjectDefinition/1","type":"size_chart","name":"Size chart",
+				"fieldDefinitions":[{"key":"title","type":{"name":"single_line_text_field"},"required":true}]},"userErrors":[]}}`
+		case strings.Contains(req.Query, "metaobjectDefinitionUpdate"):
+			return `{"metaobjectDefinitionUpdate":{"metaobjectDefinition":{"id":"gid://shopify/MetaobjectDefinition/1","type":"size_chart","name":"Size chart",
+				"fieldDefinitions":[{"key":"title","type":{"name":"single_line_text_field"},"required":true},{"key":"sizes","type":{"name":"list.single_line_text_field"}}]},"userErrors":[]}}`
+		case strings.Contains(req.Query, "metaobjectCreate"):
+			return `{"metaobjectCreate":{"metaobject":{"id":"gid://shopify/Metaobject/5","type":"size_chart","handle":"tops","displayName":"Tops",
+				"fields":[{"key":"title","value":"Tops","type":"single_line_text_field"},{"key":"sizes","value":null,"type":"list.single_line_text_field"}]},"userErrors":[]}}`
+		case strings.Contains(req.Query, "metaobjectUpdate"):
+			return `{"metaobjectUpdate":{"metaobject":null,"userErrors":[{"field":["metaobject","fields","1"],"message":"Value is invalid"}]}}`
+		case strings.Contains(req.Query, "metaobjectByHandle"):
+			return `{"metaobjectByHandle":{"id":"gid://shopify/Metaobject/5","type":"size_chart","handle":"tops","fields":[]}}`
+		case strings.Contains(req.Query, "metaobjects("):
+			return `{"metaobjects":{"nodes":[{"id":"gid://shopify/Metaobject/5","type":"size_chart","handle":"tops","fields":[]}],"pageInfo":{"hasNextPage":false}}}`
+		case strings.Contains(req.Query, "metaobjectDelete"):
+			return `{"metaobjectDelete":{"deletedId":"gid://shopify/Metaobject/5","userErrors":[]}}`
+		}
+		return `{}`
+	})
+
+	definition := api.NewMetaobjectDefinition("size_chart")
+	definition.Name = "Size chart"
+	definition.FieldDefinitions = []MetaobjectFieldDefinition{{Key: "title", Type: MetafieldTypeSingleLineText, Required: true}}
+	if err := definition.Save(); err != nil {
+		t.Fatalf("Error creating definition: %v", err)
+	}
+	if definition.ID != "gid://shopify/MetaobjectDefinition/1" || definition.FieldDefinition("title").Type != MetafieldTypeSingleLineText {
+		t.Errorf("Expected the created definition, got %#v", definition)
+	}
+	if err := definition.AddFieldDefinition(MetaobjectFieldDefinition{Key: "sizes", Type: "list." + MetafieldTypeSingleLineText}); err != nil {
+		t.Fatalf("Error adding field definition: %v", err)
+	}
+	if definition.FieldDefinition("sizes") == nil {
+		t.Errorf("Expected the added field definition, got %#v", definition.FieldDefinitions)
+	}
+	update := (*requests)[1].Variables
+	fields := update["definition"].(map[string]interface{})["fieldDefinitions"].([]interface{})
+	if update["id"] != definition.ID || fields[0].(map[string]interface{})["create"].(map[string]interface{})["key"] != "sizes" {
+		t.Errorf("Expected a create operation for the field, got %v", update)
+	}
+
+	metaobject := api.NewMetaobject("size_chart")
+	if err := metaobject.SetFields(sizeChart{Title: "Tops"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := metaobject.Save(); err != nil {
+		t.Fatalf("Error creating metaobject: %v", err)
+	}
+	if metaobject.ID != "gid://shopify/Metaobject/5" || metaobject.DisplayName != "Tops" || metaobject.Field("sizes").Value != "" || metaobject.api != api {
+		t.Errorf("Expected the created metaobject, got %#v", metaobject)
+	}
+
+	metaobject.SetField("sizes", "list."+MetafieldTypeSingleLineText, []string{"XXXL"})
+	err := metaobject.Save()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Fields["metaobject.fields.1"][0] != "Value is invalid" {
+		t.Errorf("Expected the user errors of the update, got %v", err)
+	}
+
+	if found, err := api.MetaobjectByHandle("size_chart", "tops"); err != nil || found.ID != metaobject.ID {
+		t.Errorf("Expected the metaobject by handle, got %v, %v", found, err)
+	}
+	if all, err := api.Metaobjects("size_chart"); err != nil || len(all) != 1 || all[0].api != api {
+		t.Errorf("Expected the metaobjects of the type, got %v, %v", all, err)
+	}
+	if err := metaobject.Delete(); err != nil {
+		t.Errorf("Error deleting metaobject: %v", err)
+	}
+	if _, err := api.Metaobject("gid://shopify/Metaobject/6"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing metaobject, got %v", err)
+	}
+}
@@ -1,225 +1,157 @@
+// Code generated by shopify-gen from schema/resources.json. DO NOT EDIT.
+
 package shopify
 
 import (
-	"bytes"
-
-	"encoding/json"
-
-	"fmt"
-
 	"time"
 )
 
+// Order is an order placed in the shop.
 type Order struct {
-	BuyerAcceptsMarketing bool `json:"buyer_accepts_marketing"`
-
-	CancelReason string `json:"cancel_reason"`
-
-	CancelledAt string `json:"cancelled_at"`
-
-	CartToken string `json:"cart_token"`
-
-	CheckoutToken string `json:"checkout_token"`
-
-	ClosedAt string `json:"closed_at"`
-
-	Confirmed bool `json:"confirmed"`
-
-	CreatedAt time.Time `json:"created_at"`
-
-	Currency string `json:"currency"`
-
-	Email string `json:"email"`
-
-	FinancialStatus string `json:"financial_status"`
-
-	FulfillmentStatus string `json:"fulfillment_status"`
-
-	Gateway string `json:"gateway"`
-
-	Id int64 `json:"id"`
-
-	LandingSite string `json:"landing_site"`
-
-	LocationId string `json:"location_id"`
-
-	Name string `json:"name"`
-
-	Note string `json:"note"`
-
-	Number int64 `json:"number"`
-
-	ProcessedAt time.Time `json:"processed_at"`
-
-	Reference string `json:"reference"`
-
-	ReferringSite string `json:"referring_site"`
-
-	SourceIdentifier string `json:"source_identifier"`
-
-	SourceName string `json:"source_name"`
-
-	SourceUrl string `json:"source_url"`
-
-	SubtotalPrice time.Time `json:"subtotal_price"`
-
-	TaxesIncluded bool `json:"taxes_included"`
-
-	Test bool `json:"test"`
-
-	Token string `json:"token"`
-
-	TotalDiscounts string `json:"total_discounts"`
-
-	TotalLineItemsPrice time.Time `json:"total_line_items_price"`
-
-	TotalPrice time.Time `json:"total_price"`
-
-	TotalPriceUsd string `json:"total_price_usd"`
-
-	TotalTax string `json:"total_tax"`
-
-	TotalWeight int64 `json:"total_weight"`
-
-	UpdatedAt time.Time `json:"updated_at"`
-
-	UserId string `json:"user_id"`
-
-	BrowserIp string `json:"browser_ip"`
-
-	LandingSiteRef string `json:"landing_site_ref"`
-
-	OrderNumber int64 `json:"order_number"`
-
-	DiscountCodes []interface{} `json:"discount_codes"`
-
-	NoteAttributes []interface{} `json:"note_attributes"`
-
-	ProcessingMethod string `json:"processing_method"`
-
-	Source string `json:"source"`
-
-	CheckoutId int64 `json:"checkout_id"`
-
-	TaxLines []interface{} `json:"tax_lines"`
-
-	Tags string `json:"tags"`
-
-	LineItems []LineItem `json:"line_items"`
-
-	ShippingLines []ShippingLine `json:"shipping_lines"`
-
-	BillingAddress BillingAddress `json:"billing_address"`
-
-	ShippingAddress BillingAddress `json:"shipping_address"`
-
-	Fulfillments []interface{} `json:"fulfillments"`
-
-	ClientDetails ClientDetail `json:"client_details"`
-
-	Refunds []interface{} `json:"refunds"`
-
-	Customer Customer `json:"customer"`
+	Id                       int64                 `json:"id"`
+	AdminGraphqlApiId        string                `json:"admin_graphql_api_id"`
+	AppId                    int64                 `json:"app_id"`
+	BrowserIp                string                `json:"browser_ip"`
+	BuyerAcceptsMarketing    bool                  `json:"buyer_accepts_marketing"`
+	CancelReason             string                `json:"cancel_reason"`
+	CancelledAt              *time.Time            `json:"cancelled_at"`
+	CartToken                string                `json:"cart_token"`
+	CheckoutId               int64                 `json:"checkout_id"`
+	CheckoutToken            string                `json:"checkout_token"`
+	ClientDetails            ClientDetail          `json:"client_details"`
+	ClosedAt                 *time.Time            `json:"closed_at"`
+	ConfirmationNumber       string                `json:"confirmation_number"`
+	Confirmed                bool                  `json:"confirmed"`
+	ContactEmail             string                `json:"contact_email"`
+	CreatedAt                time.Time             `json:"created_at"`
+	Currency                 string                `json:"currency"`
+	CurrentSubtotalPrice     string                `json:"current_subtotal_price"`
+	CurrentSubtotalPriceSet  *PriceSet             `json:"current_subtotal_price_set,omitempty"`
+	CurrentTotalDiscounts    string                `json:"current_total_discounts"`
+	CurrentTotalDiscountsSet *PriceSet             `json:"current_total_discounts_set,omitempty"`
+	CurrentTotalDutiesSet    *PriceSet             `json:"current_total_duties_set,omitempty"`
+	CurrentTotalPrice        string                `json:"current_total_price"`
+	CurrentTotalPriceSet     *PriceSet             `json:"current_total_price_set,omitempty"`
+	CurrentTotalTax          string                `json:"current_total_tax"`
+	CurrentTotalTaxSet       *PriceSet             `json:"current_total_tax_set,omitempty"`
+	Customer                 Customer              `json:"customer"`
+	CustomerLocale           string                `json:"customer_locale"`
+	DeviceId                 int64                 `json:"device_id"`
+	DiscountApplications     []DiscountApplication `json:"discount_applications"`
+	DiscountCodes            []DiscountCode        `json:"discount_codes"`
+	Email                    string                `json:"email"`
+	EstimatedTaxes           bool                  `json:"estimated_taxes"`
+	FinancialStatus          string                `json:"financial_status"`
+	FulfillmentStatus        string                `json:"fulfillment_status"`
+	Fulfillments             []interface{}         `json:"fulfillments"`
+	Gateway                  string                `json:"gateway"`
+	LandingSite              string                `json:"landing_site"`
+	LandingSiteRef           string                `json:"landing_site_ref"`
+	LineItems                []LineItem            `json:"line_items"`
+	LocationId               int64                 `json:"location_id"`
+	MerchantOfRecordAppId    int64                 `json:"merchant_of_record_app_id"`
+	Name                     string                `json:"name"`
+	Note                     string                `json:"note"`
+	NoteAttributes           []NoteAttribute       `json:"note_attributes"`
+	Number                   int64                 `json:"number"`
+	OrderNumber              int64                 `json:"order_number"`
+	OrderStatusUrl           string                `json:"order_status_url"`
+	OriginalTotalDutiesSet   *PriceSet             `json:"original_total_duties_set,omitempty"`
+	PaymentGatewayNames      []string              `json:"payment_gateway_names"`
+	Phone                    string                `json:"phone"`
+	PoNumber                 string                `json:"po_number"`
+	PresentmentCurrency      string                `json:"presentment_currency"`
+	ProcessedAt              time.Time             `json:"processed_at"`
+	ProcessingMethod         string                `json:"processing_method"`
+	Reference                string                `json:"reference"`
+	ReferringSite            string                `json:"referring_site"`
+	Refunds                  []interface{}         `json:"refunds"`
+	BillingAddress           BillingAddress        `json:"billing_address"`
+	ShippingAddress          BillingAddress        `json:"shipping_address"`
+	ShippingLines            []ShippingLine        `json:"shipping_lines"`
+	Source                   string                `json:"source"`
+	SourceIdentifier         string                `json:"source_identifier"`
+	SourceName               string                `json:"source_name"`
+	SourceUrl                string                `json:"source_url"`
+	SubtotalPrice            string                `json:"subtotal_price"`
+	SubtotalPriceSet         *PriceSet             `json:"subtotal_price_set,omitempty"`
+	Tags                     string                `json:"tags"`
+	TaxLines                 []TaxLine             `json:"tax_lines"`
+	TaxesIncluded            bool                  `json:"taxes_included"`
+	Test                     bool                  `json:"test"`
+	Token                    string                `json:"token"`
+	TotalDiscounts           string                `json:"total_discounts"`
+	TotalDiscountsSet        *PriceSet             `json:"total_discounts_set,omitempty"`
+	TotalLineItemsPrice      string                `json:"total_line_items_price"`
+	TotalLineItemsPriceSet   *PriceSet             `json:"total_line_items_price_set,omitempty"`
+	TotalOutstanding         string                `json:"total_outstanding"`
+	TotalPrice               string                `json:"total_price"`
+	TotalPriceSet            *PriceSet             `json:"total_price_set,omitempty"`
+	TotalPriceUsd            string                `json:"total_price_usd"`
+	TotalShippingPriceSet    *PriceSet             `json:"total_shipping_price_set,omitempty"`
+	TotalTax                 string                `json:"total_tax"`
+	TotalTaxSet              *PriceSet             `json:"total_tax_set,omitempty"`
+	TotalTipReceived         string                `json:"total_tip_received"`
+	TotalWeight              int64                 `json:"total_weight"`
+	UpdatedAt                time.Time             `json:"updated_at"`
+	UserId                   int64                 `json:"user_id"`
 
 	api *API
 }
 
-func (api *API) Orders() ([]Order, error) {
-	res, status, err := api.request("/admin/orders.json", "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
+// OrdersOptions filters the orders listed and counted.
+type OrdersOptions struct {
+	IDs               string `url:"ids,omitempty"`
+	Limit             int    `url:"limit,omitempty"`
+	SinceID           int64  `url:"since_id,omitempty"`
+	CreatedAtMin      string `url:"created_at_min,omitempty"`
+	CreatedAtMax      string `url:"created_at_max,omitempty"`
+	UpdatedAtMin      string `url:"updated_at_min,omitempty"`
+	UpdatedAtMax      string `url:"updated_at_max,omitempty"`
+	ProcessedAtMin    string `url:"processed_at_min,omitempty"`
+	ProcessedAtMax    string `url:"processed_at_max,omitempty"`
+	AttributionAppID  string `url:"attribution_app_id,omitempty"`
+	Status            string `url:"status,omitempty"`
+	FinancialStatus   string `url:"financial_status,omitempty"`
+	FulfillmentStatus string `url:"fulfillment_status,omitempty"`
+	Fields            string `url:"fields,omitempty"`
+}
 
-	r := &map[string][]Order{}
-	err = json.NewDecoder(res).Decode(r)
+var orderResource = newRESTResource[Order]("/admin/orders", "order", "orders")
 
-	result := (*r)["orders"]
+func (obj *Order) setAPI(api *API) { obj.api = api }
 
-	if err != nil {
-		return nil, err
-	}
+// Orders returns the orders.
+func (api *API) Orders() ([]Order, error) {
+	return api.OrdersWithOptions(&OrdersOptions{})
+}
 
-	for _, v := range result {
-		v.api = api
-	}
+// OrdersWithOptions returns the orders matching options.
+func (api *API) OrdersWithOptions(options *OrdersOptions) ([]Order, error) {
+	return orderResource.listValues(api, options)
+}
 
-	return result, nil
+// OrdersCount returns the number of orders matching options.
+func (api *API) OrdersCount(options *OrdersOptions) (int, error) {
+	return orderResource.count(api, options)
 }
 
+// Order returns the order with id.
 func (api *API) Order(id int64) (*Order, error) {
-	endpoint := fmt.Sprintf("/admin/orders/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]Order{}
-	err = json.NewDecoder(res).Decode(&r)
-	result := r["order"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+	return orderResource.get(api, id, nil)
 }
 
+// NewOrder returns a new order, to be saved with api.
 func (api *API) NewOrder() *Order {
 	return &Order{api: api}
 }
 
+// Save creates the order if it has no id, and updates it otherwise.
 func (obj *Order) Save() error {
-	endpoint := fmt.Sprintf("/admin/orders/%d.json", obj.Id)
-	method := "PUT"
-	expectedStatus := 201
-
-	if obj.Id == 0 {
-		endpoint = fmt.Sprintf("/admin/orders.json")
-		method = "POST"
-		expectedStatus = 201
-	}
-
-	body := map[string]*Order{}
-	body["order"] = obj
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
-
-	if err != nil {
-		return err
-	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	r := map[string]Order{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	if err != nil {
-		return err
-	}
-
-	*obj = r["order"]
+	return orderResource.save(obj.api, obj.Id, obj, nil)
+}
 
-	return nil
+// Delete deletes the order.
+func (obj *Order) Delete() error {
+	return orderResource.delete(obj.api, obj.Id)
 }
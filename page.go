@@ -1,12 +1,6 @@
 package shopify
 
 import (
-	"bytes"
-
-	"encoding/json"
-
-	"fmt"
-
 	"time"
 )
 
@@ -34,58 +28,16 @@ type Page struct {
 	api *API
 }
 
-func (api *API) Pages() ([]Page, error) {
-	res, status, err := api.request("/admin/pages.json", "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := &map[string][]Page{}
-	err = json.NewDecoder(res).Decode(r)
-
-	result := (*r)["pages"]
+var pageResource = newRESTResource[Page]("/admin/pages", "page", "pages")
 
-	if err != nil {
-		return nil, err
-	}
+func (obj *Page) setAPI(api *API) { obj.api = api }
 
-	for _, v := range result {
-		v.api = api
-	}
-
-	return result, nil
+func (api *API) Pages() ([]Page, error) {
+	return pageResource.listValues(api, nil)
 }
 
 func (api *API) Page(id int64) (*Page, error) {
-	endpoint := fmt.Sprintf("/admin/pages/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]Page{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["page"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+	return pageResource.get(api, id, nil)
 }
 
 func (api *API) NewPage() *Page {
@@ -93,44 +45,5 @@ func (api *API) NewPage() *Page {
 }
 
 func (obj *Page) Save() error {
-	endpoint := fmt.Sprintf("/admin/pages/%d.json", obj.Id)
-	method := "PUT"
-	expectedStatus := 201
-
-	if obj.Id == 0 {
-		endpoint = fmt.Sprintf("/admin/pages.json")
-		method = "POST"
-		expectedStatus = 201
-	}
-
-	body := map[string]*Page{}
-	body["page"] = obj
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
-
-	if err != nil {
-		return err
-	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	r := map[string]Page{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	if err != nil {
-		return err
-	}
-
-	*obj = r["page"]
-
-	return nil
+	return pageResource.save(obj.api, obj.Id, obj, nil)
 }
@@ -0,0 +1,334 @@
+package shopify
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLeakRate is the rate, in calls per second, at which Shopify empties
+// the REST call-limit bucket of a standard shop.
+const DefaultLeakRate = 2.0
+
+// ClientPool hands out one API per shop, built from the offline sessions of
+// a SessionStore, so that call-limit state is kept between calls. Requests
+// of each shop wait for room in a local copy of the shop's REST call-limit
+// bucket, which GraphQL requests don't use, and the number of requests in flight across all shops can be
+// capped.
+type ClientPool struct {
+	// Store holds the offline sessions of the shops.
+	Store SessionStore
+	// MaxConcurrency caps the number of requests in flight across all
+	// shops. Zero means no cap.
+	MaxConcurrency int
+	// LeakRate is the rate, in calls per second, at which the local copy of
+	// each shop's bucket empties. If zero, DefaultLeakRate is used.
+	LeakRate float64
+	// IdleTimeout, if positive, evicts clients which haven't sent a request
+	// for that long.
+	IdleTimeout time.Duration
+	// Transport sends the requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+	// Configure, if set, is called with every new API, e.g. to set its
+	// RetryPolicy, Logger or hooks. It must not replace API.Client, which
+	// enforces the limits of the pool.
+	Configure func(api *API)
+
+	mu      sync.Mutex
+	clients map[string]*pooledClient
+	// loading are the shops whose session is being loaded by Get
+	loading   map[string]*poolLoad
+	sem       chan struct{}
+	lastSweep time.Time
+}
+
+// poolLoad is the loading of a shop's API, which concurrent calls to Get
+// for the same shop wait for.
+type poolLoad struct {
+	done chan struct{}
+	api  *API
+	err  error
+}
+
+type pooledClient struct {
+	api      *API
+	bucket   *rateBucket
+	mu       sync.Mutex
+	lastUsed time.Time
+	inFlight int
+}
+
+// NewClientPool returns a ClientPool for the sessions in store.
+func NewClientPool(store SessionStore) *ClientPool {
+	return &ClientPool{Store: store}
+}
+
+// Get returns the API of shop, building it from the offline session of the
+// shop the first time. The session is loaded without holding up the other
+// shops, and only once for concurrent calls.
+func (p *ClientPool) Get(shop string) (*API, error) {
+	p.mu.Lock()
+	if p.clients == nil {
+		p.clients = map[string]*pooledClient{}
+		p.loading = map[string]*poolLoad{}
+	}
+	if p.sem == nil && p.MaxConcurrency > 0 {
+		p.sem = make(chan struct{}, p.MaxConcurrency)
+	}
+	if p.IdleTimeout > 0 && time.Since(p.lastSweep) > p.IdleTimeout {
+		p.evictIdle()
+	}
+
+	if c, ok := p.clients[shop]; ok {
+		p.mu.Unlock()
+		return c.api, nil
+	}
+	if load, ok := p.loading[shop]; ok {
+		p.mu.Unlock()
+		<-load.done
+		return load.api, load.err
+	}
+	load := &poolLoad{done: make(chan struct{})}
+	p.loading[shop] = load
+	p.mu.Unlock()
+
+	c, err := p.load(shop)
+
+	p.mu.Lock()
+	delete(p.loading, shop)
+	if err == nil {
+		p.clients[shop] = c
+		load.api = c.api
+	}
+	p.mu.Unlock()
+
+	load.err = err
+	close(load.done)
+	return load.api, load.err
+}
+
+// load builds the client of shop from its offline session.
+func (p *ClientPool) load(shop string) (*pooledClient, error) {
+	api, err := LoadAPI(p.Store, shop, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	leakRate := p.LeakRate
+	if leakRate == 0 {
+		leakRate = DefaultLeakRate
+	}
+	c := &pooledClient{
+		api:      api,
+		bucket:   &rateBucket{size: float64(defaultConfig.BucketLimit), leakRate: leakRate},
+		lastUsed: time.Now(),
+	}
+	api.Client = &http.Client{Transport: &poolTransport{pool: p, client: c}}
+	if p.Configure != nil {
+		p.Configure(api)
+	}
+	return c, nil
+}
+
+// Evict removes the client of shop from the pool.
+func (p *ClientPool) Evict(shop string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.clients, shop)
+}
+
+// EvictIdle removes the clients which have no request in flight and haven't
+// sent one for IdleTimeout, returning how many were removed.
+func (p *ClientPool) EvictIdle() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.evictIdle()
+}
+
+func (p *ClientPool) evictIdle() int {
+	p.lastSweep = time.Now()
+	if p.IdleTimeout <= 0 {
+		return 0
+	}
+
+	evicted := 0
+	for shop, c := range p.clients {
+		c.mu.Lock()
+		idle := c.inFlight == 0 && time.Since(c.lastUsed) > p.IdleTimeout
+		c.mu.Unlock()
+		if idle {
+			delete(p.clients, shop)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// Len returns the number of clients in the pool.
+func (p *ClientPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.clients)
+}
+
+// FanOut calls fn with the API of every shop, running at most parallelism
+// calls at once, or all of them if parallelism isn't positive. It waits for
+// every call to return, and returns a *FanOutError if any failed.
+func (p *ClientPool) FanOut(shops []string, parallelism int, fn func(shop string, api *API) error) error {
+	if parallelism <= 0 || parallelism > len(shops) {
+		parallelism = len(shops)
+	}
+
+	var mu sync.Mutex
+	errs := map[string]error{}
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for shop := range work {
+				api, err := p.Get(shop)
+				if err == nil {
+					err = fn(shop, api)
+				}
+				if err != nil {
+					mu.Lock()
+					errs[shop] = err
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, shop := range shops {
+		work <- shop
+	}
+	close(work)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return &FanOutError{Errors: errs}
+	}
+	return nil
+}
+
+// FanOutError is returned by ClientPool.FanOut when calls failed.
+type FanOutError struct {
+	// Errors maps the shops to the error of their call.
+	Errors map[string]error
+}
+
+func (e *FanOutError) Error() string {
+	shops := []string{}
+	for shop := range e.Errors {
+		shops = append(shops, shop)
+	}
+	sort.Strings(shops)
+
+	messages := []string{}
+	for _, shop := range shops {
+		messages = append(messages, fmt.Sprintf("%s: %v", shop, e.Errors[shop]))
+	}
+	return fmt.Sprintf("%d of the shops failed: %s", len(shops), strings.Join(messages, "; "))
+}
+
+// Unwrap returns the errors of every shop, for errors.Is and errors.As.
+func (e *FanOutError) Unwrap() []error {
+	errs := []error{}
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// poolTransport waits for room in the global concurrency cap and, for REST
+// requests, the shop's bucket before sending a request.
+type poolTransport struct {
+	pool   *ClientPool
+	client *pooledClient
+}
+
+func (t *poolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := t.client
+	c.mu.Lock()
+	c.inFlight++
+	c.lastUsed = time.Now()
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.lastUsed = time.Now()
+		c.mu.Unlock()
+	}()
+
+	// wait for the shop's bucket first, not to hold a slot while sleeping.
+	// GraphQL requests are limited by query cost instead.
+	if !strings.HasSuffix(req.URL.Path, "/graphql.json") {
+		c.bucket.take()
+	}
+	if t.pool.sem != nil {
+		t.pool.sem <- struct{}{}
+		defer func() { <-t.pool.sem }()
+	}
+
+	transport := t.pool.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if calls, total := parseAPICallLimit(resp.Header.Get("X-Shopify-Shop-Api-Call-Limit")); total > 0 {
+		c.bucket.sync(calls, total)
+	}
+	return resp, nil
+}
+
+// rateBucket is a local copy of a shop's leaky call-limit bucket, kept in
+// sync with the call-limit header of the responses.
+type rateBucket struct {
+	mu       sync.Mutex
+	size     float64
+	leakRate float64
+	level    float64
+	last     time.Time
+}
+
+func (b *rateBucket) leak(now time.Time) {
+	if !b.last.IsZero() {
+		b.level = math.Max(0, b.level-now.Sub(b.last).Seconds()*b.leakRate)
+	}
+	b.last = now
+}
+
+// take waits until the bucket has room for a call, and adds it.
+func (b *rateBucket) take() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		b.leak(time.Now())
+		if b.level+1 <= b.size {
+			b.level++
+			return
+		}
+		wait := time.Duration((b.level + 1 - b.size) / b.leakRate * float64(time.Second))
+		b.mu.Unlock()
+		time.Sleep(wait)
+		b.mu.Lock()
+	}
+}
+
+// sync replaces the level of the bucket with the one reported by Shopify.
+func (b *rateBucket) sync(calls, size int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.leak(time.Now())
+	b.level = float64(calls)
+	b.size = float64(size)
+}
@@ -0,0 +1,254 @@
+package shopify
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// poolServer answers every request after delay, reporting callLimit, and
+// records the maximum number of requests in flight.
+type poolServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	requests    int
+}
+
+func newPoolServer(t *testing.T, delay time.Duration, callLimit string) *poolServer {
+	s := &poolServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.inFlight++
+		s.requests++
+		if s.inFlight > s.maxInFlight {
+			s.maxInFlight = s.inFlight
+		}
+		s.mu.Unlock()
+
+		time.Sleep(delay)
+
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+		w.Header().Set("X-Shopify-Shop-Api-Call-Limit", callLimit)
+		w.Write([]byte(`{"shop":{"id":1}}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestPool(t *testing.T, srv *poolServer, shops ...string) *ClientPool {
+	store := NewMemorySessionStore()
+	for _, shop := range shops {
+		if err := store.SaveSession(&Session{Shop: shop, AccessToken: "token-" + shop}); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+	}
+	pool := NewClientPool(store)
+	pool.Configure = func(api *API) {
+		api.BaseURL = srv.URL
+	}
+	return pool
+}
+
+func TestClientPoolGet(t *testing.T) {
+	srv := newPoolServer(t, 0, "1/40")
+	pool := newTestPool(t, srv, "a.myshopify.com")
+
+	api, err := pool.Get("a.myshopify.com")
+	if err != nil {
+		t.Fatalf("Error getting client: %v", err)
+	}
+	if api.AccessToken != "token-a.myshopify.com" || api.BaseURL != srv.URL {
+		t.Errorf("Unexpected client %#v", api)
+	}
+	if _, err = api.CurrentShop(); err != nil {
+		t.Fatalf("Error fetching shop: %v", err)
+	}
+
+	again, _ := pool.Get("a.myshopify.com")
+	if again != api {
+		t.Errorf("Expected the client to be reused")
+	}
+	if made, limit := again.CallLimit(); made != 1 || limit != 40 {
+		t.Errorf("Expected call limit state to be kept, got %d/%d", made, limit)
+	}
+
+	if _, err = pool.Get("missing.myshopify.com"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+	if pool.Len() != 1 {
+		t.Errorf("Expected 1 client, got %d", pool.Len())
+	}
+}
+
+// slowSessionStore blocks loading the sessions of slow shops until release
+// is closed, counting the loads.
+type slowSessionStore struct {
+	SessionStore
+	slow    string
+	release chan struct{}
+
+	mu    sync.Mutex
+	loads int
+}
+
+func (s *slowSessionStore) LoadSession(shop string, userID int64) (*Session, error) {
+	s.mu.Lock()
+	s.loads++
+	s.mu.Unlock()
+	if shop == s.slow {
+		<-s.release
+	}
+	return s.SessionStore.LoadSession(shop, userID)
+}
+
+func TestClientPoolGetSlowStore(t *testing.T) {
+	srv := newPoolServer(t, 0, "1/40")
+	pool := newTestPool(t, srv, "a.myshopify.com", "b.myshopify.com")
+	store := &slowSessionStore{SessionStore: pool.Store, slow: "a.myshopify.com", release: make(chan struct{})}
+	pool.Store = store
+
+	results := make(chan *API, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			api, err := pool.Get("a.myshopify.com")
+			if err != nil {
+				t.Errorf("Error getting client: %v", err)
+			}
+			results <- api
+		}()
+	}
+
+	// another shop isn't held up by the slow load
+	done := make(chan struct{})
+	go func() {
+		if _, err := pool.Get("b.myshopify.com"); err != nil {
+			t.Errorf("Error getting client: %v", err)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Get of another shop not to wait for a slow load")
+	}
+
+	close(store.release)
+	first, second := <-results, <-results
+	if first == nil || first != second {
+		t.Errorf("Expected concurrent calls to get the same client")
+	}
+	if store.loads != 2 {
+		t.Errorf("Expected each session to be loaded once, got %d loads", store.loads)
+	}
+}
+
+func TestClientPoolConcurrencyCap(t *testing.T) {
+	srv := newPoolServer(t, 20*time.Millisecond, "1/40")
+	shops := []string{"a.myshopify.com", "b.myshopify.com", "c.myshopify.com", "d.myshopify.com", "e.myshopify.com", "f.myshopify.com"}
+	pool := newTestPool(t, srv, shops...)
+	pool.MaxConcurrency = 2
+
+	err := pool.FanOut(shops, 0, func(shop string, api *API) error {
+		_, err := api.CurrentShop()
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Error fanning out: %v", err)
+	}
+	if srv.requests != len(shops) || srv.maxInFlight > 2 {
+		t.Errorf("Expected %d requests, at most 2 at once, got %d with %d at once", len(shops), srv.requests, srv.maxInFlight)
+	}
+}
+
+func TestClientPoolShopBucket(t *testing.T) {
+	srv := newPoolServer(t, 0, "40/40")
+	pool := newTestPool(t, srv, "a.myshopify.com")
+	pool.LeakRate = 10
+
+	api, _ := pool.Get("a.myshopify.com")
+	if _, err := api.CurrentShop(); err != nil {
+		t.Fatalf("Error fetching shop: %v", err)
+	}
+
+	// the bucket is full, so the next call waits for one call to leak
+	start := time.Now()
+	if _, err := api.CurrentShop(); err != nil {
+		t.Fatalf("Error fetching shop: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("Expected to wait for the bucket, took %s", elapsed)
+	}
+}
+
+func TestClientPoolGraphQLSkipsBucket(t *testing.T) {
+	srv := newPoolServer(t, 0, "40/40")
+	pool := newTestPool(t, srv, "a.myshopify.com")
+	pool.LeakRate = 10
+
+	api, _ := pool.Get("a.myshopify.com")
+	if _, err := api.CurrentShop(); err != nil {
+		t.Fatalf("Error fetching shop: %v", err)
+	}
+
+	// the bucket is full, but only limits REST calls
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := api.GraphQL(`{ shop { id } }`, nil, nil); err != nil {
+			t.Fatalf("Error querying shop: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed >= 80*time.Millisecond {
+		t.Errorf("Expected GraphQL requests not to wait for the bucket, took %s", elapsed)
+	}
+}
+
+func TestClientPoolEvictIdle(t *testing.T) {
+	srv := newPoolServer(t, 0, "1/40")
+	pool := newTestPool(t, srv, "a.myshopify.com", "b.myshopify.com")
+	pool.IdleTimeout = 50 * time.Millisecond
+
+	a, _ := pool.Get("a.myshopify.com")
+	pool.Get("b.myshopify.com")
+	time.Sleep(30 * time.Millisecond)
+	a.CurrentShop()
+	time.Sleep(30 * time.Millisecond)
+
+	if n := pool.EvictIdle(); n != 1 || pool.Len() != 1 {
+		t.Errorf("Expected only the idle client to be evicted, evicted %d, %d left", n, pool.Len())
+	}
+	if again, _ := pool.Get("a.myshopify.com"); again != a {
+		t.Errorf("Expected the active client to be kept")
+	}
+}
+
+func TestClientPoolFanOutErrors(t *testing.T) {
+	srv := newPoolServer(t, 0, "1/40")
+	pool := newTestPool(t, srv, "a.myshopify.com", "b.myshopify.com")
+	failed := errors.New("failed")
+
+	err := pool.FanOut([]string{"a.myshopify.com", "b.myshopify.com", "c.myshopify.com"}, 2, func(shop string, api *API) error {
+		if shop == "b.myshopify.com" {
+			return failed
+		}
+		return nil
+	})
+
+	var fanOutErr *FanOutError
+	if !errors.As(err, &fanOutErr) || len(fanOutErr.Errors) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", err)
+	}
+	if !errors.Is(err, failed) || !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected the errors of every shop to be wrapped, got %v", err)
+	}
+	if fanOutErr.Errors["b.myshopify.com"] != failed {
+		t.Errorf("Unexpected errors %v", fanOutErr.Errors)
+	}
+}
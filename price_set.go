@@ -0,0 +1,15 @@
+// Code generated by shopify-gen from schema/resources.json. DO NOT EDIT.
+
+package shopify
+
+// PriceSet is an amount in the shop's currency and in the currency the customer paid in.
+type PriceSet struct {
+	PresentmentMoney Money `json:"presentment_money"`
+	ShopMoney        Money `json:"shop_money"`
+}
+
+// Money is an amount in a currency.
+type Money struct {
+	Amount       string `json:"amount"`
+	CurrencyCode string `json:"currency_code"`
+}
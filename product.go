@@ -1,11 +1,8 @@
 package shopify
 
 import (
-	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"strconv"
 
 	"github.com/google/go-querystring/query"
 )
@@ -54,32 +51,12 @@ type ProductsOptions struct {
 	Fields          string `url:"fields,omitempty"`
 }
 
-func (api *API) Products(options *ProductsOptions) ([]*Product, error) {
-
-	qs := encodeOptions(options)
-	endpoint := fmt.Sprintf("/admin/products.json?%v", qs)
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := &map[string][]*Product{}
-	err = json.NewDecoder(res).Decode(r)
-	if err != nil {
-		return nil, err
-	}
+var productResource = newRESTResource[Product]("/admin/products", "product", "products")
 
-	result := (*r)["products"]
-	for _, v := range result {
-		v.api = api
-	}
+func (obj *Product) setAPI(api *API) { obj.api = api }
 
-	return result, nil
+func (api *API) Products(options *ProductsOptions) ([]*Product, error) {
+	return productResource.list(api, options)
 }
 
 type ProductsCountOptions struct {
@@ -96,55 +73,11 @@ type ProductsCountOptions struct {
 }
 
 func (api *API) ProductsCount(options *ProductsCountOptions) (int, error) {
-
-	qs := encodeOptions(options)
-	endpoint := fmt.Sprintf("/admin/products/count.json?%v", qs)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return 0, err
-	}
-
-	if status != 200 {
-		return 0, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]interface{}{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result, _ := strconv.Atoi(fmt.Sprintf("%v", r["count"]))
-	if err != nil {
-		return 0, err
-	}
-	return result, nil
+	return productResource.count(api, options)
 }
 
 func (api *API) Product(id int64) (*Product, error) {
-	endpoint := fmt.Sprintf("/admin/products/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]Product{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["product"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+	return productResource.get(api, id, nil)
 }
 
 func (api *API) NewProduct() *Product {
@@ -168,150 +101,19 @@ func (obj *Product) Metafields(options *ProductsMetafieldsOptions) ([]*Metafield
 	if obj == nil || obj.api == nil {
 		return nil, errors.New("Product is nil")
 	}
-	qs := encodeOptions(options)
-	endpoint := fmt.Sprintf("/admin/products/%d/metafields.json?%v", obj.ID, qs)
-	res, status, err := obj.api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string][]*Metafield{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["metafields"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	for _, v := range result {
-		v.api = obj.api
-	}
-
-	return result, nil
+	return metafieldResource.nested(fmt.Sprintf("/admin/products/%d", obj.ID)).list(obj.api, options)
 }
 
-//func (obj *Product) Save() error {
-//	endpoint := fmt.Sprintf("/admin/products/%d.json", obj.Id)
-//	method := "PUT"
-//	expectedStatus := 200
-//
-//	if obj.Id == 0 {
-//		endpoint = fmt.Sprintf("/admin/products.json")
-//		method = "POST"
-//		expectedStatus = 201
-//	}
-//
-//	body := map[string]*Product{}
-//	body["product"] = obj
-//
-//	buf := &bytes.Buffer{}
-//	err := json.NewEncoder(buf).Encode(body)
-//
-//	if err != nil {
-//		return err
-//	}
-//
-//	res, status, err := obj.api.request(endpoint, method, nil, buf)
-//
-//	if err != nil {
-//		return err
-//	}
-//
-//	if status != expectedStatus {
-//		r := errorResponse{}
-//		err = json.NewDecoder(res).Decode(&r)
-//		if err == nil {
-//			return fmt.Errorf("Status %d: %v", status, r.Errors)
-//		} else {
-//			return fmt.Errorf("Status %d, and error parsing body: %s", status, err)
-//		}
-//	}
-//
-//	r := map[string]Product{}
-//	err = json.NewDecoder(res).Decode(&r)
-//
-//	if err != nil {
-//		return err
-//	}
-//
-//	api := obj.api
-//	*obj = r["product"]
-//	obj.api = api
-//
-//	return nil
-//}
-
 func (obj *Product) Save(partial *Product) error {
-	endpoint := fmt.Sprintf("/admin/products/%d.json", obj.ID)
-	method := "PUT"
-	expectedStatus := 200
-
-	if obj.ID == 0 {
-		endpoint = fmt.Sprintf("/admin/products.json")
-		method = "POST"
-		expectedStatus = 201
-	}
-
-	body := map[string]*Product{}
-	if partial == nil {
-		body["product"] = obj
-	} else {
-		body["product"] = partial
-	}
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
-
-	if err != nil {
-		return err
+	var body interface{}
+	if partial != nil {
+		body = partial
 	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	r := map[string]Product{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	if err != nil {
-		return err
-	}
-
-	api := obj.api
-	*obj = r["product"]
-	obj.api = api
-
-	return nil
+	return productResource.save(obj.api, obj.ID, obj, body)
 }
 
 func (obj *Product) Delete() error {
-	endpoint := fmt.Sprintf("/admin/products/%d.json", obj.ID)
-	method := "DELETE"
-	expectedStatus := 200
-
-	res, status, err := obj.api.request(endpoint, method, nil, nil)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	return nil
+	return productResource.delete(obj.api, obj.ID)
 }
 
 func encodeOptions(v interface{}) string {
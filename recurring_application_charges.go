@@ -1,8 +1,6 @@
 package shopify
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 )
 
@@ -37,60 +35,17 @@ type RecurringApplicationChargeOptions struct {
 	Fields  string `url:"fields,omitempty"`
 }
 
-// RecurringApplicationCharges Retrieve all recurring application charges
-func (api *API) RecurringApplicationCharges(options *RecurringApplicationChargeOptions) ([]*RecurringApplicationCharge, error) {
-
-	qs := encodeOptions(options)
-	endpoint := fmt.Sprintf("/admin/recurring_application_charges.json?%v", qs)
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
+var recurringApplicationChargeResource = newRESTResource[RecurringApplicationCharge]("/admin/recurring_application_charges", "recurring_application_charge", "recurring_application_charges")
 
-	r := &map[string][]*RecurringApplicationCharge{}
-	err = json.NewDecoder(res).Decode(r)
-	if err != nil {
-		return nil, err
-	}
+func (obj *RecurringApplicationCharge) setAPI(api *API) { obj.api = api }
 
-	result := (*r)["recurring_application_charges"]
-	for _, v := range result {
-		v.api = api
-	}
-
-	return result, nil
+// RecurringApplicationCharges Retrieve all recurring application charges
+func (api *API) RecurringApplicationCharges(options *RecurringApplicationChargeOptions) ([]*RecurringApplicationCharge, error) {
+	return recurringApplicationChargeResource.list(api, options)
 }
 
 func (api *API) RecurringApplicationCharge(id int64) (*RecurringApplicationCharge, error) {
-	endpoint := fmt.Sprintf("/admin/recurring_application_charges/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]RecurringApplicationCharge{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["recurring_application_charge"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+	return recurringApplicationChargeResource.get(api, id, nil)
 }
 
 func (api *API) NewRecurringApplicationCharge() *RecurringApplicationCharge {
@@ -98,77 +53,14 @@ func (api *API) NewRecurringApplicationCharge() *RecurringApplicationCharge {
 }
 
 func (obj *RecurringApplicationCharge) Save() error {
-
-	endpoint := fmt.Sprintf("/admin/recurring_application_charges.json")
-	method := "POST"
-	expectedStatus := 201
-
-	body := map[string]*RecurringApplicationCharge{}
-	body["recurring_application_charge"] = obj
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
-
-	if err != nil {
-		return err
-	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	r := map[string]RecurringApplicationCharge{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	if err != nil {
-		return err
-	}
-
-	api := obj.api
-	*obj = r["recurring_application_charge"]
-	obj.api = api
-
-	return nil
+	return recurringApplicationChargeResource.create(obj.api, obj, nil)
 }
 
 func (obj *RecurringApplicationCharge) Activate() error {
 	endpoint := fmt.Sprintf("/admin/recurring_application_charges/%d/activate.json", obj.ID)
-	method := "POST"
-	expectedStatus := 200
-
-	res, status, err := obj.api.request(endpoint, method, nil, nil)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	return nil
+	return recurringApplicationChargeResource.do(obj.api, endpoint, "POST", nil, 200, "", nil)
 }
 
 func (obj *RecurringApplicationCharge) Delete() error {
-	endpoint := fmt.Sprintf("/admin/recurring_application_charges/%d.json", obj.ID)
-	method := "DELETE"
-	expectedStatus := 200
-
-	res, status, err := obj.api.request(endpoint, method, nil, nil)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	return nil
+	return recurringApplicationChargeResource.delete(obj.api, obj.ID)
 }
@@ -0,0 +1,136 @@
+package shopify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Redactor masks secrets and customer data in request and response bodies
+// before they are logged or included in error strings.
+type Redactor struct {
+	// Mask replaces the redacted values.
+	Mask string
+	// Fields are the lower case names of JSON fields whose values are
+	// masked entirely.
+	Fields map[string]bool
+	// Patterns are masked wherever they match in strings. If a pattern has
+	// a capture group, the text it matches is kept before the mask, e.g.
+	// to keep the name of a parameter.
+	Patterns []*regexp.Regexp
+}
+
+// NewRedactor returns a Redactor masking access tokens and other secrets,
+// names, emails, addresses and phone numbers.
+func NewRedactor() *Redactor {
+	fields := map[string]bool{}
+	for _, field := range []string{
+		// secrets
+		"access_token", "token", "client_secret", "secret", "password", "api_key", "subject_token", "hmac",
+		// customer data
+		"email", "contact_email", "customer_email", "phone",
+		"name", "first_name", "last_name", "company",
+		"address1", "address2", "city", "province", "country", "zip", "latitude", "longitude",
+	} {
+		fields[field] = true
+	}
+
+	return &Redactor{
+		Mask:   redactedSecret,
+		Fields: fields,
+		Patterns: []*regexp.Regexp{
+			// name=value and "name": "value" forms of secrets, e.g. in
+			// query strings or truncated JSON
+			regexp.MustCompile(`(?i)("?(?:access_token|client_secret|password|subject_token|hmac)"?\s*[:=]\s*"?)[^"&\s,}]+`),
+			regexp.MustCompile(`([?&]code=)[^&\s]+`),
+			// Shopify access tokens and bearer tokens
+			regexp.MustCompile(`\bshp(?:at|ca|pa|ss|ua)_[A-Za-z0-9]+`),
+			regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`),
+			// emails
+			regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+			// phone numbers, either international or with separators, so
+			// that IDs aren't matched
+			regexp.MustCompile(`\+\d[\d\s().-]{6,}\d`),
+			regexp.MustCompile(`\(?\b\d{3}\)?[\s.-]\d{3}[\s.-]\d{4}\b`),
+		},
+	}
+}
+
+// DefaultRedactor is used by ErrorResponse.Error() and when logging bodies.
+// Set it to nil to disable redaction.
+var DefaultRedactor = NewRedactor()
+
+// MaxErrorBodyLength, if positive, truncates the request and response bodies
+// included in ErrorResponse.Error() to that many bytes.
+var MaxErrorBodyLength = 0
+
+// Redact returns a copy of body with secrets and customer data masked. JSON
+// bodies are re-encoded with the values of sensitive fields masked, other
+// bodies are matched against the patterns only.
+func (r *Redactor) Redact(body []byte) []byte {
+	if r == nil || len(body) == 0 {
+		return body
+	}
+
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return []byte(r.RedactString(string(body)))
+	}
+
+	b, err := json.Marshal(r.redactValue(v))
+	if err != nil {
+		return []byte(r.RedactString(string(body)))
+	}
+	return b
+}
+
+// RedactString returns s with the patterns masked.
+func (r *Redactor) RedactString(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, pattern := range r.Patterns {
+		s = pattern.ReplaceAllString(s, "${1}"+r.Mask)
+	}
+	return s
+}
+
+func (r *Redactor) redactValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if r.Fields[strings.ToLower(key)] && field != nil && field != "" {
+				value[key] = r.Mask
+			} else {
+				value[key] = r.redactValue(field)
+			}
+		}
+		return value
+	case []interface{}:
+		for i := range value {
+			value[i] = r.redactValue(value[i])
+		}
+		return value
+	case string:
+		return r.RedactString(value)
+	}
+	return v
+}
+
+// truncate shortens body to at most max bytes, if max is positive, without
+// splitting a UTF-8 sequence.
+func truncate(body []byte, max int) string {
+	if max <= 0 || len(body) <= max {
+		return string(body)
+	}
+	n := max
+	for n > 0 && !utf8.RuneStart(body[n]) {
+		n--
+	}
+	return fmt.Sprintf("%s... (%d bytes truncated)", body[:n], len(body)-n)
+}
@@ -0,0 +1,85 @@
+package shopify
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRedact(t *testing.T) {
+	r := NewRedactor()
+
+	tests := []struct {
+		body     string
+		expected string
+	}{
+		{
+			`{"customer":{"id":207119551,"email":"bob.norman@example.com","phone":"+16136120707","note":"call bob.norman@example.com"}}`,
+			`{"customer":{"email":"[REDACTED]","id":207119551,"note":"call [REDACTED]","phone":"[REDACTED]"}}`,
+		},
+		{
+			`{"order":{"billing_address":{"first_name":"Bob","last_name":"Norman","name":"Bob Norman","company":"Acme","address1":"Chestnut Street 92","city":"Louisville","province":"Kentucky","country":"United States","country_code":"US","zip":"40202"},"total_price":"598.94"}}`,
+			`{"order":{"billing_address":{"address1":"[REDACTED]","city":"[REDACTED]","company":"[REDACTED]","country":"[REDACTED]","country_code":"US","first_name":"[REDACTED]","last_name":"[REDACTED]","name":"[REDACTED]","province":"[REDACTED]","zip":"[REDACTED]"},"total_price":"598.94"}}`,
+		},
+		{
+			`{"access_token":"shpat_abc123","scope":"read_orders","email":null}`,
+			`{"access_token":"[REDACTED]","email":null,"scope":"read_orders"}`,
+		},
+		{
+			`Bearer eyJhbGciOi.eyJpc3Mi.sig failed for shpat_0123abcd`,
+			`Bearer [REDACTED] failed for [REDACTED]`,
+		},
+		{
+			`{"truncated": "yes", "access_token": "shpca_0123`,
+			`{"truncated": "yes", "access_token": "[REDACTED]`,
+		},
+		{
+			`/admin/oauth?code=0907a61c0c8d55e99db179b68161bc00&shop=example.myshopify.com`,
+			`/admin/oauth?code=[REDACTED]&shop=example.myshopify.com`,
+		},
+		{
+			`call (613) 612-0707 about order 450789469`,
+			`call [REDACTED] about order 450789469`,
+		},
+	}
+
+	for _, test := range tests {
+		if redacted := string(r.Redact([]byte(test.body))); redacted != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, redacted)
+		}
+	}
+
+	var disabled *Redactor
+	if redacted := disabled.RedactString("bob@example.com"); redacted != "bob@example.com" {
+		t.Errorf("Expected nil Redactor not to redact, got %s", redacted)
+	}
+}
+
+func TestErrorResponseRedacted(t *testing.T) {
+	err := decodeErrorResponse(422, []byte(`{"customer":{"email":"bob@example.com"}}`), bytes.NewBufferString(`{"errors":{"email":["bob@example.com has already been taken"]}}`))
+
+	if msg := err.Error(); strings.Contains(msg, "bob@example.com") {
+		t.Errorf("Expected email to be redacted, got %s", msg)
+	}
+	if string(err.ReqBody) != `{"customer":{"email":"bob@example.com"}}` {
+		t.Errorf("Expected ReqBody to be kept, got %s", err.ReqBody)
+	}
+}
+
+func TestErrorResponseTruncated(t *testing.T) {
+	defer func(max int) { MaxErrorBodyLength = max }(MaxErrorBodyLength)
+	MaxErrorBodyLength = 10
+
+	err := decodeErrorResponse(500, []byte(strings.Repeat("a", 30)), bytes.NewBufferString(strings.Repeat("b", 25)))
+	msg := err.Error()
+	if !strings.Contains(msg, "request body: aaaaaaaaaa... (20 bytes truncated)") || !strings.Contains(msg, "response body: bbbbbbbbbb... (15 bytes truncated)") {
+		t.Errorf("Expected bodies to be truncated, got %s", msg)
+	}
+
+	// a multi-byte character is cut before, not in the middle
+	err = decodeErrorResponse(500, nil, bytes.NewBufferString(strings.Repeat("b", 9)+"€"))
+	if msg := err.Error(); !utf8.ValidString(msg) || !strings.Contains(msg, "response body: bbbbbbbbb... (3 bytes truncated)") {
+		t.Errorf("Expected body to be truncated before €, got %q", msg)
+	}
+}
@@ -1,13 +1,5 @@
 package shopify
 
-import (
-	"bytes"
-
-	"encoding/json"
-
-	"fmt"
-)
-
 type Redirect struct {
 	Id int64 `json:"id"`
 
@@ -18,57 +10,16 @@ type Redirect struct {
 	api *API
 }
 
-func (api *API) Redirects() ([]Redirect, error) {
-	res, status, err := api.request("/admin/redirects.json", "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := &map[string][]Redirect{}
-	err = json.NewDecoder(res).Decode(r)
-	result := (*r)["redirects"]
+var redirectResource = newRESTResource[Redirect]("/admin/redirects", "redirect", "redirects")
 
-	if err != nil {
-		return nil, err
-	}
+func (obj *Redirect) setAPI(api *API) { obj.api = api }
 
-	for _, v := range result {
-		v.api = api
-	}
-
-	return result, nil
+func (api *API) Redirects() ([]Redirect, error) {
+	return redirectResource.listValues(api, nil)
 }
 
 func (api *API) Redirect(id int64) (*Redirect, error) {
-	endpoint := fmt.Sprintf("/admin/redirects/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]Redirect{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["redirect"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+	return redirectResource.get(api, id, nil)
 }
 
 func (api *API) NewRedirect() *Redirect {
@@ -76,43 +27,5 @@ func (api *API) NewRedirect() *Redirect {
 }
 
 func (obj *Redirect) Save() error {
-	endpoint := fmt.Sprintf("/admin/redirects/%d.json", obj.Id)
-	method := "PUT"
-	expectedStatus := 201
-
-	if obj.Id == 0 {
-		endpoint = fmt.Sprintf("/admin/redirects.json")
-		method = "POST"
-		expectedStatus = 201
-	}
-
-	body := map[string]*Redirect{}
-	body["redirect"] = obj
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
-
-	if err != nil {
-		return err
-	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	r := map[string]Redirect{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	if err != nil {
-		return err
-	}
-	*obj = r["redirect"]
-
-	return nil
+	return redirectResource.save(obj.api, obj.Id, obj, nil)
 }
@@ -0,0 +1,244 @@
+package shopify
+
+//go:generate go run ./cmd/shopify-gen -schema schema/resources.json -out .
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// resourcePtr is satisfied by pointers to resources, which keep the API they
+// were fetched with to be saved or deleted later.
+type resourcePtr[T any] interface {
+	*T
+	setAPI(api *API)
+}
+
+// restResource describes a REST resource: the path of its collection and the
+// keys wrapping it in request and response bodies. Every resource is
+// listed, counted, fetched, created, updated and deleted through it, so that
+// status checks, decoding and keeping the api pointer are done in one place.
+type restResource[T any, PT resourcePtr[T]] struct {
+	// path is the collection's path without the .json suffix, e.g.
+	// "/admin/blogs".
+	path     string
+	singular string
+	plural   string
+}
+
+func newRESTResource[T any, PT resourcePtr[T]](path, singular, plural string) restResource[T, PT] {
+	return restResource[T, PT]{path: path, singular: singular, plural: plural}
+}
+
+// nested returns the resource under parent, e.g. the metafields of a product
+// under "/admin/products/1".
+func (r restResource[T, PT]) nested(parent string) restResource[T, PT] {
+	r.path = parent + r.path[len("/admin"):]
+	return r
+}
+
+func (r restResource[T, PT]) collection() string {
+	return r.path + ".json"
+}
+
+func (r restResource[T, PT]) member(id int64) string {
+	return fmt.Sprintf("%s/%d.json", r.path, id)
+}
+
+// withQuery appends the query string of options to endpoint, if any.
+func withQuery(endpoint string, options interface{}) string {
+	if qs := encodeOptions(options); qs != "" {
+		return endpoint + "?" + qs
+	}
+	return endpoint
+}
+
+// list returns the resources matching options.
+func (r restResource[T, PT]) list(api *API, options interface{}) ([]*T, error) {
+	return r.listAt(api, withQuery(r.collection(), options))
+}
+
+// listAt returns the resources listed by endpoint, for listings outside the
+// collection's path.
+func (r restResource[T, PT]) listAt(api *API, endpoint string) ([]*T, error) {
+	result := []*T{}
+	if err := r.do(api, endpoint, "GET", nil, 200, r.plural, &result); err != nil {
+		return nil, err
+	}
+	for _, v := range result {
+		PT(v).setAPI(api)
+	}
+	return result, nil
+}
+
+// listPage returns one page of the resources matching options, and the
+// page_info of the next page, or "" on the last one.
+func (r restResource[T, PT]) listPage(api *API, options interface{}) ([]*T, string, error) {
+	result := []*T{}
+	header, err := r.exchange(api, withQuery(r.collection(), options), "GET", nil, 200, r.plural, &result)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, v := range result {
+		PT(v).setAPI(api)
+	}
+	return result, nextPageInfo(header), nil
+}
+
+// listValues is list for the getters returning values rather than
+// pointers.
+func (r restResource[T, PT]) listValues(api *API, options interface{}) ([]T, error) {
+	result, err := r.list(api, options)
+	if err != nil {
+		return nil, err
+	}
+	return values(result), nil
+}
+
+// count returns the number of resources matching options.
+func (r restResource[T, PT]) count(api *API, options interface{}) (int, error) {
+	endpoint := withQuery(r.path+"/count.json", options)
+	var count json.Number
+	if err := r.do(api, endpoint, "GET", nil, 200, "count", &count); err != nil {
+		return 0, err
+	}
+	if count == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(count.String())
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// get returns the resource with id.
+func (r restResource[T, PT]) get(api *API, id int64, options interface{}) (*T, error) {
+	return r.getAt(api, withQuery(r.member(id), options))
+}
+
+// getAt returns the resource at endpoint, for singletons such as the shop.
+func (r restResource[T, PT]) getAt(api *API, endpoint string) (*T, error) {
+	result := new(T)
+	if err := r.do(api, endpoint, "GET", nil, 200, r.singular, result); err != nil {
+		return nil, err
+	}
+	PT(result).setAPI(api)
+	return result, nil
+}
+
+// create creates obj, sending body, or obj if body is nil, and replaces obj
+// with the resource returned.
+func (r restResource[T, PT]) create(api *API, obj PT, body interface{}) error {
+	return r.write(api, r.collection(), "POST", 201, obj, body)
+}
+
+// update updates the resource with id, sending body, or obj if body is nil,
+// and replaces obj with the resource returned.
+func (r restResource[T, PT]) update(api *API, id int64, obj PT, body interface{}) error {
+	return r.write(api, r.member(id), "PUT", 200, obj, body)
+}
+
+// save creates obj if id is zero, and updates it otherwise.
+func (r restResource[T, PT]) save(api *API, id int64, obj PT, body interface{}) error {
+	if id == 0 {
+		return r.create(api, obj, body)
+	}
+	return r.update(api, id, obj, body)
+}
+
+// write sends body, or obj if body is nil, to endpoint, and replaces obj
+// with the resource returned.
+func (r restResource[T, PT]) write(api *API, endpoint, method string, expectedStatus int, obj PT, body interface{}) error {
+	if body == nil {
+		body = obj
+	}
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(map[string]interface{}{r.singular: body}); err != nil {
+		return err
+	}
+
+	result := new(T)
+	if err := r.do(api, endpoint, method, buf, expectedStatus, r.singular, result); err != nil {
+		return err
+	}
+	*obj = *result
+	obj.setAPI(api)
+	return nil
+}
+
+// delete deletes the resource with id.
+func (r restResource[T, PT]) delete(api *API, id int64) error {
+	return r.do(api, r.member(id), "DELETE", nil, 200, "", nil)
+}
+
+// do sends a request, checks its status and decodes the value under key in
+// the response into out. Nothing is decoded if out is nil.
+func (r restResource[T, PT]) do(api *API, endpoint, method string, body *bytes.Buffer, expectedStatus int, key string, out interface{}) error {
+	_, err := r.exchange(api, endpoint, method, body, expectedStatus, key, out)
+	return err
+}
+
+// exchange is do, also returning the headers of the response.
+func (r restResource[T, PT]) exchange(api *API, endpoint, method string, body *bytes.Buffer, expectedStatus int, key string, out interface{}) (http.Header, error) {
+	if api == nil {
+		return nil, fmt.Errorf("%s has no API", r.singular)
+	}
+
+	var reqBody []byte
+	if body != nil {
+		reqBody = body.Bytes()
+	}
+	res, header, status, err := api.requestWithHeader(endpoint, method, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if status != expectedStatus {
+		return nil, newErrorResponse(status, reqBody, res)
+	}
+	if out == nil {
+		return header, nil
+	}
+
+	envelope := map[string]json.RawMessage{}
+	if err = json.NewDecoder(res).Decode(&envelope); err != nil {
+		return nil, err
+	}
+	if raw, ok := envelope[key]; ok {
+		if err = json.Unmarshal(raw, out); err != nil {
+			return nil, err
+		}
+	}
+	return header, nil
+}
+
+// nextPageInfo returns the page_info of the next page in a Link header, or
+// "" on the last page.
+func nextPageInfo(header http.Header) string {
+	for _, link := range strings.Split(header.Get("Link"), ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 || !strings.Contains(parts[1], `rel="next"`) {
+			continue
+		}
+		target, err := url.Parse(strings.Trim(strings.TrimSpace(parts[0]), "<>"))
+		if err != nil {
+			return ""
+		}
+		return target.Query().Get("page_info")
+	}
+	return ""
+}
+
+// values converts resources to the value slices returned by older getters.
+func values[T any](ptrs []*T) []T {
+	result := make([]T, len(ptrs))
+	for i, v := range ptrs {
+		result[i] = *v
+	}
+	return result
+}
@@ -0,0 +1,272 @@
+package shopify
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// recordedRequest is the last request received by a resourceServer.
+type recordedRequest struct {
+	Method string
+	URI    string
+	Body   map[string]interface{}
+}
+
+// resourceServer answers every request with status and body, recording it.
+func resourceServer(t *testing.T, status int, body string) (*API, *recordedRequest) {
+	recorded := &recordedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorded.Method = r.Method
+		recorded.URI = r.URL.RequestURI()
+		recorded.Body = nil
+		if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+			json.Unmarshal(data, &recorded.Body)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return &API{Shop: "example.myshopify.com", BaseURL: server.URL, RetryPolicy: NoRetry}, recorded
+}
+
+func TestResourceRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		call   func(api *API) (*API, error)
+		method string
+		uri    string
+		// key is the key expected to wrap the request body, if any
+		key string
+	}{
+		{
+			name: "list", status: 200, body: `{"blogs":[{"id":1},{"id":2}]}`,
+			call: func(api *API) (*API, error) {
+				blogs, err := api.Blogs()
+				if err != nil {
+					return nil, err
+				}
+				if len(blogs) != 2 {
+					return nil, errors.New("expected 2 blogs")
+				}
+				return blogs[1].api, nil
+			},
+			method: "GET", uri: "/admin/blogs.json",
+		},
+		{
+			name: "list values", status: 200, body: `{"orders":[{"id":1}]}`,
+			call: func(api *API) (*API, error) {
+				orders, err := api.Orders()
+				if err != nil {
+					return nil, err
+				}
+				return orders[0].api, nil
+			},
+			method: "GET", uri: "/admin/orders.json",
+		},
+		{
+			name: "list with options", status: 200, body: `{"custom_collections":[{"id":1}]}`,
+			call: func(api *API) (*API, error) {
+				collections, err := api.CustomCollectionsWithOptions(&CollectionOptions{Handle: "sale", Limit: 5})
+				if err != nil {
+					return nil, err
+				}
+				return collections[0].api, nil
+			},
+			method: "GET", uri: "/admin/custom_collections.json?handle=sale&limit=5",
+		},
+		{
+			name: "nested list", status: 200, body: `{"metafields":[{"id":1}]}`,
+			call: func(api *API) (*API, error) {
+				product := &Product{ID: 7, api: api}
+				metafields, err := product.Metafields(&ProductsMetafieldsOptions{Key: "color"})
+				if err != nil {
+					return nil, err
+				}
+				return metafields[0].api, nil
+			},
+			method: "GET", uri: "/admin/products/7/metafields.json?key=color",
+		},
+		{
+			name: "generated nested get", status: 200, body: `{"transaction":{"id":8,"kind":"capture"}}`,
+			call: func(api *API) (*API, error) {
+				transaction, err := api.Transaction(5, 8)
+				if err != nil {
+					return nil, err
+				}
+				return transaction.api, nil
+			},
+			method: "GET", uri: "/admin/orders/5/transactions/8.json",
+		},
+		{
+			name: "count", status: 200, body: `{"count":12}`,
+			call: func(api *API) (*API, error) {
+				count, err := api.ProductsCount(&ProductsCountOptions{Vendor: "Acme"})
+				if err == nil && count != 12 {
+					return nil, errors.New("expected 12 products")
+				}
+				return api, err
+			},
+			method: "GET", uri: "/admin/products/count.json?vendor=Acme",
+		},
+		{
+			name: "get", status: 200, body: `{"page":{"id":3,"title":"About"}}`,
+			call: func(api *API) (*API, error) {
+				page, err := api.Page(3)
+				if err != nil {
+					return nil, err
+				}
+				if page.Title != "About" {
+					return nil, errors.New("expected the page to be decoded")
+				}
+				return page.api, nil
+			},
+			method: "GET", uri: "/admin/pages/3.json",
+		},
+		{
+			name: "singleton", status: 200, body: `{"shop":{"id":1}}`,
+			call: func(api *API) (*API, error) {
+				shop, err := api.CurrentShop()
+				if err != nil {
+					return nil, err
+				}
+				return shop.api, nil
+			},
+			method: "GET", uri: "/admin/shop.json",
+		},
+		{
+			name: "create", status: 201, body: `{"redirect":{"id":9,"path":"/a","target":"/b"}}`,
+			call: func(api *API) (*API, error) {
+				redirect := api.NewRedirect()
+				redirect.Path = "/a"
+				err := redirect.Save()
+				if err == nil && redirect.Id != 9 {
+					return nil, errors.New("expected the redirect to be replaced")
+				}
+				return redirect.api, err
+			},
+			method: "POST", uri: "/admin/redirects.json", key: "redirect",
+		},
+		{
+			name: "update", status: 200, body: `{"order":{"id":4,"note":"rush"}}`,
+			call: func(api *API) (*API, error) {
+				order := api.NewOrder()
+				order.Id = 4
+				err := order.Save()
+				return order.api, err
+			},
+			method: "PUT", uri: "/admin/orders/4.json", key: "order",
+		},
+		{
+			name: "update partial", status: 200, body: `{"product":{"id":5}}`,
+			call: func(api *API) (*API, error) {
+				product := api.NewProduct()
+				product.ID = 5
+				err := product.Save(&Product{ID: 5})
+				return product.api, err
+			},
+			method: "PUT", uri: "/admin/products/5.json", key: "product",
+		},
+		{
+			name: "nested save", status: 201, body: `{"metafield":{"id":2}}`,
+			call: func(api *API) (*API, error) {
+				metafield := api.NewMetafield()
+				err := metafield.SaveForProduct(7)
+				return metafield.api, err
+			},
+			method: "POST", uri: "/admin/products/7/metafields.json", key: "metafield",
+		},
+		{
+			name: "delete", status: 200, body: `{}`,
+			call: func(api *API) (*API, error) {
+				webhook := api.NewWebhook()
+				webhook.Id = 6
+				return api, webhook.Delete()
+			},
+			method: "DELETE", uri: "/admin/webhooks/6.json",
+		},
+	}
+
+	for _, test := range tests {
+		api, recorded := resourceServer(t, test.status, test.body)
+
+		got, err := test.call(api)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if got != api {
+			t.Errorf("%s: expected the api pointer to be kept", test.name)
+		}
+		if recorded.Method != test.method || recorded.URI != test.uri {
+			t.Errorf("%s: expected %s %s, got %s %s", test.name, test.method, test.uri, recorded.Method, recorded.URI)
+		}
+		if test.key != "" && recorded.Body[test.key] == nil {
+			t.Errorf("%s: expected the body to be wrapped in %q, got %v", test.name, test.key, recorded.Body)
+		}
+	}
+}
+
+func TestResourceUnexpectedStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		call   func(api *API) error
+	}{
+		// creating expects 201
+		{"create", 200, func(api *API) error { return api.NewBlog().Save() }},
+		// updating and deleting expect 200
+		{"update", 201, func(api *API) error { return (&Theme{Id: 1, api: api}).Save() }},
+		{"delete", 204, func(api *API) error { return (&Product{ID: 1, api: api}).Delete() }},
+		{"get", 404, func(api *API) error { _, err := api.Customer(1); return err }},
+	}
+
+	for _, test := range tests {
+		api, _ := resourceServer(t, test.status, `{"errors":"Failed"}`)
+
+		var errResp *ErrorResponse
+		if err := test.call(api); !errors.As(err, &errResp) || errResp.StatusCode != test.status {
+			t.Errorf("%s: expected an *ErrorResponse with status %d, got %v", test.name, test.status, err)
+		}
+	}
+}
+
+func TestResourceWithoutAPI(t *testing.T) {
+	blog := &Blog{Title: "News"}
+	if err := blog.Save(); err == nil {
+		t.Errorf("Expected an error saving a blog without an API")
+	}
+
+	// GraphQL resources fail the same way
+	if err := (&MetafieldDefinition{}).Pin(); err == nil {
+		t.Errorf("Expected an error pinning a metafield definition without an API")
+	}
+	if err := (&Metaobject{Type: "designer"}).Save(); err == nil {
+		t.Errorf("Expected an error saving a metaobject without an API")
+	}
+	if _, err := (*API)(nil).Metaobjects("designer"); err == nil {
+		t.Errorf("Expected an error listing metaobjects without an API")
+	}
+}
+
+func TestNextPageInfo(t *testing.T) {
+	tests := map[string]string{
+		"": "",
+		`<https://example.myshopify.com/admin/api/2024-01/inventory_levels.json?limit=2&page_info=abc>; rel="next"`:                                                                         "abc",
+		`<https://example.myshopify.com/admin/inventory_levels.json?page_info=prev>; rel="previous", <https://example.myshopify.com/admin/inventory_levels.json?page_info=def>; rel="next"`: "def",
+		`<https://example.myshopify.com/admin/inventory_levels.json?page_info=prev>; rel="previous"`:                                                                                        "",
+	}
+
+	for link, expected := range tests {
+		header := http.Header{}
+		header.Set("Link", link)
+		if got := nextPageInfo(header); got != expected {
+			t.Errorf("%s: expected %q, got %q", link, expected, got)
+		}
+	}
+}
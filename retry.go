@@ -0,0 +1,131 @@
+package shopify
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jpillora/backoff"
+)
+
+// IdempotencyKeyHeader is the request header marking a request as safe to
+// retry, whatever its method.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// RetryAttempt describes a failed attempt at sending a request.
+type RetryAttempt struct {
+	Method   string
+	Endpoint string
+	// Attempt is the number of the attempt that failed, starting at 1.
+	Attempt int
+	// Elapsed is the time since the first attempt was sent.
+	Elapsed time.Duration
+	// StatusCode is the status of the response, or 0 if Err is set.
+	StatusCode int
+	// Header holds the response headers, or nil if Err is set.
+	Header http.Header
+	// Err is the error returned by the http.Client, e.g. a network error.
+	Err error
+	// Idempotent reports whether sending the request again can't apply it
+	// twice: GET, HEAD, OPTIONS, PUT and DELETE requests, and requests with
+	// an Idempotency-Key header.
+	Idempotent bool
+}
+
+// RetryPolicy decides whether a failed request is sent again.
+type RetryPolicy interface {
+	// Retry returns how long to wait before sending the request again, or
+	// false to give up and return the failure to the caller.
+	Retry(attempt RetryAttempt) (time.Duration, bool)
+}
+
+// BackoffRetryPolicy retries throttled requests, and server errors and
+// network errors of idempotent requests, with exponential backoff.
+type BackoffRetryPolicy struct {
+	// MaxRetries is the maximum number of retries of a single request.
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the jittered exponential backoff used
+	// when the response has no Retry-After header.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// MaxElapsed caps the total time spent on a request: no retry is made
+	// if it would start later than MaxElapsed after the first attempt.
+	// Zero means no cap.
+	MaxElapsed time.Duration
+
+	// OnRetry, if set, is called before waiting to retry a request.
+	OnRetry func(attempt RetryAttempt, wait time.Duration)
+	// OnGiveUp, if set, is called when a retryable failure isn't retried
+	// because MaxRetries or MaxElapsed was reached.
+	OnGiveUp func(attempt RetryAttempt)
+}
+
+// DefaultRetryPolicy returns the policy used by an API without a RetryPolicy.
+func DefaultRetryPolicy() *BackoffRetryPolicy {
+	return &BackoffRetryPolicy{
+		MaxRetries: defaultConfig.MaxRetries,
+		MinBackoff: defaultConfig.MinBackoffValue,
+		MaxBackoff: defaultConfig.MaxBackoffValue,
+		MaxElapsed: 30 * time.Second,
+	}
+}
+
+// NoRetry is a RetryPolicy that never retries.
+var NoRetry RetryPolicy = noRetry{}
+
+type noRetry struct{}
+
+func (noRetry) Retry(RetryAttempt) (time.Duration, bool) {
+	return 0, false
+}
+
+func (p *BackoffRetryPolicy) Retry(attempt RetryAttempt) (time.Duration, bool) {
+	if !retryable(attempt) {
+		return 0, false
+	}
+
+	wait := parseRetryAfter(attempt.Header.Get("Retry-After"))
+	if wait == 0 {
+		b := &backoff.Backoff{Min: p.MinBackoff, Max: p.MaxBackoff, Jitter: true}
+		wait = b.ForAttempt(float64(attempt.Attempt - 1))
+	}
+
+	if attempt.Attempt > p.MaxRetries || (p.MaxElapsed > 0 && attempt.Elapsed+wait > p.MaxElapsed) {
+		if p.OnGiveUp != nil {
+			p.OnGiveUp(attempt)
+		}
+		return 0, false
+	}
+
+	if p.OnRetry != nil {
+		p.OnRetry(attempt, wait)
+	}
+	return wait, true
+}
+
+// retryable reports whether sending the request again may succeed. Throttled
+// requests were never applied and can always be retried, other failures only
+// when the request is idempotent.
+func retryable(attempt RetryAttempt) bool {
+	if attempt.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if !attempt.Idempotent {
+		return false
+	}
+	if attempt.Err != nil {
+		return true
+	}
+	switch attempt.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return req.Header.Get(IdempotencyKeyHeader) != ""
+}
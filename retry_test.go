@@ -0,0 +1,199 @@
+package shopify
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyServer answers the nth request with the nth handler, and every later
+// request successfully.
+type flakyServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	handlers []http.HandlerFunc
+	requests []*http.Request
+}
+
+func newFlakyServer(t *testing.T, handlers ...http.HandlerFunc) *flakyServer {
+	s := &flakyServer{handlers: handlers}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		n := len(s.requests)
+		s.requests = append(s.requests, r)
+		s.mu.Unlock()
+
+		if n < len(s.handlers) {
+			s.handlers[n](w, r)
+			return
+		}
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusCreated)
+		}
+		w.Write([]byte(`{"shop":{"id":1},"webhook":{"id":1}}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *flakyServer) api(policy RetryPolicy) *API {
+	return &API{Shop: "example.myshopify.com", BaseURL: s.URL, RetryPolicy: policy}
+}
+
+func (s *flakyServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func fail(status int, retryAfter string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"errors":"failed"}`))
+	}
+}
+
+// hangUp closes the connection without answering.
+func hangUp(w http.ResponseWriter, r *http.Request) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+func testRetryPolicy() *BackoffRetryPolicy {
+	return &BackoffRetryPolicy{
+		MaxRetries: 3,
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 5 * time.Millisecond,
+	}
+}
+
+func TestRetryIdempotentRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		handlers []http.HandlerFunc
+	}{
+		{"server errors", []http.HandlerFunc{fail(500, ""), fail(503, "")}},
+		{"network errors", []http.HandlerFunc{hangUp}},
+		{"throttled", []http.HandlerFunc{fail(429, "")}},
+	}
+
+	for _, test := range tests {
+		srv := newFlakyServer(t, test.handlers...)
+		policy := testRetryPolicy()
+		retries := []RetryAttempt{}
+		policy.OnRetry = func(attempt RetryAttempt, wait time.Duration) {
+			retries = append(retries, attempt)
+		}
+
+		if _, err := srv.api(policy).CurrentShop(); err != nil {
+			t.Errorf("%s: expected request to succeed, got %v", test.name, err)
+		}
+		if srv.count() != len(test.handlers)+1 {
+			t.Errorf("%s: expected %d requests, got %d", test.name, len(test.handlers)+1, srv.count())
+		}
+		if len(retries) != len(test.handlers) || retries[0].Attempt != 1 || !retries[0].Idempotent || retries[0].Endpoint != "/admin/shop.json" {
+			t.Errorf("%s: unexpected retries %#v", test.name, retries)
+		}
+	}
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	srv := newFlakyServer(t, fail(429, "0.2"))
+	policy := testRetryPolicy()
+	var waited time.Duration
+	policy.OnRetry = func(attempt RetryAttempt, wait time.Duration) {
+		waited = wait
+	}
+
+	start := time.Now()
+	if _, err := srv.api(policy).CurrentShop(); err != nil {
+		t.Fatalf("Expected request to succeed, got %v", err)
+	}
+	if waited != 200*time.Millisecond || time.Since(start) < 200*time.Millisecond {
+		t.Errorf("Expected to wait 200ms, waited %s", waited)
+	}
+}
+
+func TestNoRetryOfNonIdempotentRequests(t *testing.T) {
+	srv := newFlakyServer(t, fail(503, ""))
+
+	hook := srv.api(testRetryPolicy()).NewWebhook()
+	err := hook.Save(nil)
+	var errResp *ErrorResponse
+	if !errors.As(err, &errResp) || errResp.StatusCode != 503 {
+		t.Errorf("Expected 503 error, got %v", err)
+	}
+	if srv.count() != 1 {
+		t.Errorf("Expected POST not to be retried, got %d requests", srv.count())
+	}
+}
+
+func TestRetryWithIdempotencyKey(t *testing.T) {
+	srv := newFlakyServer(t, fail(503, ""), hangUp)
+	api := srv.api(testRetryPolicy())
+	api.IdempotencyKey = func(method, endpoint string, body []byte) string {
+		return "key-1"
+	}
+
+	if err := api.NewWebhook().Save(nil); err != nil {
+		t.Fatalf("Expected POST with idempotency key to be retried, got %v", err)
+	}
+	if srv.count() != 3 {
+		t.Errorf("Expected 3 requests, got %d", srv.count())
+	}
+	for _, req := range srv.requests {
+		if req.Header.Get(IdempotencyKeyHeader) != "key-1" {
+			t.Errorf("Expected every attempt to send the same key, got %q", req.Header.Get(IdempotencyKeyHeader))
+		}
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *BackoffRetryPolicy
+		requests int
+	}{
+		{"max retries", &BackoffRetryPolicy{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}, 3},
+		{"max elapsed", &BackoffRetryPolicy{MaxRetries: 5, MinBackoff: time.Second, MaxBackoff: time.Second, MaxElapsed: 100 * time.Millisecond}, 1},
+	}
+
+	for _, test := range tests {
+		srv := newFlakyServer(t, fail(429, ""), fail(429, ""), fail(429, ""), fail(429, ""))
+		var gaveUp *RetryAttempt
+		test.policy.OnGiveUp = func(attempt RetryAttempt) {
+			gaveUp = &attempt
+		}
+
+		_, err := srv.api(test.policy).CurrentShop()
+		if !errors.Is(err, ErrRateLimited) {
+			t.Errorf("%s: expected rate limit error, got %v", test.name, err)
+		}
+		if srv.count() != test.requests {
+			t.Errorf("%s: expected %d requests, got %d", test.name, test.requests, srv.count())
+		}
+		if gaveUp == nil || gaveUp.Attempt != test.requests || gaveUp.StatusCode != 429 {
+			t.Errorf("%s: expected OnGiveUp after attempt %d, got %#v", test.name, test.requests, gaveUp)
+		}
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	srv := newFlakyServer(t, fail(503, ""))
+
+	if _, err := srv.api(NoRetry).CurrentShop(); err == nil {
+		t.Errorf("Expected error")
+	}
+	if srv.count() != 1 {
+		t.Errorf("Expected 1 request, got %d", srv.count())
+	}
+}
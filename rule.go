@@ -1,9 +1,460 @@
 package shopify
 
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RuleColumn is the product attribute a smart collection rule tests.
+type RuleColumn string
+
+// RuleRelation is how a smart collection rule compares its column with its
+// condition.
+type RuleRelation string
+
+const (
+	RuleColumnTitle                 RuleColumn = "title"
+	RuleColumnType                  RuleColumn = "type"
+	RuleColumnVendor                RuleColumn = "vendor"
+	RuleColumnTag                   RuleColumn = "tag"
+	RuleColumnVariantTitle          RuleColumn = "variant_title"
+	RuleColumnVariantPrice          RuleColumn = "variant_price"
+	RuleColumnVariantCompareAtPrice RuleColumn = "variant_compare_at_price"
+	RuleColumnVariantWeight         RuleColumn = "variant_weight"
+	RuleColumnVariantInventory      RuleColumn = "variant_inventory"
+	RuleColumnIsPriceReduced        RuleColumn = "is_price_reduced"
+	// RuleColumnCategory tests the product's category, whose ID in the
+	// product taxonomy is the condition.
+	RuleColumnCategory RuleColumn = "product_taxonomy_node_id"
+	// The metafield columns test the metafield of the definition whose ID
+	// is the rule's ConditionObjectID.
+	RuleColumnProductMetafield RuleColumn = "product_metafield_definition"
+	RuleColumnVariantMetafield RuleColumn = "variant_metafield_definition"
+)
+
+const (
+	RuleRelationEquals      RuleRelation = "equals"
+	RuleRelationNotEquals   RuleRelation = "not_equals"
+	RuleRelationGreaterThan RuleRelation = "greater_than"
+	RuleRelationLessThan    RuleRelation = "less_than"
+	RuleRelationStartsWith  RuleRelation = "starts_with"
+	RuleRelationEndsWith    RuleRelation = "ends_with"
+	RuleRelationContains    RuleRelation = "contains"
+	RuleRelationNotContains RuleRelation = "not_contains"
+	RuleRelationIsSet       RuleRelation = "is_set"
+	RuleRelationIsNotSet    RuleRelation = "is_not_set"
+)
+
+var (
+	textRelations    = []RuleRelation{RuleRelationEquals, RuleRelationNotEquals, RuleRelationStartsWith, RuleRelationEndsWith, RuleRelationContains, RuleRelationNotContains}
+	numberRelations  = []RuleRelation{RuleRelationEquals, RuleRelationNotEquals, RuleRelationGreaterThan, RuleRelationLessThan}
+	presentRelations = []RuleRelation{RuleRelationIsSet, RuleRelationIsNotSet}
+)
+
+// ruleRelations are the relations Shopify accepts for each column.
+var ruleRelations = map[RuleColumn][]RuleRelation{
+	RuleColumnTitle:                 textRelations,
+	RuleColumnType:                  textRelations,
+	RuleColumnVendor:                textRelations,
+	RuleColumnVariantTitle:          textRelations,
+	RuleColumnTag:                   {RuleRelationEquals},
+	RuleColumnVariantPrice:          numberRelations,
+	RuleColumnVariantCompareAtPrice: numberRelations,
+	RuleColumnVariantWeight:         numberRelations,
+	RuleColumnVariantInventory:      numberRelations,
+	RuleColumnIsPriceReduced:        presentRelations,
+	RuleColumnCategory:              {RuleRelationEquals},
+	RuleColumnProductMetafield:      append(append([]RuleRelation{}, numberRelations...), RuleRelationContains),
+	RuleColumnVariantMetafield:      append(append([]RuleRelation{}, numberRelations...), RuleRelationContains),
+}
+
+// ErrRuleNotEvaluable is returned when evaluating rules on columns which
+// products don't carry, such as categories and metafields.
+var ErrRuleNotEvaluable = errors.New("rule can't be evaluated locally")
+
+// Rule is a condition of a smart collection. Column and Relation hold
+// RuleColumn and RuleRelation values; see RuleBuilder to build rules.
 type Rule struct {
 	Column string `json:"column"`
 
 	Relation string `json:"relation"`
 
 	Condition string `json:"condition"`
+
+	// ConditionObjectID is the ID of the metafield definition tested by
+	// the metafield columns.
+	ConditionObjectID string `json:"condition_object_id,omitempty"`
+}
+
+// Relations returns the relations which apply to the column, or nil if
+// Shopify doesn't know it.
+func (c RuleColumn) Relations() []RuleRelation {
+	return append([]RuleRelation(nil), ruleRelations[c]...)
+}
+
+// Allows returns true if the relation applies to the column.
+func (c RuleColumn) Allows(relation RuleRelation) bool {
+	for _, r := range ruleRelations[c] {
+		if r == relation {
+			return true
+		}
+	}
+	return false
+}
+
+// isNumber returns true for the columns with numeric conditions.
+func (c RuleColumn) isNumber() bool {
+	switch c {
+	case RuleColumnVariantPrice, RuleColumnVariantCompareAtPrice, RuleColumnVariantWeight, RuleColumnVariantInventory:
+		return true
+	}
+	return false
+}
+
+// Validate checks the rule as Shopify does when the collection is saved:
+// the column must be known, the relation must apply to it, and the condition
+// must be set, and be a number for numeric columns.
+func (r Rule) Validate() error {
+	if problem := r.problem(); problem != "" {
+		return &ValidationError{Fields: map[string][]string{"rules": {problem}}}
+	}
+	return nil
+}
+
+func (r Rule) problem() string {
+	column, relation := RuleColumn(r.Column), RuleRelation(r.Relation)
+	relations, ok := ruleRelations[column]
+	switch {
+	case !ok:
+		return fmt.Sprintf("column %q is not valid", r.Column)
+	case !column.Allows(relation):
+		names := []string{}
+		for _, relation := range relations {
+			names = append(names, string(relation))
+		}
+		return fmt.Sprintf("relation %q is not valid for column %s, use %s", r.Relation, r.Column, strings.Join(names, ", "))
+	case relation == RuleRelationIsSet || relation == RuleRelationIsNotSet:
+		return ""
+	case strings.TrimSpace(r.Condition) == "":
+		return fmt.Sprintf("condition of %s %s can't be blank", r.Column, r.Relation)
+	case (column == RuleColumnProductMetafield || column == RuleColumnVariantMetafield) && r.ConditionObjectID == "":
+		return fmt.Sprintf("column %s needs the metafield definition as condition_object_id", r.Column)
+	}
+	if column.isNumber() {
+		if _, err := strconv.ParseFloat(r.Condition, 64); err != nil {
+			return fmt.Sprintf("condition %q of %s is not a number", r.Condition, r.Column)
+		}
+	}
+	return ""
+}
+
+// Matches returns true if product satisfies the rule. Text is compared
+// case-insensitively, and rules on variants are satisfied by any variant,
+// except is_price_reduced is_not_set, which requires that none is reduced.
+// Weights are compared in the unit of each variant. Rules on columns
+// products don't carry return ErrRuleNotEvaluable.
+func (r Rule) Matches(product *Product) (bool, error) {
+	if err := r.Validate(); err != nil {
+		return false, err
+	}
+
+	column, relation := RuleColumn(r.Column), RuleRelation(r.Relation)
+	switch column {
+	case RuleColumnTitle:
+		return matchText(stringValue(product.Title), relation, r.Condition), nil
+	case RuleColumnType:
+		return matchText(stringValue(product.ProductType), relation, r.Condition), nil
+	case RuleColumnVendor:
+		return matchText(stringValue(product.Vendor), relation, r.Condition), nil
+	case RuleColumnTag:
+		for _, tag := range strings.Split(stringValue(product.Tags), ",") {
+			if strings.EqualFold(strings.TrimSpace(tag), strings.TrimSpace(r.Condition)) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case RuleColumnVariantTitle:
+		return anyVariant(product, func(v *Variant) bool {
+			return matchText(stringValue(v.Title), relation, r.Condition)
+		}), nil
+	case RuleColumnIsPriceReduced:
+		return anyVariant(product, priceReduced) == (relation == RuleRelationIsSet), nil
+	}
+
+	if !column.isNumber() {
+		return false, fmt.Errorf("%s: %w", r.Column, ErrRuleNotEvaluable)
+	}
+	condition, _ := strconv.ParseFloat(r.Condition, 64)
+	return anyVariant(product, func(v *Variant) bool {
+		value, ok := variantNumber(v, column)
+		return ok && matchNumber(value, relation, condition)
+	}), nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func matchText(value string, relation RuleRelation, condition string) bool {
+	value, condition = strings.ToLower(value), strings.ToLower(condition)
+	switch relation {
+	case RuleRelationEquals:
+		return value == condition
+	case RuleRelationNotEquals:
+		return value != condition
+	case RuleRelationStartsWith:
+		return strings.HasPrefix(value, condition)
+	case RuleRelationEndsWith:
+		return strings.HasSuffix(value, condition)
+	case RuleRelationContains:
+		return strings.Contains(value, condition)
+	case RuleRelationNotContains:
+		return !strings.Contains(value, condition)
+	}
+	return false
+}
+
+func matchNumber(value float64, relation RuleRelation, condition float64) bool {
+	switch relation {
+	case RuleRelationEquals:
+		return value == condition
+	case RuleRelationNotEquals:
+		return value != condition
+	case RuleRelationGreaterThan:
+		return value > condition
+	case RuleRelationLessThan:
+		return value < condition
+	}
+	return false
+}
+
+func anyVariant(product *Product, f func(v *Variant) bool) bool {
+	for i := range product.Variants {
+		if f(&product.Variants[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// variantNumber returns the value of a numeric column of v, and false if
+// it's not set.
+func variantNumber(v *Variant, column RuleColumn) (float64, bool) {
+	switch column {
+	case RuleColumnVariantPrice:
+		return parsePrice(v.Price)
+	case RuleColumnVariantCompareAtPrice:
+		return parsePrice(v.CompareAtPrice)
+	case RuleColumnVariantWeight:
+		return v.Weight, true
+	case RuleColumnVariantInventory:
+		return float64(v.InventoryQuantity), true
+	}
+	return 0, false
+}
+
+func parsePrice(price string) (float64, bool) {
+	if price == "" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(price, 64)
+	return f, err == nil
+}
+
+func priceReduced(v *Variant) bool {
+	price, ok := parsePrice(v.Price)
+	compareAt, compared := parsePrice(v.CompareAtPrice)
+	return ok && compared && compareAt > price
+}
+
+// RuleBuilder builds the rules of a smart collection:
+//
+//	err := shopify.NewRuleBuilder().
+//		Vendor().Equals("Acme").
+//		VariantPrice().LessThan(20).
+//		Apply(collection)
+//
+// Rules are collected as they're added, and validated by Rules and Apply.
+type RuleBuilder struct {
+	rules       []Rule
+	disjunctive bool
+}
+
+// RuleCondition completes a rule on a column of a RuleBuilder.
+type RuleCondition struct {
+	builder *RuleBuilder
+	column  RuleColumn
+	// objectID is the metafield definition of the metafield columns.
+	objectID string
+}
+
+// NewRuleBuilder returns a builder of rules which products must all
+// satisfy.
+func NewRuleBuilder() *RuleBuilder {
+	return &RuleBuilder{}
+}
+
+// Any makes products only need to satisfy one of the rules.
+func (b *RuleBuilder) Any() *RuleBuilder {
+	b.disjunctive = true
+	return b
+}
+
+// All makes products need to satisfy all the rules, as they do by default.
+func (b *RuleBuilder) All() *RuleBuilder {
+	b.disjunctive = false
+	return b
+}
+
+// Add adds a rule.
+func (b *RuleBuilder) Add(column RuleColumn, relation RuleRelation, condition string) *RuleBuilder {
+	b.rules = append(b.rules, Rule{Column: string(column), Relation: string(relation), Condition: condition})
+	return b
+}
+
+// Column starts a rule on column.
+func (b *RuleBuilder) Column(column RuleColumn) *RuleCondition {
+	return &RuleCondition{builder: b, column: column}
+}
+
+// The column methods start a rule on their column.
+
+func (b *RuleBuilder) Title() *RuleCondition {
+	return b.Column(RuleColumnTitle)
+}
+
+func (b *RuleBuilder) Type() *RuleCondition {
+	return b.Column(RuleColumnType)
+}
+
+func (b *RuleBuilder) Vendor() *RuleCondition {
+	return b.Column(RuleColumnVendor)
+}
+
+func (b *RuleBuilder) Tag() *RuleCondition {
+	return b.Column(RuleColumnTag)
+}
+
+func (b *RuleBuilder) VariantTitle() *RuleCondition {
+	return b.Column(RuleColumnVariantTitle)
+}
+
+func (b *RuleBuilder) VariantPrice() *RuleCondition {
+	return b.Column(RuleColumnVariantPrice)
+}
+
+func (b *RuleBuilder) VariantCompareAtPrice() *RuleCondition {
+	return b.Column(RuleColumnVariantCompareAtPrice)
+}
+
+func (b *RuleBuilder) VariantWeight() *RuleCondition {
+	return b.Column(RuleColumnVariantWeight)
+}
+
+func (b *RuleBuilder) VariantInventory() *RuleCondition {
+	return b.Column(RuleColumnVariantInventory)
+}
+
+func (b *RuleBuilder) IsPriceReduced() *RuleCondition {
+	return b.Column(RuleColumnIsPriceReduced)
+}
+
+// ProductMetafield starts a rule on the product metafields of the
+// definition with definitionID, e.g. gid://shopify/MetafieldDefinition/1.
+func (b *RuleBuilder) ProductMetafield(definitionID string) *RuleCondition {
+	return &RuleCondition{builder: b, column: RuleColumnProductMetafield, objectID: definitionID}
+}
+
+// VariantMetafield starts a rule on the variant metafields of the
+// definition with definitionID.
+func (b *RuleBuilder) VariantMetafield(definitionID string) *RuleCondition {
+	return &RuleCondition{builder: b, column: RuleColumnVariantMetafield, objectID: definitionID}
+}
+
+// Rules returns the rules, or a *ValidationError listing the invalid ones.
+func (b *RuleBuilder) Rules() ([]Rule, error) {
+	if err := validateRules(b.rules); err != nil {
+		return nil, err
+	}
+	return append([]Rule(nil), b.rules...), nil
+}
+
+// Apply sets the rules of collection, if they're valid.
+func (b *RuleBuilder) Apply(collection *SmartCollection) error {
+	rules, err := b.Rules()
+	if err != nil {
+		return err
+	}
+	collection.Rules = rules
+	collection.Disjunctive = b.disjunctive
+	return nil
+}
+
+func (c *RuleCondition) add(relation RuleRelation, condition string) *RuleBuilder {
+	c.builder.Add(c.column, relation, condition)
+	c.builder.rules[len(c.builder.rules)-1].ConditionObjectID = c.objectID
+	return c.builder
+}
+
+func (c *RuleCondition) Equals(condition string) *RuleBuilder {
+	return c.add(RuleRelationEquals, condition)
+}
+
+func (c *RuleCondition) NotEquals(condition string) *RuleBuilder {
+	return c.add(RuleRelationNotEquals, condition)
+}
+
+func (c *RuleCondition) StartsWith(condition string) *RuleBuilder {
+	return c.add(RuleRelationStartsWith, condition)
+}
+
+func (c *RuleCondition) EndsWith(condition string) *RuleBuilder {
+	return c.add(RuleRelationEndsWith, condition)
+}
+
+func (c *RuleCondition) Contains(condition string) *RuleBuilder {
+	return c.add(RuleRelationContains, condition)
+}
+
+func (c *RuleCondition) NotContains(condition string) *RuleBuilder {
+	return c.add(RuleRelationNotContains, condition)
+}
+
+func (c *RuleCondition) GreaterThan(condition float64) *RuleBuilder {
+	return c.add(RuleRelationGreaterThan, strconv.FormatFloat(condition, 'f', -1, 64))
+}
+
+func (c *RuleCondition) LessThan(condition float64) *RuleBuilder {
+	return c.add(RuleRelationLessThan, strconv.FormatFloat(condition, 'f', -1, 64))
+}
+
+func (c *RuleCondition) IsSet() *RuleBuilder {
+	return c.add(RuleRelationIsSet, "")
+}
+
+func (c *RuleCondition) IsNotSet() *RuleBuilder {
+	return c.add(RuleRelationIsNotSet, "")
+}
+
+// validateRules returns a *ValidationError listing the problems of rules
+// under "rules".
+func validateRules(rules []Rule) error {
+	problems := []string{}
+	if len(rules) == 0 {
+		problems = append(problems, "can't be blank")
+	}
+	for _, rule := range rules {
+		if problem := rule.problem(); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Fields: map[string][]string{"rules": problems}}
+	}
+	return nil
 }
@@ -0,0 +1,148 @@
+package shopify
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		column    RuleColumn
+		relation  RuleRelation
+		condition string
+		objectID  string
+		problem   string
+	}{
+		{RuleColumnTitle, RuleRelationContains, "shirt", "", ""},
+		{RuleColumnTag, RuleRelationEquals, "sale", "", ""},
+		{RuleColumnTag, RuleRelationContains, "sale", "", `relation "contains" is not valid for column tag, use equals`},
+		{RuleColumnVariantPrice, RuleRelationStartsWith, "1", "", "not valid for column variant_price"},
+		{RuleColumnVariantPrice, RuleRelationLessThan, "cheap", "", "is not a number"},
+		{RuleColumnVendor, RuleRelationEquals, " ", "", "can't be blank"},
+		{RuleColumnIsPriceReduced, RuleRelationIsSet, "", "", ""},
+		{RuleColumnIsPriceReduced, RuleRelationEquals, "true", "", "not valid for column is_price_reduced"},
+		{RuleColumnProductMetafield, RuleRelationEquals, "cotton", "", "condition_object_id"},
+		{RuleColumnProductMetafield, RuleRelationEquals, "cotton", "gid://shopify/MetafieldDefinition/1", ""},
+		{"colour", RuleRelationEquals, "red", "", `column "colour" is not valid`},
+	}
+
+	for _, test := range tests {
+		rule := Rule{string(test.column), string(test.relation), test.condition, test.objectID}
+		err := rule.Validate()
+		var validationErr *ValidationError
+		switch {
+		case test.problem == "" && err != nil:
+			t.Errorf("%v: expected the rule to be valid, got %v", rule, err)
+		case test.problem != "" && (!errors.As(err, &validationErr) || !strings.Contains(validationErr.Fields["rules"][0], test.problem)):
+			t.Errorf("%v: expected %q, got %v", rule, test.problem, err)
+		}
+	}
+
+	if !RuleColumnVariantWeight.Allows(RuleRelationGreaterThan) || RuleColumnTitle.Allows(RuleRelationGreaterThan) {
+		t.Errorf("Expected numeric relations to apply to numeric columns only")
+	}
+	if relations := RuleColumn("colour").Relations(); relations != nil {
+		t.Errorf("Expected no relations for an unknown column, got %v", relations)
+	}
+}
+
+func TestRuleBuilder(t *testing.T) {
+	collection := &SmartCollection{}
+	err := NewRuleBuilder().Any().
+		Vendor().Equals("Acme").
+		VariantPrice().LessThan(19.5).
+		IsPriceReduced().IsSet().
+		Apply(collection)
+	if err != nil {
+		t.Fatalf("Error building rules: %v", err)
+	}
+	expected := []Rule{
+		{Column: string(RuleColumnVendor), Relation: string(RuleRelationEquals), Condition: "Acme"},
+		{Column: string(RuleColumnVariantPrice), Relation: string(RuleRelationLessThan), Condition: "19.5"},
+		{Column: string(RuleColumnIsPriceReduced), Relation: string(RuleRelationIsSet)},
+	}
+	if !collection.Disjunctive || len(collection.Rules) != 3 {
+		t.Fatalf("Expected 3 disjunctive rules, got %#v", collection)
+	}
+	for i, rule := range expected {
+		if collection.Rules[i] != rule {
+			t.Errorf("Expected rule %d to be %v, got %v", i, rule, collection.Rules[i])
+		}
+	}
+
+	rules, err := NewRuleBuilder().
+		ProductMetafield("gid://shopify/MetafieldDefinition/1").Contains("cotton").
+		VariantMetafield("gid://shopify/MetafieldDefinition/2").GreaterThan(2).
+		Rules()
+	if err != nil || len(rules) != 2 || rules[0].ConditionObjectID != "gid://shopify/MetafieldDefinition/1" || rules[1].Column != string(RuleColumnVariantMetafield) || rules[1].ConditionObjectID != "gid://shopify/MetafieldDefinition/2" {
+		t.Errorf("Expected metafield rules with their definitions, got %#v (%v)", rules, err)
+	}
+
+	_, err = NewRuleBuilder().Tag().StartsWith("sale").Title().Contains("").Rules()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Fields["rules"]) != 2 {
+		t.Errorf("Expected both invalid rules to be reported, got %v", err)
+	}
+	if _, err := NewRuleBuilder().Rules(); err == nil {
+		t.Errorf("Expected an error without rules")
+	}
+}
+
+func TestSmartCollectionPreview(t *testing.T) {
+	str := func(s string) *string { return &s }
+	products := []*Product{
+		{ID: 1, Title: str("Blue T-Shirt"), Vendor: str("Acme"), Tags: str("summer, Sale"), Variants: []Variant{
+			{Title: str("S"), Price: "15.00", CompareAtPrice: "20.00", InventoryQuantity: 3},
+			{Title: str("XL"), Price: "18.00", InventoryQuantity: 0},
+		}},
+		{ID: 2, Title: str("Mug"), Vendor: str("acme"), Variants: []Variant{
+			{Title: str("Default Title"), Price: "25.00", InventoryQuantity: 10, Weight: 0.4},
+		}},
+		{ID: 3, Title: str("Red shirt"), Vendor: str("Other"), Tags: str("sale"), Variants: []Variant{
+			{Title: str("M"), Price: "30.00", InventoryQuantity: 5},
+		}},
+	}
+	ids := func(products []*Product) []int64 {
+		result := []int64{}
+		for _, product := range products {
+			result = append(result, product.ID)
+		}
+		return result
+	}
+
+	tests := []struct {
+		builder  *RuleBuilder
+		expected []int64
+	}{
+		{NewRuleBuilder().Vendor().Equals("ACME"), []int64{1, 2}},
+		{NewRuleBuilder().Title().EndsWith("shirt").Tag().Equals("sale"), []int64{1, 3}},
+		{NewRuleBuilder().Title().NotContains("shirt").VariantPrice().GreaterThan(20), []int64{2}},
+		{NewRuleBuilder().Any().VariantInventory().Equals("0").VariantWeight().GreaterThan(0.1), []int64{1, 2}},
+		{NewRuleBuilder().IsPriceReduced().IsSet(), []int64{1}},
+		// product 1 has a variant at full price, but also a reduced one
+		{NewRuleBuilder().IsPriceReduced().IsNotSet(), []int64{2, 3}},
+		// each rule can be satisfied by a different variant
+		{NewRuleBuilder().VariantTitle().Equals("xl").VariantCompareAtPrice().LessThan(100), []int64{1}},
+		{NewRuleBuilder().Title().StartsWith("green"), []int64{}},
+	}
+	for i, test := range tests {
+		collection := &SmartCollection{}
+		if err := test.builder.Apply(collection); err != nil {
+			t.Fatalf("%d: error building rules: %v", i, err)
+		}
+		matched, err := collection.Preview(products)
+		if err != nil {
+			t.Fatalf("%d: error previewing: %v", i, err)
+		}
+		if got := ids(matched); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%d: expected products %v, got %v", i, test.expected, got)
+		}
+	}
+
+	collection := &SmartCollection{Rules: []Rule{{Column: string(RuleColumnCategory), Relation: string(RuleRelationEquals), Condition: "gid://shopify/TaxonomyCategory/aa-1"}}}
+	if _, err := collection.Preview(products); !errors.Is(err, ErrRuleNotEvaluable) {
+		t.Errorf("Expected category rules not to be evaluable, got %v", err)
+	}
+}
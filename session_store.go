@@ -0,0 +1,277 @@
+package shopify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSessionNotFound is returned by a SessionStore when no session is stored
+// for the requested shop and user.
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrSessionExpired is returned by LoadAPI when the stored online session has expired.
+var ErrSessionExpired = errors.New("session expired")
+
+// Session is an access token stored for a shop. Offline sessions have a zero
+// UserID; online sessions belong to the staff member identified by UserID.
+type Session struct {
+	Shop           string          `json:"shop"`
+	UserID         int64           `json:"user_id,omitempty"`
+	AccessToken    string          `json:"access_token"`
+	Scope          string          `json:"scope,omitempty"`
+	ExpiresAt      time.Time       `json:"expires_at,omitempty"`
+	AssociatedUser *AssociatedUser `json:"associated_user,omitempty"`
+}
+
+// NewSession builds a session for shop from an access token response.
+func NewSession(shop string, token *AccessTokenResponse) *Session {
+	session := &Session{
+		Shop:           shop,
+		AccessToken:    token.AccessToken,
+		Scope:          token.Scope,
+		ExpiresAt:      token.ExpiresAt,
+		AssociatedUser: token.AssociatedUser,
+	}
+	if token.AssociatedUser != nil {
+		session.UserID = token.AssociatedUser.ID
+	}
+	return session
+}
+
+// Expired returns true if the session's access token has expired.
+func (s *Session) Expired() bool {
+	return !s.ExpiresAt.IsZero() && !time.Now().Before(s.ExpiresAt)
+}
+
+// API returns an API client for the session's shop and access token.
+func (s *Session) API() *API {
+	return &API{Shop: s.Shop, AccessToken: s.AccessToken}
+}
+
+// String implements fmt.Stringer, hiding the access token.
+func (s Session) String() string {
+	return fmt.Sprintf("Session{Shop: %s, UserID: %d, AccessToken: %s, Scope: %s, ExpiresAt: %s}",
+		s.Shop, s.UserID, redactSecret(s.AccessToken), s.Scope, s.ExpiresAt)
+}
+
+// GoString implements fmt.GoStringer, hiding the access token.
+func (s Session) GoString() string {
+	return "shopify." + s.String()
+}
+
+// SessionStore persists sessions by shop and user. A userID of 0 refers to the
+// shop's offline session.
+type SessionStore interface {
+	SaveSession(session *Session) error
+	LoadSession(shop string, userID int64) (*Session, error)
+	DeleteSession(shop string, userID int64) error
+	// DeleteShopSessions deletes the offline session and every online session
+	// stored for shop.
+	DeleteShopSessions(shop string) error
+}
+
+// LoadAPI loads the session for shop and userID from store and returns an API
+// client for it.
+func LoadAPI(store SessionStore, shop string, userID int64) (*API, error) {
+	session, err := store.LoadSession(shop, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Expired() {
+		return nil, ErrSessionExpired
+	}
+	return session.API(), nil
+}
+
+// MemorySessionStore is a SessionStore that keeps sessions in memory. It is
+// safe for concurrent use.
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]map[int64]Session
+}
+
+// NewMemorySessionStore returns an empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]map[int64]Session{}}
+}
+
+func (m *MemorySessionStore) SaveSession(session *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sessions[session.Shop] == nil {
+		m.sessions[session.Shop] = map[int64]Session{}
+	}
+	m.sessions[session.Shop][session.UserID] = *session
+	return nil
+}
+
+func (m *MemorySessionStore) LoadSession(shop string, userID int64) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.sessions[shop][userID]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (m *MemorySessionStore) DeleteSession(shop string, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions[shop], userID)
+	return nil
+}
+
+func (m *MemorySessionStore) DeleteShopSessions(shop string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, shop)
+	return nil
+}
+
+// FileSessionStore is a SessionStore that keeps each session as a JSON file in
+// a directory. Files are written with 0600 permissions.
+type FileSessionStore struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+// NewFileSessionStore returns a FileSessionStore using dir, creating it if needed.
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileSessionStore{Dir: dir}, nil
+}
+
+func (f *FileSessionStore) filePrefix(shop string) string {
+	// QueryEscape never leaves a path separator, so the file stays inside Dir.
+	return url.QueryEscape(shop) + "_"
+}
+
+func (f *FileSessionStore) path(shop string, userID int64) string {
+	return filepath.Join(f.Dir, fmt.Sprintf("%s%d.json", f.filePrefix(shop), userID))
+}
+
+func (f *FileSessionStore) SaveSession(session *Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tmp, err := os.CreateTemp(f.Dir, ".session-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = json.NewEncoder(tmp).Encode(session); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path(session.Shop, session.UserID))
+}
+
+func (f *FileSessionStore) LoadSession(shop string, userID int64) (*Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.path(shop, userID))
+	if os.IsNotExist(err) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	session := &Session{}
+	if err = json.NewDecoder(file).Decode(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (f *FileSessionStore) DeleteSession(shop string, userID int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := os.Remove(f.path(shop, userID))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (f *FileSessionStore) DeleteShopSessions(shop string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := os.ReadDir(f.Dir)
+	if err != nil {
+		return err
+	}
+
+	prefix := f.filePrefix(shop)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		err = os.Remove(filepath.Join(f.Dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// UninstallHandler returns a webhook handler for the app/uninstalled topic
+// which purges every session stored for the uninstalling shop.
+func (s *App) UninstallHandler(store SessionStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := s.VerifyWebhook(r)
+		if errors.Is(err, ErrInvalidWebhookSignature) {
+			http.Error(w, "Invalid signature", 401)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Bad Request", 400)
+			return
+		}
+
+		if topic := r.Header.Get("X-Shopify-Topic"); topic != "app/uninstalled" {
+			http.Error(w, fmt.Sprintf("Unexpected topic %q", topic), 400)
+			return
+		}
+
+		shop := r.Header.Get("X-Shopify-Shop-Domain")
+		if shop == "" {
+			http.Error(w, "Expected X-Shopify-Shop-Domain header", 400)
+			return
+		}
+
+		if err = store.DeleteShopSessions(shop); err != nil {
+			s.log(r.Context(), "deleting sessions failed", err, slog.String("shop", shop))
+			http.Error(w, "Internal Server Error", 500)
+			return
+		}
+		s.log(r.Context(), "app uninstalled", nil, slog.String("shop", shop))
+	})
+}
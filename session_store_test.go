@@ -0,0 +1,108 @@
+package shopify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSessionStore(t *testing.T, store SessionStore) {
+	offline := &Session{Shop: "burnsmod.myshopify.com", AccessToken: "offline-token"}
+	online := &Session{Shop: "burnsmod.myshopify.com", UserID: 42, AccessToken: "online-token", ExpiresAt: time.Now().Add(time.Hour)}
+	other := &Session{Shop: "other.myshopify.com", AccessToken: "other-token"}
+
+	for _, session := range []*Session{offline, online, other} {
+		if err := store.SaveSession(session); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+	}
+
+	session, err := store.LoadSession("burnsmod.myshopify.com", 42)
+	if err != nil {
+		t.Fatalf("Error loading session: %v", err)
+	}
+	if session.AccessToken != "online-token" {
+		t.Errorf("Expected online-token, got %s", session.AccessToken)
+	}
+
+	api, err := LoadAPI(store, "burnsmod.myshopify.com", 0)
+	if err != nil {
+		t.Fatalf("Error loading API: %v", err)
+	}
+	if api.Shop != "burnsmod.myshopify.com" || api.AccessToken != "offline-token" {
+		t.Errorf("Unexpected API %#v", api)
+	}
+
+	if err = store.DeleteSession("burnsmod.myshopify.com", 42); err != nil {
+		t.Fatalf("Error deleting session: %v", err)
+	}
+	if _, err = store.LoadSession("burnsmod.myshopify.com", 42); err != ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+
+	if err = store.DeleteShopSessions("burnsmod.myshopify.com"); err != nil {
+		t.Fatalf("Error deleting shop sessions: %v", err)
+	}
+	if _, err = store.LoadSession("burnsmod.myshopify.com", 0); err != ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+	if _, err = store.LoadSession("other.myshopify.com", 0); err != nil {
+		t.Errorf("Expected other shop's session to be kept, got %v", err)
+	}
+}
+
+func TestMemorySessionStore(t *testing.T) {
+	testSessionStore(t, NewMemorySessionStore())
+}
+
+func TestFileSessionStore(t *testing.T) {
+	store, err := NewFileSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Error creating file session store: %v", err)
+	}
+	testSessionStore(t, store)
+}
+
+func TestLoadAPIExpiredSession(t *testing.T) {
+	store := NewMemorySessionStore()
+	store.SaveSession(&Session{Shop: "burnsmod.myshopify.com", UserID: 42, AccessToken: "online-token", ExpiresAt: time.Now().Add(-time.Minute)})
+
+	if _, err := LoadAPI(store, "burnsmod.myshopify.com", 42); err != ErrSessionExpired {
+		t.Errorf("Expected ErrSessionExpired, got %v", err)
+	}
+}
+
+func TestUninstallHandler(t *testing.T) {
+	store := NewMemorySessionStore()
+	store.SaveSession(&Session{Shop: "burnsmod.myshopify.com", AccessToken: "offline-token"})
+
+	body := `{"id": 1, "domain": "burnsmod.myshopify.com"}`
+	mac := hmac.New(sha256.New, []byte("1234"))
+	mac.Write([]byte(body))
+
+	req := httptest.NewRequest("POST", "/webhooks/uninstalled", strings.NewReader(body))
+	req.Header.Set("X-Shopify-Topic", "app/uninstalled")
+	req.Header.Set("X-Shopify-Shop-Domain", "burnsmod.myshopify.com")
+	req.Header.Set("X-Shopify-Hmac-SHA256", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	w := httptest.NewRecorder()
+
+	app.UninstallHandler(store).ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+	if _, err := store.LoadSession("burnsmod.myshopify.com", 0); err != ErrSessionNotFound {
+		t.Errorf("Expected session to be purged, got %v", err)
+	}
+
+	forged := httptest.NewRequest("POST", "/webhooks/uninstalled", strings.NewReader(body))
+	forged.Header.Set("X-Shopify-Hmac-SHA256", base64.StdEncoding.EncodeToString([]byte("forged")))
+	if _, err := app.VerifyWebhook(forged); !errors.Is(err, ErrInvalidWebhookSignature) {
+		t.Errorf("Expected ErrInvalidWebhookSignature, got %v", err)
+	}
+}
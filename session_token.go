@@ -0,0 +1,190 @@
+package shopify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SessionTokenLeeway is the clock skew tolerated when checking the exp and nbf
+// claims of a session token.
+var SessionTokenLeeway = 5 * time.Second
+
+// ErrInvalidSessionToken is returned (wrapped) by VerifySessionToken when a
+// session token is malformed, incorrectly signed, expired or issued for
+// another app or shop.
+var ErrInvalidSessionToken = errors.New("invalid session token")
+
+var shopDomainRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9\-]*\.myshopify\.com$`)
+
+// ValidShopDomain returns true if shop looks like a myshopify.com domain,
+// e.g. demo-3.myshopify.com.
+func ValidShopDomain(shop string) bool {
+	return shopDomainRegexp.MatchString(shop)
+}
+
+// SessionTokenClaims are the claims of an App Bridge session token.
+type SessionTokenClaims struct {
+	Issuer    string `json:"iss"`
+	Dest      string `json:"dest"`
+	Audience  string `json:"aud"`
+	Subject   string `json:"sub"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf"`
+	IssuedAt  int64  `json:"iat"`
+	ID        string `json:"jti"`
+	SessionID string `json:"sid"`
+}
+
+// Shop returns the shop domain the token was issued for, taken from the dest claim.
+func (c *SessionTokenClaims) Shop() string {
+	u, err := url.Parse(c.Dest)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// UserID returns the ID of the staff member the token was issued for, or 0
+// if the subject is not a user.
+func (c *SessionTokenClaims) UserID() int64 {
+	id, _ := strconv.ParseInt(c.Subject, 10, 64)
+	return id
+}
+
+// VerifySessionToken validates an App Bridge session token, signed with HS256
+// using the app's APISecret, and returns its claims. IgnoreSignature doesn't
+// apply to session tokens.
+func (s *App) VerifySessionToken(token string) (*SessionTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidSessionToken)
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ"`
+	}{}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidSessionToken, err)
+	}
+	if header.Alg != "HS256" {
+		return nil, fmt.Errorf("%w: unexpected algorithm %q", ErrInvalidSessionToken, header.Alg)
+	}
+
+	// The signature is checked even with IgnoreSignature: the token is the
+	// only credential of the request.
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidSessionToken, err)
+	}
+	mac := hmac.New(sha256.New, []byte(s.APISecret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidSessionToken)
+	}
+
+	claims := &SessionTokenClaims{}
+	if err := decodeJWTSegment(parts[1], claims); err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", ErrInvalidSessionToken, err)
+	}
+
+	if claims.Audience != s.APIKey {
+		return nil, fmt.Errorf("%w: audience does not match API key", ErrInvalidSessionToken)
+	}
+
+	now := time.Now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(SessionTokenLeeway)) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidSessionToken)
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0).Add(-SessionTokenLeeway)) {
+		return nil, fmt.Errorf("%w: token not valid yet", ErrInvalidSessionToken)
+	}
+
+	dest, err := url.Parse(claims.Dest)
+	if err != nil || dest.Scheme != "https" || !ValidShopDomain(dest.Host) {
+		return nil, fmt.Errorf("%w: dest is not a shop domain", ErrInvalidSessionToken)
+	}
+	iss, err := url.Parse(claims.Issuer)
+	if err != nil || iss.Host != dest.Host {
+		return nil, fmt.Errorf("%w: issuer does not match dest", ErrInvalidSessionToken)
+	}
+
+	return claims, nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+type contextKey int
+
+const sessionTokenContextKey contextKey = iota
+
+// SessionTokenMiddleware verifies the session token passed as a bearer token
+// in the Authorization header, and makes its claims available to next through
+// SessionTokenFromContext, ShopFromContext and UserIDFromContext.
+// Requests without a valid token are rejected with 401 Unauthorized, and the
+// X-Shopify-Retry-Invalid-Session-Request header is set so that App Bridge
+// fetches a new token and retries.
+func (s *App) SessionTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			s.log(r.Context(), "missing session token", ErrInvalidSessionToken, slog.String("path", r.URL.Path))
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+
+		claims, err := s.VerifySessionToken(strings.TrimPrefix(auth, "Bearer "))
+		if err != nil {
+			s.log(r.Context(), "invalid session token", err, slog.String("path", r.URL.Path))
+			w.Header().Set("X-Shopify-Retry-Invalid-Session-Request", "1")
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), sessionTokenContextKey, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// SessionTokenFromContext returns the session token claims stored by
+// SessionTokenMiddleware.
+func SessionTokenFromContext(ctx context.Context) (*SessionTokenClaims, bool) {
+	claims, ok := ctx.Value(sessionTokenContextKey).(*SessionTokenClaims)
+	return claims, ok
+}
+
+// ShopFromContext returns the shop domain of the session token stored by
+// SessionTokenMiddleware, or "" if there is none.
+func ShopFromContext(ctx context.Context) string {
+	if claims, ok := SessionTokenFromContext(ctx); ok {
+		return claims.Shop()
+	}
+	return ""
+}
+
+// UserIDFromContext returns the staff member ID of the session token stored
+// by SessionTokenMiddleware, or 0 if there is none.
+func UserIDFromContext(ctx context.Context) int64 {
+	if claims, ok := SessionTokenFromContext(ctx); ok {
+		return claims.UserID()
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package shopify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func signSessionToken(secret string, claims SessionTokenClaims) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	b, _ := json.Marshal(claims)
+	payload := base64.RawURLEncoding.EncodeToString(b)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(header + "." + payload))
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func validSessionTokenClaims() SessionTokenClaims {
+	now := time.Now()
+	return SessionTokenClaims{
+		Issuer:    "https://burnsmod.myshopify.com/admin",
+		Dest:      "https://burnsmod.myshopify.com",
+		Audience:  "asdf",
+		Subject:   "42",
+		ExpiresAt: now.Add(time.Minute).Unix(),
+		NotBefore: now.Unix(),
+		IssuedAt:  now.Unix(),
+		ID:        "00000000-0000-0000-0000-000000000000",
+		SessionID: "abc",
+	}
+}
+
+func TestVerifySessionToken(t *testing.T) {
+	claims, err := app.VerifySessionToken(signSessionToken("1234", validSessionTokenClaims()))
+	if err != nil {
+		t.Fatalf("Error verifying session token: %v", err)
+	}
+
+	if claims.Shop() != "burnsmod.myshopify.com" {
+		t.Errorf("Expected shop burnsmod.myshopify.com, got %s", claims.Shop())
+	}
+	if claims.UserID() != 42 {
+		t.Errorf("Expected user 42, got %d", claims.UserID())
+	}
+}
+
+func TestVerifySessionTokenInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		modify func(c *SessionTokenClaims)
+	}{
+		{"wrong secret", "wrong", func(c *SessionTokenClaims) {}},
+		{"wrong audience", "1234", func(c *SessionTokenClaims) { c.Audience = "other" }},
+		{"expired", "1234", func(c *SessionTokenClaims) { c.ExpiresAt = time.Now().Add(-time.Minute).Unix() }},
+		{"not yet valid", "1234", func(c *SessionTokenClaims) { c.NotBefore = time.Now().Add(time.Minute).Unix() }},
+		{"foreign dest", "1234", func(c *SessionTokenClaims) { c.Dest = "https://example.com" }},
+		{"issuer mismatch", "1234", func(c *SessionTokenClaims) { c.Issuer = "https://other.myshopify.com/admin" }},
+	}
+
+	for _, test := range tests {
+		claims := validSessionTokenClaims()
+		test.modify(&claims)
+
+		_, err := app.VerifySessionToken(signSessionToken(test.secret, claims))
+		if !errors.Is(err, ErrInvalidSessionToken) {
+			t.Errorf("%s: expected ErrInvalidSessionToken, got %v", test.name, err)
+		}
+	}
+
+	ignoring := app
+	ignoring.IgnoreSignature = true
+	if _, err := ignoring.VerifySessionToken(signSessionToken("wrong", validSessionTokenClaims())); !errors.Is(err, ErrInvalidSessionToken) {
+		t.Errorf("IgnoreSignature: expected ErrInvalidSessionToken, got %v", err)
+	}
+
+	if _, err := app.VerifySessionToken("not-a-token"); !errors.Is(err, ErrInvalidSessionToken) {
+		t.Errorf("malformed: expected ErrInvalidSessionToken, got %v", err)
+	}
+}
+
+func TestSessionTokenMiddleware(t *testing.T) {
+	var shop string
+	var userID int64
+	handler := app.SessionTokenMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		shop = ShopFromContext(r.Context())
+		userID = UserIDFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/api/products", nil)
+	req.Header.Set("Authorization", "Bearer "+signSessionToken("1234", validSessionTokenClaims()))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+	if shop != "burnsmod.myshopify.com" || userID != 42 {
+		t.Errorf("Unexpected shop %q and user %d in context", shop, userID)
+	}
+
+	req = httptest.NewRequest("GET", "/api/products", nil)
+	req.Header.Set("Authorization", "Bearer "+signSessionToken("wrong", validSessionTokenClaims()))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != 401 {
+		t.Errorf("Expected 401, got %d", w.Code)
+	}
+	if w.Header().Get("X-Shopify-Retry-Invalid-Session-Request") != "1" {
+		t.Errorf("Expected retry header to be set")
+	}
+}
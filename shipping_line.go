@@ -1,17 +1,20 @@
-package shopify
+// Code generated by shopify-gen from schema/resources.json. DO NOT EDIT.
 
-import (
-	"time"
-)
+package shopify
 
+// ShippingLine is a shipping method of an order or checkout.
 type ShippingLine struct {
-	Code string `json:"code"`
-
-	Price time.Time `json:"price"`
-
-	Source string `json:"source"`
-
-	Title string `json:"title"`
-
-	TaxLines []interface{} `json:"tax_lines"`
+	Id                            int64                `json:"id"`
+	CarrierIdentifier             string               `json:"carrier_identifier"`
+	Code                          string               `json:"code"`
+	DiscountAllocations           []DiscountAllocation `json:"discount_allocations"`
+	DiscountedPrice               string               `json:"discounted_price"`
+	DiscountedPriceSet            *PriceSet            `json:"discounted_price_set,omitempty"`
+	Phone                         string               `json:"phone"`
+	Price                         string               `json:"price"`
+	PriceSet                      *PriceSet            `json:"price_set,omitempty"`
+	RequestedFulfillmentServiceId string               `json:"requested_fulfillment_service_id"`
+	Source                        string               `json:"source"`
+	TaxLines                      []TaxLine            `json:"tax_lines"`
+	Title                         string               `json:"title"`
 }
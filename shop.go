@@ -1,10 +1,5 @@
 package shopify
 
-import (
-	"encoding/json"
-	"fmt"
-)
-
 type Shop struct {
 	Address1                        string  `json:"address1"`
 	Address2                        string  `json:"address2"`
@@ -58,29 +53,10 @@ type Shop struct {
 	api *API
 }
 
-func (api *API) CurrentShop() (*Shop, error) {
-	endpoint := "/admin/shop.json"
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
+var shopResource = newRESTResource[Shop]("/admin/shop", "shop", "shops")
 
-	r := map[string]Shop{}
-	err = json.NewDecoder(res).Decode(&r)
+func (obj *Shop) setAPI(api *API) { obj.api = api }
 
-	result := r["shop"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+func (api *API) CurrentShop() (*Shop, error) {
+	return shopResource.getAt(api, shopResource.collection())
 }
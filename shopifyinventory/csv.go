@@ -0,0 +1,59 @@
+package shopifyinventory
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadCSV reads desired levels from a stock file with a header row naming
+// the sku, location_id and available columns, in any order. Other columns
+// are ignored.
+func ReadCSV(r io.Reader) (map[Key]int64, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("shopifyinventory: reading header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"sku", "location_id", "available"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("shopifyinventory: no %s column", name)
+		}
+	}
+
+	result := map[Key]int64{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("shopifyinventory: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		key := Key{SKU: strings.TrimSpace(record[columns["sku"]])}
+		if key.SKU == "" {
+			return nil, fmt.Errorf("shopifyinventory: line %d: no SKU", line)
+		}
+		if key.LocationID, err = strconv.ParseInt(strings.TrimSpace(record[columns["location_id"]]), 10, 64); err != nil {
+			return nil, fmt.Errorf("shopifyinventory: line %d: invalid location_id: %w", line, err)
+		}
+		available, err := strconv.ParseInt(strings.TrimSpace(record[columns["available"]]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("shopifyinventory: line %d: invalid available: %w", line, err)
+		}
+		if _, ok := result[key]; ok {
+			return nil, fmt.Errorf("shopifyinventory: line %d: %s is listed twice for location %d", line, key.SKU, key.LocationID)
+		}
+		result[key] = available
+	}
+}
@@ -0,0 +1,220 @@
+package shopifyinventory
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotJournaled is returned by a Journal when no adjustment is recorded
+// under a key.
+var ErrNotJournaled = errors.New("shopifyinventory: adjustment not journaled")
+
+// AdjustmentState is how far an adjustment got.
+type AdjustmentState string
+
+const (
+	// AdjustmentPending is an adjustment which may or may not have been
+	// applied by Shopify.
+	AdjustmentPending AdjustmentState = "pending"
+	// AdjustmentApplied is an adjustment Shopify applied.
+	AdjustmentApplied AdjustmentState = "applied"
+	// AdjustmentFailed is an adjustment Shopify rejected, which may be tried
+	// again under the same key.
+	AdjustmentFailed AdjustmentState = "failed"
+	// AdjustmentUnverified is an adjustment whose outcome couldn't be told
+	// from the level, because it was changed by something else meanwhile.
+	// It needs to be checked by hand.
+	AdjustmentUnverified AdjustmentState = "unverified"
+)
+
+// Adjustment is an adjustment of an inventory level, recorded in a Journal
+// under the key given by the client.
+type Adjustment struct {
+	Key             string `json:"key"`
+	InventoryItemID int64  `json:"inventory_item_id"`
+	LocationID      int64  `json:"location_id"`
+	Delta           int64  `json:"delta"`
+	// Before is the available quantity read before the adjustment was
+	// first sent, and Available the one after it was applied.
+	Before    int64           `json:"before"`
+	Available int64           `json:"available,omitempty"`
+	State     AdjustmentState `json:"state"`
+	Attempts  int             `json:"attempts"`
+	// Error is the last error met sending the adjustment.
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Expected returns the available quantity once the adjustment is applied.
+func (a *Adjustment) Expected() int64 {
+	return a.Before + a.Delta
+}
+
+// Journal persists adjustments by key, so that they survive the process
+// sending them.
+type Journal interface {
+	SaveAdjustment(adjustment *Adjustment) error
+	LoadAdjustment(key string) (*Adjustment, error)
+	// PendingAdjustments returns the adjustments in the pending state.
+	PendingAdjustments() ([]*Adjustment, error)
+}
+
+// MemoryJournal is a Journal that keeps adjustments in memory, for tests and
+// processes which don't need to recover from crashes. It is safe for
+// concurrent use.
+type MemoryJournal struct {
+	mu          sync.RWMutex
+	adjustments map[string]Adjustment
+}
+
+// NewMemoryJournal returns an empty MemoryJournal.
+func NewMemoryJournal() *MemoryJournal {
+	return &MemoryJournal{adjustments: map[string]Adjustment{}}
+}
+
+func (m *MemoryJournal) SaveAdjustment(adjustment *Adjustment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.adjustments[adjustment.Key] = *adjustment
+	return nil
+}
+
+func (m *MemoryJournal) LoadAdjustment(key string) (*Adjustment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	adjustment, ok := m.adjustments[key]
+	if !ok {
+		return nil, ErrNotJournaled
+	}
+	return &adjustment, nil
+}
+
+func (m *MemoryJournal) PendingAdjustments() ([]*Adjustment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := []*Adjustment{}
+	for _, adjustment := range m.adjustments {
+		if adjustment.State == AdjustmentPending {
+			adjustment := adjustment
+			result = append(result, &adjustment)
+		}
+	}
+	sortAdjustments(result)
+	return result, nil
+}
+
+// FileJournal is a Journal that keeps each adjustment as a JSON file in a
+// directory. Files are replaced atomically, so an adjustment is either
+// recorded in its previous state or its new one.
+type FileJournal struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+// NewFileJournal returns a FileJournal using dir, creating it if needed.
+func NewFileJournal(dir string) (*FileJournal, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileJournal{Dir: dir}, nil
+}
+
+func (f *FileJournal) path(key string) string {
+	// QueryEscape never leaves a path separator, so the file stays inside Dir.
+	return filepath.Join(f.Dir, url.QueryEscape(key)+".json")
+}
+
+func (f *FileJournal) SaveAdjustment(adjustment *Adjustment) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tmp, err := os.CreateTemp(f.Dir, ".adjustment-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = json.NewEncoder(tmp).Encode(adjustment); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path(adjustment.Key))
+}
+
+func (f *FileJournal) LoadAdjustment(key string) (*Adjustment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.load(f.path(key))
+}
+
+func (f *FileJournal) load(path string) (*Adjustment, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotJournaled
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	adjustment := &Adjustment{}
+	if err = json.NewDecoder(file).Decode(adjustment); err != nil {
+		return nil, err
+	}
+	return adjustment, nil
+}
+
+func (f *FileJournal) PendingAdjustments() ([]*Adjustment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := os.ReadDir(f.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*Adjustment{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		adjustment, err := f.load(filepath.Join(f.Dir, name))
+		if err != nil {
+			return nil, err
+		}
+		if adjustment.State == AdjustmentPending {
+			result = append(result, adjustment)
+		}
+	}
+	sortAdjustments(result)
+	return result, nil
+}
+
+// sortAdjustments sorts adjustments in the order they were made.
+func sortAdjustments(adjustments []*Adjustment) {
+	sort.Slice(adjustments, func(i, j int) bool {
+		if !adjustments[i].CreatedAt.Equal(adjustments[j].CreatedAt) {
+			return adjustments[i].CreatedAt.Before(adjustments[j].CreatedAt)
+		}
+		return adjustments[i].Key < adjustments[j].Key
+	})
+}
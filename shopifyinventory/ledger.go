@@ -0,0 +1,282 @@
+package shopifyinventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/boourns/go_shopify"
+)
+
+// DefaultMaxAttempts is the number of times a Ledger sends an adjustment by
+// default.
+const DefaultMaxAttempts = 3
+
+var (
+	// ErrAdjustmentUnverified is returned for adjustments in the
+	// AdjustmentUnverified state.
+	ErrAdjustmentUnverified = errors.New("shopifyinventory: adjustment can't be verified, the level changed meanwhile")
+	// ErrAdjustmentInProgress is returned when an adjustment is sent under
+	// a key which is already being sent.
+	ErrAdjustmentInProgress = errors.New("shopifyinventory: adjustment already in progress")
+	// ErrKeyReused is returned when a key is reused for a different
+	// adjustment.
+	ErrKeyReused = errors.New("shopifyinventory: key already used for another adjustment")
+)
+
+// Ledger adjusts inventory levels at most once per key. Shopify's adjust
+// action isn't idempotent, so every adjustment is journaled with the level
+// read before it. When the outcome of a request is unknown, e.g. after a
+// timeout, or after a crash, the level is read again: the adjustment was
+// applied if it's at the expected quantity, and is sent again if it's still
+// at the previous one.
+//
+// Changes made to a level by something else between the two reads can't be
+// told apart from the adjustment; such adjustments are marked
+// AdjustmentUnverified rather than risk counting them twice.
+type Ledger struct {
+	API     *shopify.API
+	Journal Journal
+	// MaxAttempts is the number of times an adjustment is sent while its
+	// outcome is unknown, DefaultMaxAttempts if zero.
+	MaxAttempts int
+
+	mu       sync.Mutex
+	inFlight map[string]bool
+}
+
+// NewLedger returns a Ledger adjusting levels with api and recording the
+// adjustments in journal.
+func NewLedger(api *shopify.API, journal Journal) *Ledger {
+	return &Ledger{API: api, Journal: journal}
+}
+
+// Adjust adjusts the available quantity of an inventory item at a location
+// by delta, unless an adjustment was already applied under key. The
+// adjustment is returned with an error if it wasn't applied; it's left
+// pending if its outcome is still unknown, to be sent again by another call
+// with the same key or by Reconcile.
+func (l *Ledger) Adjust(ctx context.Context, key string, inventoryItemID, locationID, delta int64) (*Adjustment, error) {
+	if l.API == nil || l.Journal == nil {
+		return nil, errors.New("shopifyinventory: Ledger needs an API and a Journal")
+	}
+	if err := l.lock(key); err != nil {
+		return nil, err
+	}
+	defer l.unlock(key)
+
+	adjustment, err := l.Journal.LoadAdjustment(key)
+	switch {
+	case errors.Is(err, ErrNotJournaled):
+	case err != nil:
+		return nil, err
+	case adjustment.InventoryItemID != inventoryItemID || adjustment.LocationID != locationID || adjustment.Delta != delta:
+		return adjustment, ErrKeyReused
+	case adjustment.State == AdjustmentApplied:
+		return adjustment, nil
+	case adjustment.State == AdjustmentUnverified:
+		return adjustment, ErrAdjustmentUnverified
+	case adjustment.State == AdjustmentPending:
+		return l.resume(ctx, adjustment)
+	}
+
+	// a new adjustment, or one which was rejected and is tried again
+	before, _, err := l.available(inventoryItemID, locationID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	adjustment = &Adjustment{
+		Key:             key,
+		InventoryItemID: inventoryItemID,
+		LocationID:      locationID,
+		Delta:           delta,
+		Before:          before,
+		State:           AdjustmentPending,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err = l.Journal.SaveAdjustment(adjustment); err != nil {
+		return nil, err
+	}
+	return l.send(ctx, adjustment)
+}
+
+// Reconcile settles the pending adjustments of the journal, e.g. after a
+// crash: those found applied are recorded so, and the others are sent
+// again. It returns the adjustments it settled, and the first error met.
+func (l *Ledger) Reconcile(ctx context.Context) ([]*Adjustment, error) {
+	if l.API == nil || l.Journal == nil {
+		return nil, errors.New("shopifyinventory: Ledger needs an API and a Journal")
+	}
+	pending, err := l.Journal.PendingAdjustments()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*Adjustment{}
+	var first error
+	for _, adjustment := range pending {
+		if err = ctx.Err(); err != nil {
+			return result, err
+		}
+		if err = l.lock(adjustment.Key); err != nil {
+			// being sent by Adjust
+			continue
+		}
+		adjustment, err = l.resume(ctx, adjustment)
+		l.unlock(adjustment.Key)
+		if err != nil && first == nil {
+			first = fmt.Errorf("%s: %w", adjustment.Key, err)
+		}
+		result = append(result, adjustment)
+	}
+	return result, first
+}
+
+// resume settles a pending adjustment, sending it again if it wasn't
+// applied.
+func (l *Ledger) resume(ctx context.Context, adjustment *Adjustment) (*Adjustment, error) {
+	if err := l.verify(adjustment); err != nil {
+		return adjustment, err
+	}
+	switch adjustment.State {
+	case AdjustmentApplied:
+		return adjustment, nil
+	case AdjustmentUnverified:
+		return adjustment, ErrAdjustmentUnverified
+	}
+	return l.send(ctx, adjustment)
+}
+
+// send sends a pending adjustment until it's applied or rejected, verifying
+// the level before sending it again.
+func (l *Ledger) send(ctx context.Context, adjustment *Adjustment) (*Adjustment, error) {
+	maxAttempts := l.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return adjustment, err
+		}
+
+		level := l.API.NewInventoryLevel()
+		level.InventoryItemID = adjustment.InventoryItemID
+		level.LocationID = adjustment.LocationID
+		level.AvailableAdjustment = adjustment.Delta
+		err := level.Adjust()
+		adjustment.Attempts++
+		if err == nil {
+			adjustment.State = AdjustmentApplied
+			adjustment.Available = level.Available
+			adjustment.Error = ""
+			return l.finish(adjustment, nil)
+		}
+		adjustment.Error = err.Error()
+
+		if rejected(err) {
+			adjustment.State = AdjustmentFailed
+			return l.finish(adjustment, err)
+		}
+
+		// the adjustment may have been applied before the error
+		if verr := l.verify(adjustment); verr != nil {
+			return adjustment, fmt.Errorf("%w, and verifying it: %v", err, verr)
+		}
+		switch adjustment.State {
+		case AdjustmentApplied:
+			adjustment.Error = ""
+			return l.finish(adjustment, nil)
+		case AdjustmentUnverified:
+			return l.finish(adjustment, ErrAdjustmentUnverified)
+		}
+		if attempt >= maxAttempts {
+			return l.finish(adjustment, err)
+		}
+	}
+}
+
+// verify reads the level of a pending adjustment, and moves it to the
+// applied state if it's at the expected quantity, or to the unverified one
+// if it's at neither the expected nor the previous quantity. The adjustment
+// is saved unless it's still pending.
+func (l *Ledger) verify(adjustment *Adjustment) error {
+	available, stocked, err := l.available(adjustment.InventoryItemID, adjustment.LocationID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case stocked && available == adjustment.Expected():
+		adjustment.State = AdjustmentApplied
+		adjustment.Available = available
+	case available == adjustment.Before:
+		return nil
+	default:
+		adjustment.State = AdjustmentUnverified
+		adjustment.Available = available
+	}
+	return l.save(adjustment)
+}
+
+// available returns the available quantity of an inventory item at a
+// location, and false if it isn't stocked there.
+func (l *Ledger) available(inventoryItemID, locationID int64) (int64, bool, error) {
+	levels, err := l.API.InventoryLevels(&shopify.InventoryLevelsOptions{
+		InventoryItemIDs: []int64{inventoryItemID},
+		LocationIDs:      []int64{locationID},
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	for _, level := range levels {
+		if level.InventoryItemID == inventoryItemID && level.LocationID == locationID {
+			return level.Available, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// finish saves adjustment and returns it with err, or with the error saving
+// it.
+func (l *Ledger) finish(adjustment *Adjustment, err error) (*Adjustment, error) {
+	if serr := l.save(adjustment); serr != nil {
+		return adjustment, serr
+	}
+	return adjustment, err
+}
+
+func (l *Ledger) save(adjustment *Adjustment) error {
+	adjustment.UpdatedAt = time.Now()
+	return l.Journal.SaveAdjustment(adjustment)
+}
+
+func (l *Ledger) lock(key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight == nil {
+		l.inFlight = map[string]bool{}
+	}
+	if l.inFlight[key] {
+		return ErrAdjustmentInProgress
+	}
+	l.inFlight[key] = true
+	return nil
+}
+
+func (l *Ledger) unlock(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.inFlight, key)
+}
+
+// rejected returns true if err is a response of Shopify refusing a request,
+// which therefore wasn't applied. Server errors and failed requests may have
+// been applied.
+func rejected(err error) bool {
+	var errorResponse *shopify.ErrorResponse
+	return errors.As(err, &errorResponse) && errorResponse.StatusCode >= 400 && errorResponse.StatusCode < 500
+}
@@ -0,0 +1,216 @@
+package shopifyinventory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boourns/go_shopify"
+	"github.com/boourns/go_shopify/shopifytest"
+)
+
+// ledgerServer returns a fake server with an item stocked at 10 at the
+// default location, and a ledger adjusting it.
+func ledgerServer(t *testing.T) (*shopifytest.Server, *Ledger, int64) {
+	srv := shopifytest.NewServer()
+	t.Cleanup(srv.Close)
+	api := srv.API()
+	itemID := seed(srv, "TS-S")["TS-S"]
+	setLevel(t, api, itemID, shopifytest.DefaultLocationID, 10)
+	return srv, NewLedger(api, NewMemoryJournal()), itemID
+}
+
+// failAdjustments makes the next adjustments sent by ledger fail with
+// statuses, after being applied if applied is true.
+func failAdjustments(srv *shopifytest.Server, ledger *Ledger, applied bool, statuses ...int) {
+	ledger.API.Use(shopify.Hook{BeforeRequest: func(event *shopify.RequestEvent) error {
+		if event.Method != "POST" || !strings.Contains(event.Endpoint, "adjust") || len(statuses) == 0 {
+			return nil
+		}
+		if applied {
+			srv.FailNextAfterApplying(statuses[0])
+		} else {
+			srv.FailNext(statuses[0])
+		}
+		statuses = statuses[1:]
+		return nil
+	}})
+}
+
+func posts(srv *shopifytest.Server) int {
+	n := 0
+	for _, req := range srv.Requests() {
+		if req.Method == "POST" && req.Path == "/admin/inventory_levels/adjust.json" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLedgerAdjust(t *testing.T) {
+	srv, ledger, itemID := ledgerServer(t)
+	ctx := context.Background()
+
+	adjustment, err := ledger.Adjust(ctx, "order-1", itemID, shopifytest.DefaultLocationID, -3)
+	if err != nil {
+		t.Fatalf("Error adjusting: %v", err)
+	}
+	if adjustment.State != AdjustmentApplied || adjustment.Before != 10 || adjustment.Available != 7 || adjustment.Attempts != 1 {
+		t.Errorf("Unexpected adjustment %#v", adjustment)
+	}
+
+	// the same key is only applied once
+	if adjustment, err = ledger.Adjust(ctx, "order-1", itemID, shopifytest.DefaultLocationID, -3); err != nil || adjustment.State != AdjustmentApplied {
+		t.Errorf("Expected the applied adjustment, got %#v (%v)", adjustment, err)
+	}
+	if available, _ := srv.InventoryLevel(itemID, shopifytest.DefaultLocationID); available != 7 || posts(srv) != 1 {
+		t.Errorf("Expected one adjustment to 7, got %d after %d", available, posts(srv))
+	}
+
+	if _, err = ledger.Adjust(ctx, "order-1", itemID, shopifytest.DefaultLocationID, -4); !errors.Is(err, ErrKeyReused) {
+		t.Errorf("Expected ErrKeyReused, got %v", err)
+	}
+}
+
+func TestLedgerAppliedBeforeFailure(t *testing.T) {
+	srv, ledger, itemID := ledgerServer(t)
+
+	// the adjustment is applied but its response is lost
+	failAdjustments(srv, ledger, true, 504)
+	adjustment, err := ledger.Adjust(context.Background(), "order-1", itemID, shopifytest.DefaultLocationID, -3)
+	if err != nil {
+		t.Fatalf("Error adjusting: %v", err)
+	}
+	if adjustment.State != AdjustmentApplied || adjustment.Available != 7 || adjustment.Attempts != 1 {
+		t.Errorf("Expected the adjustment to be verified as applied, got %#v", adjustment)
+	}
+	if available, _ := srv.InventoryLevel(itemID, shopifytest.DefaultLocationID); available != 7 || posts(srv) != 1 {
+		t.Errorf("Expected one adjustment to 7, got %d after %d", available, posts(srv))
+	}
+}
+
+func TestLedgerRetriesFailure(t *testing.T) {
+	srv, ledger, itemID := ledgerServer(t)
+
+	// the adjustment fails without being applied
+	failAdjustments(srv, ledger, false, 503)
+	adjustment, err := ledger.Adjust(context.Background(), "order-1", itemID, shopifytest.DefaultLocationID, -3)
+	if err != nil {
+		t.Fatalf("Error adjusting: %v", err)
+	}
+	if adjustment.State != AdjustmentApplied || adjustment.Available != 7 || adjustment.Attempts != 2 {
+		t.Errorf("Expected the adjustment to be applied on the second attempt, got %#v", adjustment)
+	}
+	if available, _ := srv.InventoryLevel(itemID, shopifytest.DefaultLocationID); available != 7 {
+		t.Errorf("Expected 7, got %d", available)
+	}
+}
+
+func TestLedgerGivesUp(t *testing.T) {
+	srv, ledger, itemID := ledgerServer(t)
+	ledger.MaxAttempts = 2
+
+	failAdjustments(srv, ledger, false, 503, 503)
+	adjustment, err := ledger.Adjust(context.Background(), "order-1", itemID, shopifytest.DefaultLocationID, -3)
+	if err == nil || adjustment.State != AdjustmentPending || adjustment.Attempts != 2 || adjustment.Error == "" {
+		t.Fatalf("Expected the adjustment to stay pending, got %#v (%v)", adjustment, err)
+	}
+
+	adjustments, err := ledger.Reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("Error reconciling: %v", err)
+	}
+	if len(adjustments) != 1 || adjustments[0].State != AdjustmentApplied || adjustments[0].Available != 7 {
+		t.Errorf("Expected the adjustment to be applied, got %#v", adjustments)
+	}
+}
+
+func TestLedgerRejected(t *testing.T) {
+	srv, ledger, _ := ledgerServer(t)
+
+	// unknown inventory items are rejected with a 422
+	adjustment, err := ledger.Adjust(context.Background(), "order-1", 999, shopifytest.DefaultLocationID, -3)
+	if err == nil || adjustment.State != AdjustmentFailed || adjustment.Attempts != 1 {
+		t.Errorf("Expected the adjustment to fail, got %#v (%v)", adjustment, err)
+	}
+	if posts(srv) != 1 {
+		t.Errorf("Expected a rejected adjustment not to be retried, got %d attempts", posts(srv))
+	}
+}
+
+func TestLedgerReconcile(t *testing.T) {
+	srv, ledger, itemID := ledgerServer(t)
+	warehouse := srv.Seed("locations", shopifytest.Object{"name": "Second Warehouse"})
+	setLevel(t, ledger.API, itemID, warehouse, 20)
+	other := seed(srv, "TS-M")["TS-M"]
+	setLevel(t, ledger.API, other, shopifytest.DefaultLocationID, 4)
+
+	// journaled by a process which crashed: the first adjustment was
+	// applied, the second wasn't sent, and the level of the third changed
+	// meanwhile
+	now := time.Now()
+	for _, a := range []*Adjustment{
+		{Key: "applied", InventoryItemID: itemID, LocationID: shopifytest.DefaultLocationID, Delta: 5, Before: 5, CreatedAt: now},
+		{Key: "unsent", InventoryItemID: itemID, LocationID: warehouse, Delta: -2, Before: 20, CreatedAt: now.Add(time.Second)},
+		{Key: "changed", InventoryItemID: other, LocationID: shopifytest.DefaultLocationID, Delta: 1, Before: 1, CreatedAt: now.Add(2 * time.Second)},
+	} {
+		a.State = AdjustmentPending
+		ledger.Journal.SaveAdjustment(a)
+	}
+
+	adjustments, err := ledger.Reconcile(context.Background())
+	if !errors.Is(err, ErrAdjustmentUnverified) {
+		t.Errorf("Expected an unverified adjustment, got %v", err)
+	}
+	if len(adjustments) != 3 {
+		t.Fatalf("Expected 3 adjustments, got %d", len(adjustments))
+	}
+	for i, expected := range []AdjustmentState{AdjustmentApplied, AdjustmentApplied, AdjustmentUnverified} {
+		if adjustments[i].State != expected {
+			t.Errorf("Expected %s to be %s, got %s", adjustments[i].Key, expected, adjustments[i].State)
+		}
+	}
+
+	if available, _ := srv.InventoryLevel(itemID, shopifytest.DefaultLocationID); available != 10 {
+		t.Errorf("Expected the applied adjustment not to be sent again, got %d", available)
+	}
+	if available, _ := srv.InventoryLevel(itemID, warehouse); available != 18 {
+		t.Errorf("Expected the unsent adjustment to be sent, got %d", available)
+	}
+	if pending, _ := ledger.Journal.PendingAdjustments(); len(pending) != 0 {
+		t.Errorf("Expected no pending adjustments, got %d", len(pending))
+	}
+	if posts(srv) != 1 {
+		t.Errorf("Expected 1 adjustment sent, got %d", posts(srv))
+	}
+}
+
+func TestFileJournal(t *testing.T) {
+	journal, err := NewFileJournal(t.TempDir())
+	if err != nil {
+		t.Fatalf("Error creating journal: %v", err)
+	}
+
+	if _, err = journal.LoadAdjustment("order/1"); !errors.Is(err, ErrNotJournaled) {
+		t.Errorf("Expected ErrNotJournaled, got %v", err)
+	}
+
+	now := time.Now()
+	for i, state := range []AdjustmentState{AdjustmentPending, AdjustmentApplied, AdjustmentPending} {
+		adjustment := &Adjustment{Key: []string{"order/1", "order/2", "order/3"}[i], Delta: int64(i), State: state, CreatedAt: now.Add(time.Duration(i) * time.Second)}
+		if err = journal.SaveAdjustment(adjustment); err != nil {
+			t.Fatalf("Error saving adjustment: %v", err)
+		}
+	}
+
+	adjustment, err := journal.LoadAdjustment("order/2")
+	if err != nil || adjustment.Delta != 1 || adjustment.State != AdjustmentApplied {
+		t.Errorf("Unexpected adjustment %#v (%v)", adjustment, err)
+	}
+	pending, err := journal.PendingAdjustments()
+	if err != nil || len(pending) != 2 || pending[0].Key != "order/1" || pending[1].Key != "order/3" {
+		t.Errorf("Expected 2 pending adjustments in order, got %#v (%v)", pending, err)
+	}
+}
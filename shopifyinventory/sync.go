@@ -0,0 +1,318 @@
+// Package shopifyinventory pushes stock levels from a local source, such as
+// the stock file of an ERP, to Shopify. Levels are keyed by SKU and location,
+// compared with the levels in Shopify, and only the ones which changed are
+// set:
+//
+//	desired, err := shopifyinventory.ReadCSV(file)
+//	report, err := shopifyinventory.NewSyncer(api).Sync(ctx, desired)
+//
+// Adjustments relative to the current level are made through a Ledger,
+// which makes them idempotent.
+package shopifyinventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/boourns/go_shopify"
+)
+
+// DefaultConcurrency is the number of levels a Syncer sets at once by
+// default.
+const DefaultConcurrency = 4
+
+// Shopify's limits on the inventory items of a level listing and on the
+// page size.
+const (
+	maxItemIDs = 50
+	pageLimit  = 250
+)
+
+var (
+	// ErrUnknownSKU is the error of the levels whose SKU matches no
+	// variant.
+	ErrUnknownSKU = errors.New("shopifyinventory: unknown SKU")
+	// ErrDuplicateSKU is the error of the levels whose SKU matches several
+	// variants with different inventory items.
+	ErrDuplicateSKU = errors.New("shopifyinventory: SKU matches several inventory items")
+)
+
+// Key identifies a stock level by the SKU of a variant and a location.
+type Key struct {
+	SKU        string
+	LocationID int64
+}
+
+// Result is the outcome of syncing one level.
+type Result struct {
+	Key
+	InventoryItemID int64
+	// Previous is the level in Shopify before the sync. Stocked is false if
+	// the item wasn't stocked at the location, in which case setting the
+	// level connects it.
+	Previous int64
+	Stocked  bool
+	Desired  int64
+	Err      error
+}
+
+// Report lists the levels set, the ones already at the desired quantity and
+// the ones which couldn't be set, ordered by SKU and location. In a dry run,
+// Applied lists the levels which would have been set.
+type Report struct {
+	DryRun  bool
+	Applied []Result
+	Skipped []Result
+	Failed  []Result
+}
+
+// Syncer sets the inventory levels of a shop to desired quantities.
+type Syncer struct {
+	API *shopify.API
+	// Concurrency is the number of levels set at once, DefaultConcurrency if
+	// zero. As many calls are left free in the call-limit bucket: the syncer
+	// waits for the bucket to leak rather than fill it.
+	Concurrency int
+	// DryRun makes Sync report the changes without making them.
+	DryRun bool
+	// Resolve returns the inventory items of the variants with skus. By
+	// default every product is listed to find them; set it to look them up
+	// in a local catalogue instead.
+	Resolve func(skus []string) (map[string][]int64, error)
+}
+
+// NewSyncer returns a Syncer setting levels with api.
+func NewSyncer(api *shopify.API) *Syncer {
+	return &Syncer{API: api}
+}
+
+// Sync sets the levels which differ from desired. The error is only set if
+// the SKUs or current levels can't be fetched, or ctx is done before every
+// level is set; the levels which fail individually are in the report.
+func (s *Syncer) Sync(ctx context.Context, desired map[Key]int64) (*Report, error) {
+	if s.API == nil {
+		return nil, errors.New("shopifyinventory: Syncer has no API")
+	}
+	report := &Report{DryRun: s.DryRun}
+
+	keys := make([]Key, 0, len(desired))
+	skus := []string{}
+	seen := map[string]bool{}
+	for key := range desired {
+		keys = append(keys, key)
+		if !seen[key.SKU] {
+			seen[key.SKU] = true
+			skus = append(skus, key.SKU)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+	sort.Strings(skus)
+
+	resolve := s.Resolve
+	if resolve == nil {
+		resolve = s.resolve
+	}
+	items, err := resolve(skus)
+	if err != nil {
+		return nil, fmt.Errorf("shopifyinventory: resolving SKUs: %w", err)
+	}
+
+	results := []*Result{}
+	itemIDs := []int64{}
+	locationIDs := []int64{}
+	for _, key := range keys {
+		result := &Result{Key: key, Desired: desired[key]}
+		ids := distinct(items[key.SKU])
+		switch {
+		case len(ids) == 0:
+			result.Err = ErrUnknownSKU
+		case len(ids) > 1:
+			result.Err = ErrDuplicateSKU
+		default:
+			result.InventoryItemID = ids[0]
+			itemIDs = append(itemIDs, ids[0])
+			locationIDs = append(locationIDs, key.LocationID)
+		}
+		results = append(results, result)
+	}
+
+	current, err := s.levels(distinct(itemIDs), distinct(locationIDs))
+	if err != nil {
+		return nil, fmt.Errorf("shopifyinventory: listing inventory levels: %w", err)
+	}
+
+	changes := []*Result{}
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		level, ok := current[levelKey{result.InventoryItemID, result.LocationID}]
+		result.Previous, result.Stocked = level, ok
+		if ok && level == result.Desired {
+			continue
+		}
+		changes = append(changes, result)
+	}
+
+	if !s.DryRun {
+		err = s.apply(ctx, changes)
+	}
+
+	applied := map[*Result]bool{}
+	for _, result := range changes {
+		applied[result] = true
+	}
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			report.Failed = append(report.Failed, *result)
+		case applied[result]:
+			report.Applied = append(report.Applied, *result)
+		default:
+			report.Skipped = append(report.Skipped, *result)
+		}
+	}
+	return report, err
+}
+
+// apply sets the levels of changes with a pool of workers, recording the
+// error of each in its result.
+func (s *Syncer) apply(ctx context.Context, changes []*Result) error {
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	work := make(chan *Result)
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for result := range work {
+				if result.Err = s.wait(ctx, concurrency); result.Err != nil {
+					continue
+				}
+				level := s.API.NewInventoryLevel()
+				level.InventoryItemID = result.InventoryItemID
+				level.LocationID = result.LocationID
+				level.Available = result.Desired
+				result.Err = level.Set()
+			}
+		}()
+	}
+
+	for i, result := range changes {
+		if ctx.Err() != nil {
+			for _, skipped := range changes[i:] {
+				skipped.Err = ctx.Err()
+			}
+			break
+		}
+		work <- result
+	}
+	close(work)
+	wg.Wait()
+	return ctx.Err()
+}
+
+// wait waits until the call-limit bucket reported by the last response has
+// room for headroom more calls.
+func (s *Syncer) wait(ctx context.Context, headroom int) error {
+	made, limit := s.API.CallLimit()
+	over := made + headroom - limit
+	if over <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(time.Duration(float64(over) / shopify.DefaultLeakRate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type levelKey struct {
+	inventoryItemID int64
+	locationID      int64
+}
+
+// levels returns the available quantities of the inventory items at the
+// locations.
+func (s *Syncer) levels(itemIDs, locationIDs []int64) (map[levelKey]int64, error) {
+	result := map[levelKey]int64{}
+	for start := 0; start < len(itemIDs); start += maxItemIDs {
+		end := start + maxItemIDs
+		if end > len(itemIDs) {
+			end = len(itemIDs)
+		}
+		levels, err := s.API.InventoryLevels(&shopify.InventoryLevelsOptions{
+			InventoryItemIDs: itemIDs[start:end],
+			LocationIDs:      locationIDs,
+			Limit:            pageLimit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, level := range levels {
+			result[levelKey{level.InventoryItemID, level.LocationID}] = level.Available
+		}
+	}
+	return result, nil
+}
+
+// resolve finds the inventory items of skus in the variants of every
+// product.
+func (s *Syncer) resolve(skus []string) (map[string][]int64, error) {
+	wanted := map[string]bool{}
+	for _, sku := range skus {
+		wanted[sku] = true
+	}
+
+	result := map[string][]int64{}
+	options := &shopify.ProductsOptions{Limit: pageLimit, Fields: "id,variants"}
+	for {
+		products, err := s.API.Products(options)
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range products {
+			for _, variant := range product.Variants {
+				if variant.SKU != nil && wanted[*variant.SKU] {
+					result[*variant.SKU] = append(result[*variant.SKU], variant.InventoryItemID)
+				}
+			}
+			options.SinceID = product.ID
+		}
+		if len(products) < pageLimit {
+			return result, nil
+		}
+	}
+}
+
+func (k Key) less(other Key) bool {
+	if k.SKU != other.SKU {
+		return k.SKU < other.SKU
+	}
+	return k.LocationID < other.LocationID
+}
+
+// distinct returns ids without duplicates, in their first order.
+func distinct(ids []int64) []int64 {
+	seen := map[int64]bool{}
+	result := []int64{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,177 @@
+package shopifyinventory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/boourns/go_shopify"
+	"github.com/boourns/go_shopify/shopifytest"
+)
+
+// seed stores a product with a variant of each of skus, and returns the
+// inventory item of every SKU.
+func seed(srv *shopifytest.Server, skus ...string) map[string]int64 {
+	variants := []interface{}{}
+	for _, sku := range skus {
+		variants = append(variants, map[string]interface{}{"sku": sku})
+	}
+	srv.Seed("products", shopifytest.Object{"title": "T-shirt", "variants": variants})
+
+	items := map[string]int64{}
+	for _, variant := range srv.Objects("variants") {
+		items[variant["sku"].(string)] = variant["inventory_item_id"].(int64)
+	}
+	return items
+}
+
+func setLevel(t *testing.T, api *shopify.API, itemID, locationID, available int64) {
+	level := api.NewInventoryLevel()
+	level.InventoryItemID = itemID
+	level.LocationID = locationID
+	level.Available = available
+	if err := level.Set(); err != nil {
+		t.Fatalf("Error setting level of item %d: %v", itemID, err)
+	}
+}
+
+func keys(results []Result) []Key {
+	result := []Key{}
+	for _, r := range results {
+		result = append(result, r.Key)
+	}
+	return result
+}
+
+func TestSync(t *testing.T) {
+	srv := shopifytest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	warehouse := srv.Seed("locations", shopifytest.Object{"name": "Second Warehouse"})
+	items := seed(srv, "TS-S", "TS-M", "TS-L")
+	srv.Seed("products", shopifytest.Object{"title": "Mug", "variants": []interface{}{
+		map[string]interface{}{"sku": "MUG"},
+		map[string]interface{}{"sku": "MUG"},
+	}})
+	setLevel(t, api, items["TS-S"], shopifytest.DefaultLocationID, 5)
+	setLevel(t, api, items["TS-M"], shopifytest.DefaultLocationID, 3)
+
+	syncer := NewSyncer(api)
+	syncer.Concurrency = 2
+	report, err := syncer.Sync(context.Background(), map[Key]int64{
+		{"TS-S", shopifytest.DefaultLocationID}:  5,
+		{"TS-M", shopifytest.DefaultLocationID}:  7,
+		{"TS-L", warehouse}:                      2,
+		{"TS-L", 999}:                            1,
+		{"TS-XL", shopifytest.DefaultLocationID}: 1,
+		{"MUG", shopifytest.DefaultLocationID}:   1,
+	})
+	if err != nil {
+		t.Fatalf("Error syncing: %v", err)
+	}
+
+	if len(report.Skipped) != 1 || report.Skipped[0].Key != (Key{"TS-S", shopifytest.DefaultLocationID}) || report.Skipped[0].Previous != 5 {
+		t.Errorf("Expected TS-S to be skipped, got %#v", report.Skipped)
+	}
+	if len(report.Applied) != 2 {
+		t.Fatalf("Expected 2 levels applied, got %v", keys(report.Applied))
+	}
+	if m := report.Applied[0]; m.Key != (Key{"TS-L", warehouse}) || m.Stocked || m.Desired != 2 {
+		t.Errorf("Expected TS-L to be stocked at the warehouse, got %#v", m)
+	}
+	if m := report.Applied[1]; m.Key != (Key{"TS-M", shopifytest.DefaultLocationID}) || !m.Stocked || m.Previous != 3 || m.Desired != 7 {
+		t.Errorf("Expected TS-M to go from 3 to 7, got %#v", m)
+	}
+
+	failed := map[string]error{}
+	for _, r := range report.Failed {
+		failed[r.SKU] = r.Err
+	}
+	if len(failed) != 3 || !errors.Is(failed["TS-XL"], ErrUnknownSKU) || !errors.Is(failed["MUG"], ErrDuplicateSKU) {
+		t.Errorf("Expected TS-XL, MUG and TS-L to fail, got %#v", report.Failed)
+	}
+	var errorResponse *shopify.ErrorResponse
+	if !errors.As(failed["TS-L"], &errorResponse) || errorResponse.StatusCode != 422 {
+		t.Errorf("Expected TS-L to fail at an unknown location, got %v", failed["TS-L"])
+	}
+
+	for sku, expected := range map[string]int64{"TS-S": 5, "TS-M": 7} {
+		if available, _ := srv.InventoryLevel(items[sku], shopifytest.DefaultLocationID); available != expected {
+			t.Errorf("Expected %s at %d, got %d", sku, expected, available)
+		}
+	}
+	if available, ok := srv.InventoryLevel(items["TS-L"], warehouse); !ok || available != 2 {
+		t.Errorf("Expected TS-L at 2 in the warehouse, got %d", available)
+	}
+}
+
+func TestSyncDryRun(t *testing.T) {
+	srv := shopifytest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	items := seed(srv, "TS-S")
+	setLevel(t, api, items["TS-S"], shopifytest.DefaultLocationID, 5)
+	before := len(srv.Requests())
+
+	syncer := NewSyncer(api)
+	syncer.DryRun = true
+	report, err := syncer.Sync(context.Background(), map[Key]int64{{"TS-S", shopifytest.DefaultLocationID}: 8})
+	if err != nil {
+		t.Fatalf("Error syncing: %v", err)
+	}
+	if !report.DryRun || len(report.Applied) != 1 || report.Applied[0].Previous != 5 || report.Applied[0].Desired != 8 {
+		t.Errorf("Expected the change to be reported, got %#v", report)
+	}
+
+	for _, req := range srv.Requests()[before:] {
+		if req.Method != "GET" {
+			t.Errorf("Expected no changes in a dry run, got %s %s", req.Method, req.Path)
+		}
+	}
+	if available, _ := srv.InventoryLevel(items["TS-S"], shopifytest.DefaultLocationID); available != 5 {
+		t.Errorf("Expected the level to stay at 5, got %d", available)
+	}
+}
+
+func TestSyncCanceled(t *testing.T) {
+	srv := shopifytest.NewServer()
+	defer srv.Close()
+
+	syncer := NewSyncer(srv.API())
+	syncer.Resolve = func(skus []string) (map[string][]int64, error) {
+		return map[string][]int64{"TS-S": {seed(srv, "TS-S")["TS-S"]}}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report, err := syncer.Sync(ctx, map[Key]int64{{"TS-S", shopifytest.DefaultLocationID}: 8})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the sync to be canceled, got %v", err)
+	}
+	if len(report.Failed) != 1 || !errors.Is(report.Failed[0].Err, context.Canceled) {
+		t.Errorf("Expected the level to fail, got %#v", report)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	desired, err := ReadCSV(strings.NewReader("available,sku,name,location_id\n5,TS-S,Small,1\n0, TS-M ,Medium,2\n"))
+	if err != nil {
+		t.Fatalf("Error reading CSV: %v", err)
+	}
+	if len(desired) != 2 || desired[Key{"TS-S", 1}] != 5 || desired[Key{"TS-M", 2}] != 0 {
+		t.Errorf("Unexpected levels %#v", desired)
+	}
+
+	for name, input := range map[string]string{
+		"missing column": "sku,available\nTS-S,5\n",
+		"bad quantity":   "sku,location_id,available\nTS-S,1,five\n",
+		"duplicate":      "sku,location_id,available\nTS-S,1,5\nTS-S,1,6\n",
+	} {
+		if _, err := ReadCSV(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package shopifymetafields
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/boourns/go_shopify"
+)
+
+// ownerTypes are the GraphQL types of the owners of metafields, by
+// owner_resource.
+var ownerTypes = map[string]string{
+	"product":    "Product",
+	"variant":    "ProductVariant",
+	"customer":   "Customer",
+	"order":      "Order",
+	"collection": "Collection",
+	"page":       "Page",
+	"blog":       "Blog",
+	"article":    "Article",
+	"location":   "Location",
+}
+
+const (
+	// maxQueryCost is the maximum requested cost of a query.
+	maxQueryCost = 1000
+	// readPageSize is the number of metafields of each owner read with
+	// readQuery; the others are read with readMoreQuery.
+	readPageSize = 50
+	// readBatchSize is the number of owners read with readQuery. Each costs
+	// about readPageSize+2, and the query must stay under maxQueryCost.
+	readBatchSize = maxQueryCost/(readPageSize+2) - 1
+)
+
+var readQuery = `query($ids: [ID!]!, $namespace: String) {
+  nodes(ids: $ids) {
+    id
+    ... on HasMetafields {
+      metafields(first: ` + strconv.Itoa(readPageSize) + `, namespace: $namespace) {
+        nodes { namespace key type value compareDigest }
+        pageInfo { hasNextPage endCursor }
+      }
+    }
+  }
+}`
+
+const readMoreQuery = `query($id: ID!, $namespace: String, $after: String) {
+  node(id: $id) {
+    id
+    ... on HasMetafields {
+      metafields(first: 250, namespace: $namespace, after: $after) {
+        nodes { namespace key type value compareDigest }
+        pageInfo { hasNextPage endCursor }
+      }
+    }
+  }
+}`
+
+const setMutation = `mutation($metafields: [MetafieldsSetInput!]!) {
+  metafieldsSet(metafields: $metafields) {
+    metafields { id }
+    userErrors { field message code }
+  }
+}`
+
+const deleteMutation = `mutation($metafields: [MetafieldIdentifierInput!]!) {
+  metafieldsDelete(metafields: $metafields) {
+    deletedMetafields { key }
+    userErrors { field message code }
+  }
+}`
+
+// ownerMetafields is an owner with a page of its metafields.
+type ownerMetafields struct {
+	ID         string `json:"id"`
+	Metafields *struct {
+		Nodes []struct {
+			Namespace     string `json:"namespace"`
+			Key           string `json:"key"`
+			Type          string `json:"type"`
+			Value         string `json:"value"`
+			CompareDigest string `json:"compareDigest"`
+		} `json:"nodes"`
+		PageInfo struct {
+			HasNextPage bool   `json:"hasNextPage"`
+			EndCursor   string `json:"endCursor"`
+		} `json:"pageInfo"`
+	} `json:"metafields"`
+}
+
+// graphQLBackend sets metafields with metafieldsSet mutations.
+type graphQLBackend struct {
+	w *Writer
+	// shopID is the GID of the shop, which owns shop metafields.
+	shopID string
+}
+
+func (b *graphQLBackend) batchSize() int {
+	if b.w.BatchSize <= 0 || b.w.BatchSize > DefaultBatchSize {
+		return DefaultBatchSize
+	}
+	return b.w.BatchSize
+}
+
+// ownerID returns the GID of owner.
+func (b *graphQLBackend) ownerID(owner shopify.MetafieldOwner) (string, error) {
+	if owner.Resource == "shop" {
+		return b.shopID, nil
+	}
+	typ, ok := ownerTypes[owner.Resource]
+	if !ok {
+		return "", fmt.Errorf("shopifymetafields: metafields can't be owned by %q", owner.Resource)
+	}
+	return fmt.Sprintf("gid://shopify/%s/%d", typ, owner.ID), nil
+}
+
+// do runs a query, sending it again while it's throttled.
+func (b *graphQLBackend) do(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	for attempt := 1; ; attempt++ {
+		err := b.w.API.GraphQL(query, variables, out)
+		if !errors.Is(err, shopify.ErrRateLimited) || attempt == maxAttempts {
+			return err
+		}
+		if err := sleep(ctx, time.Duration(attempt)*time.Second); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *graphQLBackend) read(ctx context.Context, owners []shopify.MetafieldOwner, namespace string) (map[metafieldKey]current, error) {
+	result := map[metafieldKey]current{}
+	byID := map[string]shopify.MetafieldOwner{}
+	ids := []string{}
+	for _, owner := range owners {
+		id, err := b.ownerID(owner)
+		if err != nil {
+			return nil, err
+		}
+		byID[id] = owner
+		ids = append(ids, id)
+	}
+
+	add := func(node *ownerMetafields) {
+		for _, m := range node.Metafields.Nodes {
+			key := metafieldKey{owner: byID[node.ID], namespace: m.Namespace, key: m.Key}
+			result[key] = current{typ: m.Type, value: m.Value, digest: m.CompareDigest}
+		}
+	}
+
+	for start := 0; start < len(ids); start += readBatchSize {
+		end := start + readBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		variables := map[string]interface{}{"ids": ids[start:end]}
+		if namespace != "" {
+			variables["namespace"] = namespace
+		}
+		data := struct {
+			Nodes []*ownerMetafields `json:"nodes"`
+		}{}
+		if err := b.do(ctx, readQuery, variables, &data); err != nil {
+			return nil, err
+		}
+
+		for _, node := range data.Nodes {
+			// owners which don't exist are null
+			if node == nil || node.Metafields == nil {
+				continue
+			}
+			add(node)
+			for node.Metafields.PageInfo.HasNextPage {
+				variables := map[string]interface{}{"id": node.ID, "after": node.Metafields.PageInfo.EndCursor}
+				if namespace != "" {
+					variables["namespace"] = namespace
+				}
+				more := struct {
+					Node *ownerMetafields `json:"node"`
+				}{}
+				if err := b.do(ctx, readMoreQuery, variables, &more); err != nil {
+					return nil, err
+				}
+				if more.Node == nil || more.Node.Metafields == nil {
+					break
+				}
+				node = more.Node
+				add(node)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (b *graphQLBackend) set(ctx context.Context, batch []*Result, current map[metafieldKey]current) {
+	b.mutate(ctx, setMutation, "metafieldsSet", batch, func(result *Result) (map[string]interface{}, error) {
+		ownerID, err := b.ownerID(result.Owner)
+		if err != nil {
+			return nil, err
+		}
+		input := map[string]interface{}{
+			"ownerId":   ownerID,
+			"namespace": result.Namespace,
+			"key":       result.Key,
+			"type":      result.Type,
+			"value":     result.Value,
+		}
+		if result.Expected != nil {
+			// a null digest only sets metafields which don't exist
+			input["compareDigest"] = nil
+			if cur, ok := current[keyOf(result.Write)]; ok {
+				input["compareDigest"] = cur.digest
+			}
+		}
+		return input, nil
+	})
+}
+
+func (b *graphQLBackend) delete(ctx context.Context, batch []*Result, current map[metafieldKey]current) {
+	b.mutate(ctx, deleteMutation, "metafieldsDelete", batch, func(result *Result) (map[string]interface{}, error) {
+		ownerID, err := b.ownerID(result.Owner)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"ownerId": ownerID, "namespace": result.Namespace, "key": result.Key}, nil
+	})
+}
+
+// mutate runs a mutation taking the inputs of a batch of metafields in its
+// $metafields variable. The mutations are atomic, so when some metafields
+// have user errors, the others are sent again without them.
+func (b *graphQLBackend) mutate(ctx context.Context, mutation, name string, batch []*Result, input func(result *Result) (map[string]interface{}, error)) {
+	for len(batch) > 0 {
+		sent := []*Result{}
+		inputs := []map[string]interface{}{}
+		for _, result := range batch {
+			in, err := input(result)
+			if err != nil {
+				result.Err = err
+				continue
+			}
+			sent = append(sent, result)
+			inputs = append(inputs, in)
+		}
+		if len(sent) == 0 {
+			return
+		}
+
+		data := map[string]struct {
+			UserErrors shopify.UserErrors `json:"userErrors"`
+		}{}
+		if err := b.do(ctx, mutation, map[string]interface{}{"metafields": inputs}, &data); err != nil {
+			fail(sent, err)
+			return
+		}
+		userErrors := data[name].UserErrors
+		if len(userErrors) == 0 {
+			return
+		}
+
+		byResult := map[*Result]shopify.UserErrors{}
+		for _, userError := range userErrors {
+			i, ok := inputIndex(userError.Field)
+			if !ok || i >= len(sent) {
+				fail(sent, userErrors)
+				return
+			}
+			byResult[sent[i]] = append(byResult[sent[i]], userError)
+		}
+
+		batch = []*Result{}
+		for _, result := range sent {
+			errs, ok := byResult[result]
+			switch {
+			case !ok:
+				batch = append(batch, result)
+			case errs[0].Code == "STALE_OBJECT":
+				result.Err = ErrConflict
+			default:
+				result.Err = errs
+			}
+		}
+	}
+}
+
+// inputIndex returns the index of the input in the field of a user error,
+// e.g. ["metafields", "3", "value"].
+func inputIndex(field []string) (int, bool) {
+	if len(field) < 2 || field[0] != "metafields" {
+		return 0, false
+	}
+	i, err := strconv.Atoi(field[1])
+	return i, err == nil && i >= 0
+}
@@ -0,0 +1,70 @@
+package shopifymetafields
+
+import (
+	"context"
+
+	"github.com/boourns/go_shopify"
+)
+
+// restBackend writes metafields one REST call at a time, within the call
+// limit.
+type restBackend struct {
+	w *Writer
+}
+
+func (b *restBackend) batchSize() int {
+	return 1
+}
+
+func (b *restBackend) read(ctx context.Context, owners []shopify.MetafieldOwner, namespace string) (map[metafieldKey]current, error) {
+	result := map[metafieldKey]current{}
+	for _, owner := range owners {
+		options := &shopify.MetafieldsOptions{Namespace: namespace, Limit: pageLimit}
+		for {
+			if err := b.w.wait(ctx, 1); err != nil {
+				return nil, err
+			}
+			metafields, err := b.w.API.OwnerMetafields(owner, options)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range metafields {
+				key := metafieldKey{owner: owner, namespace: m.Namespace, key: m.Key}
+				result[key] = current{id: m.Id, typ: m.Type, value: m.Value}
+				options.SinceID = m.Id
+			}
+			if len(metafields) < pageLimit {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// set updates the metafields which were read, and creates the others, which
+// updates them if they exist.
+func (b *restBackend) set(ctx context.Context, batch []*Result, current map[metafieldKey]current) {
+	for _, result := range batch {
+		if result.Err = b.w.wait(ctx, b.w.concurrency()); result.Err != nil {
+			continue
+		}
+		metafield := b.w.API.NewOwnerMetafield(result.Owner)
+		metafield.Id = current[keyOf(result.Write)].id
+		metafield.Namespace = result.Namespace
+		metafield.Key = result.Key
+		metafield.Type = result.Type
+		metafield.Value = result.Value
+		result.Err = metafield.Save()
+	}
+}
+
+func (b *restBackend) delete(ctx context.Context, batch []*Result, current map[metafieldKey]current) {
+	for _, result := range batch {
+		if result.Err = b.w.wait(ctx, b.w.concurrency()); result.Err != nil {
+			continue
+		}
+		metafield := b.w.API.NewMetafield()
+		metafield.Id = current[keyOf(result.Write)].id
+		result.Err = metafield.Delete()
+	}
+}
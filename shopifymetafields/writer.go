@@ -0,0 +1,406 @@
+// Package shopifymetafields writes metafields in bulk. Rather than a REST
+// call per metafield, writes are grouped by owner into metafieldsSet
+// mutations of up to 25 metafields, and can be made conditional on the
+// current values:
+//
+//	report, err := shopifymetafields.NewWriter(api).Write(ctx, writes)
+//
+// SyncNamespace also deletes the metafields of a namespace which aren't
+// written, leaving exactly the desired keys. Shops whose token can't use the
+// GraphQL API are written through the REST API, within its call limit.
+package shopifymetafields
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/boourns/go_shopify"
+)
+
+const (
+	// DefaultBatchSize is the number of metafields set by a mutation by
+	// default, the most metafieldsSet accepts.
+	DefaultBatchSize = 25
+	// DefaultConcurrency is the number of mutations, or of REST calls, a
+	// Writer makes at once by default.
+	DefaultConcurrency = 4
+)
+
+const (
+	pageLimit = 250
+	// maxAttempts is the number of times a throttled query is sent.
+	maxAttempts = 5
+)
+
+var (
+	// ErrConflict is the error of the compare-and-set writes whose
+	// metafield doesn't have the expected value.
+	ErrConflict = errors.New("shopifymetafields: metafield doesn't have the expected value")
+	// ErrDuplicate is the error of the writes of a metafield written earlier
+	// in the same call.
+	ErrDuplicate = errors.New("shopifymetafields: metafield written twice")
+)
+
+// Write is the desired value of the metafield of an owner with a namespace
+// and key.
+type Write struct {
+	Owner     shopify.MetafieldOwner
+	Namespace string
+	Key       string
+	// Type and Value are those of shopify.Metafield: use
+	// shopify.EncodeMetafieldValue to encode Go values.
+	Type  string
+	Value string
+	// Expected makes the write a compare-and-set: the metafield is only
+	// written if its value is *Expected, or, if *Expected is "", if it
+	// doesn't exist. Through GraphQL, Shopify checks it as it writes;
+	// through REST, it's checked just before.
+	Expected *string
+}
+
+// Result is the outcome of a write, or of a deletion by SyncNamespace.
+type Result struct {
+	Write
+	// Delete is true for the deletion of a metafield missing from the
+	// writes of SyncNamespace, whose type and value are in Write.
+	Delete bool
+	Err    error
+}
+
+// Report lists the metafields written, the ones which already had the
+// desired value, the ones deleted and the ones which couldn't be written or
+// deleted. Writes are listed in their order, and deletions by owner and key.
+// In a dry run, Written and Deleted list the changes which would have been
+// made.
+//
+// Unchanged metafields are only detected when the current values are read:
+// for SyncNamespace, and for compare-and-set writes.
+type Report struct {
+	DryRun    bool
+	Written   []Result
+	Unchanged []Result
+	Deleted   []Result
+	Failed    []Result
+}
+
+// Writer writes metafields in bulk.
+type Writer struct {
+	API *shopify.API
+	// BatchSize is the number of metafields set or deleted by a mutation,
+	// DefaultBatchSize if zero.
+	BatchSize int
+	// Concurrency is the number of mutations, or of REST calls, made at
+	// once, DefaultConcurrency if zero. Through REST, as many calls are left
+	// free in the call-limit bucket: the writer waits for the bucket to leak
+	// rather than fill it.
+	Concurrency int
+	// REST makes the writer use the REST API. Otherwise it only falls back
+	// to it if the GraphQL API is denied or missing.
+	REST bool
+	// DryRun makes the writer report the changes without making them.
+	DryRun bool
+}
+
+// NewWriter returns a Writer writing metafields with api.
+func NewWriter(api *shopify.API) *Writer {
+	return &Writer{API: api}
+}
+
+// Write writes the metafields. The error is only set if the API can't be
+// reached, current values can't be read, or ctx is done before every
+// metafield is written; the writes which fail individually are in the
+// report.
+func (w *Writer) Write(ctx context.Context, writes []Write) (*Report, error) {
+	return w.run(ctx, writes, "", nil)
+}
+
+// SyncNamespace writes the metafields, which must all be in namespace, and
+// deletes the other metafields of namespace of their owners and of owners.
+// An owner in owners without writes has its whole namespace deleted.
+func (w *Writer) SyncNamespace(ctx context.Context, namespace string, owners []shopify.MetafieldOwner, writes []Write) (*Report, error) {
+	if namespace == "" {
+		return nil, errors.New("shopifymetafields: SyncNamespace needs a namespace")
+	}
+	if owners == nil {
+		owners = []shopify.MetafieldOwner{}
+	}
+	return w.run(ctx, writes, namespace, owners)
+}
+
+// metafieldKey identifies a metafield.
+type metafieldKey struct {
+	owner     shopify.MetafieldOwner
+	namespace string
+	key       string
+}
+
+// current is a metafield as read before writing.
+type current struct {
+	// id is the numeric ID of the metafield, only read through REST.
+	id     int64
+	typ    string
+	value  string
+	digest string
+}
+
+// backend reads and writes metafields through one of the APIs.
+type backend interface {
+	read(ctx context.Context, owners []shopify.MetafieldOwner, namespace string) (map[metafieldKey]current, error)
+	set(ctx context.Context, batch []*Result, current map[metafieldKey]current)
+	delete(ctx context.Context, batch []*Result, current map[metafieldKey]current)
+	batchSize() int
+}
+
+// run writes the metafields, syncing namespace if owners isn't nil.
+func (w *Writer) run(ctx context.Context, writes []Write, namespace string, owners []shopify.MetafieldOwner) (*Report, error) {
+	if w.API == nil {
+		return nil, errors.New("shopifymetafields: Writer has no API")
+	}
+	syncing := owners != nil
+	report := &Report{DryRun: w.DryRun}
+
+	// owners are ordered by first appearance, to group their writes
+	ownerIndex := map[shopify.MetafieldOwner]int{}
+	addOwner := func(owner shopify.MetafieldOwner) {
+		if _, ok := ownerIndex[owner]; !ok {
+			ownerIndex[owner] = len(ownerIndex)
+		}
+	}
+	for _, owner := range owners {
+		addOwner(normalize(owner))
+	}
+
+	results := []*Result{}
+	desired := map[metafieldKey]bool{}
+	read := map[shopify.MetafieldOwner]bool{}
+	for _, write := range writes {
+		write.Owner = normalize(write.Owner)
+		result := &Result{Write: write}
+		results = append(results, result)
+		key := keyOf(write)
+		switch {
+		case write.Namespace == "" || write.Key == "" || write.Type == "":
+			result.Err = errors.New("shopifymetafields: a write needs a namespace, key and type")
+		case !supported(write.Owner):
+			result.Err = fmt.Errorf("shopifymetafields: metafields can't be owned by %q", write.Owner.Resource)
+		case syncing && write.Namespace != namespace:
+			result.Err = fmt.Errorf("shopifymetafields: %s.%s isn't in namespace %s", write.Namespace, write.Key, namespace)
+		case desired[key]:
+			result.Err = ErrDuplicate
+		default:
+			desired[key] = true
+			addOwner(write.Owner)
+			if syncing || write.Expected != nil {
+				read[write.Owner] = true
+			}
+		}
+	}
+	if syncing {
+		for owner := range ownerIndex {
+			read[owner] = true
+		}
+	}
+
+	b, err := w.backend(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	current := map[metafieldKey]current{}
+	if len(read) > 0 {
+		readOwners := make([]shopify.MetafieldOwner, 0, len(read))
+		for owner := range read {
+			readOwners = append(readOwners, owner)
+		}
+		sort.Slice(readOwners, func(i, j int) bool { return ownerIndex[readOwners[i]] < ownerIndex[readOwners[j]] })
+		if current, err = b.read(ctx, readOwners, namespace); err != nil {
+			return nil, fmt.Errorf("shopifymetafields: reading metafields: %w", err)
+		}
+	}
+
+	sets := []*Result{}
+	unchanged := map[*Result]bool{}
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		cur, exists := current[keyOf(result.Write)]
+		if expected := result.Expected; expected != nil && (exists && cur.value != *expected || !exists && *expected != "") {
+			result.Err = ErrConflict
+			continue
+		}
+		if exists && cur.value == result.Value && cur.typ == result.Type {
+			unchanged[result] = true
+			continue
+		}
+		sets = append(sets, result)
+	}
+
+	deletes := []*Result{}
+	if syncing {
+		for key, cur := range current {
+			if key.namespace == namespace && !desired[key] {
+				deletes = append(deletes, &Result{
+					Write:  Write{Owner: key.owner, Namespace: key.namespace, Key: key.key, Type: cur.typ, Value: cur.value},
+					Delete: true,
+				})
+			}
+		}
+		sort.Slice(deletes, func(i, j int) bool {
+			a, b := deletes[i], deletes[j]
+			if a.Owner != b.Owner {
+				return ownerIndex[a.Owner] < ownerIndex[b.Owner]
+			}
+			return a.Key < b.Key
+		})
+	}
+
+	if !w.DryRun {
+		w.apply(ctx, batches(sets, b.batchSize(), ownerIndex), func(batch []*Result) { b.set(ctx, batch, current) })
+		w.apply(ctx, batches(deletes, b.batchSize(), ownerIndex), func(batch []*Result) { b.delete(ctx, batch, current) })
+	}
+
+	for _, result := range append(results, deletes...) {
+		switch {
+		case result.Err != nil:
+			report.Failed = append(report.Failed, *result)
+		case unchanged[result]:
+			report.Unchanged = append(report.Unchanged, *result)
+		case result.Delete:
+			report.Deleted = append(report.Deleted, *result)
+		default:
+			report.Written = append(report.Written, *result)
+		}
+	}
+	return report, ctx.Err()
+}
+
+// backend returns the GraphQL backend, or the REST one if the writer is set
+// to use it or GraphQL isn't available.
+func (w *Writer) backend(ctx context.Context) (backend, error) {
+	rest := &restBackend{w: w}
+	if w.REST {
+		return rest, nil
+	}
+
+	b := &graphQLBackend{w: w}
+	data := struct {
+		Shop struct {
+			ID string `json:"id"`
+		} `json:"shop"`
+	}{}
+	err := b.do(ctx, `{ shop { id } }`, nil, &data)
+	switch {
+	case errors.Is(err, shopify.ErrNotFound), errors.Is(err, shopify.ErrUnauthorized):
+		return rest, nil
+	case err != nil:
+		return nil, fmt.Errorf("shopifymetafields: %w", err)
+	}
+	b.shopID = data.Shop.ID
+	return b, nil
+}
+
+// apply calls f with each batch with a pool of workers. The batches left
+// when ctx is done fail with its error.
+func (w *Writer) apply(ctx context.Context, batches [][]*Result, f func(batch []*Result)) {
+	work := make(chan []*Result)
+	wg := sync.WaitGroup{}
+	for i := 0; i < w.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range work {
+				f(batch)
+			}
+		}()
+	}
+
+	for i, batch := range batches {
+		if ctx.Err() != nil {
+			for _, skipped := range batches[i:] {
+				fail(skipped, ctx.Err())
+			}
+			break
+		}
+		work <- batch
+	}
+	close(work)
+	wg.Wait()
+}
+
+func (w *Writer) concurrency() int {
+	if w.Concurrency <= 0 {
+		return DefaultConcurrency
+	}
+	return w.Concurrency
+}
+
+// wait waits until the call-limit bucket reported by the last response has
+// room for headroom more calls.
+func (w *Writer) wait(ctx context.Context, headroom int) error {
+	made, limit := w.API.CallLimit()
+	over := made + headroom - limit
+	if over <= 0 {
+		return ctx.Err()
+	}
+	return sleep(ctx, time.Duration(float64(over)/shopify.DefaultLeakRate*float64(time.Second)))
+}
+
+// batches splits results into batches of size, keeping the writes of an
+// owner together as far as they fit.
+func batches(results []*Result, size int, ownerIndex map[shopify.MetafieldOwner]int) [][]*Result {
+	sorted := append([]*Result(nil), results...)
+	sort.SliceStable(sorted, func(i, j int) bool { return ownerIndex[sorted[i].Owner] < ownerIndex[sorted[j].Owner] })
+
+	result := [][]*Result{}
+	for start := 0; start < len(sorted); start += size {
+		end := start + size
+		if end > len(sorted) {
+			end = len(sorted)
+		}
+		result = append(result, sorted[start:end])
+	}
+	return result
+}
+
+func keyOf(write Write) metafieldKey {
+	return metafieldKey{owner: write.Owner, namespace: write.Namespace, key: write.Key}
+}
+
+// normalize returns owner with the shop as "shop", so that owners compare
+// equal.
+func normalize(owner shopify.MetafieldOwner) shopify.MetafieldOwner {
+	if owner.Resource == "" {
+		owner.Resource = "shop"
+	}
+	return owner
+}
+
+func supported(owner shopify.MetafieldOwner) bool {
+	_, ok := ownerTypes[owner.Resource]
+	return ok || owner.Resource == "shop"
+}
+
+// fail sets the error of the results which don't have one yet.
+func fail(results []*Result, err error) {
+	for _, result := range results {
+		if result.Err == nil {
+			result.Err = err
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,331 @@
+package shopifymetafields
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/boourns/go_shopify"
+	"github.com/boourns/go_shopify/shopifytest"
+)
+
+// graphQLServer returns a fake server whose GraphQL requests are answered
+// by respond.
+func graphQLServer(t *testing.T, respond func(req shopifytest.GraphQLRequest) string) *shopifytest.Server {
+	srv := shopifytest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.GraphQL = respond
+	return srv
+}
+
+// inputs returns the $metafields of the requests with mutation.
+func inputs(requests []shopifytest.GraphQLRequest, mutation string) [][]interface{} {
+	result := [][]interface{}{}
+	for _, req := range requests {
+		if strings.Contains(req.Query, mutation+"(") {
+			result = append(result, req.Variables["metafields"].([]interface{}))
+		}
+	}
+	return result
+}
+
+func keys(results []Result) []string {
+	result := []string{}
+	for _, r := range results {
+		result = append(result, fmt.Sprintf("%s/%d/%s", r.Owner.Resource, r.Owner.ID, r.Key))
+	}
+	return result
+}
+
+func product(id int64) shopify.MetafieldOwner {
+	return shopify.MetafieldOwner{Resource: "product", ID: id}
+}
+
+func TestWriteGraphQL(t *testing.T) {
+	srv := graphQLServer(t, func(req shopifytest.GraphQLRequest) string {
+		switch {
+		case strings.Contains(req.Query, "shop {"):
+			return `{"shop":{"id":"gid://shopify/Shop/1"}}`
+		case strings.Contains(req.Query, "metafieldsSet("):
+			for i, input := range req.Variables["metafields"].([]interface{}) {
+				if input.(map[string]interface{})["value"] == "bad" {
+					return fmt.Sprintf(`{"metafieldsSet":{"metafields":null,"userErrors":[{"field":["metafields","%d","value"],"message":"Value is invalid","code":"INVALID_VALUE"}]}}`, i)
+				}
+			}
+			return `{"metafieldsSet":{"metafields":[],"userErrors":[]}}`
+		}
+		t.Errorf("Unexpected query %s", req.Query)
+		return `{}`
+	})
+
+	writes := []Write{}
+	for i := 0; i < 10; i++ {
+		for _, owner := range []int64{1, 2, 3} {
+			value := fmt.Sprint(i)
+			if owner == 1 && i == 3 {
+				value = "bad"
+			}
+			writes = append(writes, Write{Owner: product(owner), Namespace: "specs", Key: fmt.Sprintf("k%d", i), Type: shopify.MetafieldTypeSingleLineText, Value: value})
+		}
+	}
+	writes = append(writes,
+		Write{Owner: product(1), Namespace: "specs", Key: "k0", Type: shopify.MetafieldTypeSingleLineText, Value: "again"},
+		Write{Owner: shopify.ShopMetafieldOwner, Namespace: "specs", Key: "k0", Type: shopify.MetafieldTypeSingleLineText, Value: "shop"},
+	)
+
+	writer := NewWriter(srv.API())
+	writer.Concurrency = 1
+	report, err := writer.Write(context.Background(), writes)
+	if err != nil {
+		t.Fatalf("Error writing: %v", err)
+	}
+	if len(report.Written) != 30 || len(report.Failed) != 2 || len(report.Unchanged) != 0 {
+		t.Fatalf("Expected 30 writes and 2 failures, got %v written, %v failed", keys(report.Written), keys(report.Failed))
+	}
+	var validationErr *shopify.ValidationError
+	if report.Failed[0].Key != "k3" || !errors.As(report.Failed[0].Err, &validationErr) {
+		t.Errorf("Expected the invalid value to fail with its user error, got %#v", report.Failed[0])
+	}
+	if !errors.Is(report.Failed[1].Err, ErrDuplicate) {
+		t.Errorf("Expected the second write of a metafield to fail, got %v", report.Failed[1].Err)
+	}
+
+	// owners are grouped: all of product 1's writes go in the first batch,
+	// which is sent again without the invalid one
+	sets := inputs(srv.GraphQLRequests(), "metafieldsSet")
+	if len(sets) != 3 || len(sets[0]) != 25 || len(sets[1]) != 24 || len(sets[2]) != 6 {
+		t.Fatalf("Expected batches of 25, 24 and 6, got %d batches", len(sets))
+	}
+	for i, input := range sets[1][:9] {
+		if input.(map[string]interface{})["ownerId"] != "gid://shopify/Product/1" {
+			t.Errorf("Expected product 1's writes first, got %v at %d", input, i)
+		}
+	}
+	if last := sets[2][5].(map[string]interface{}); last["ownerId"] != "gid://shopify/Shop/1" {
+		t.Errorf("Expected the shop's metafield to be owned by the shop, got %v", last)
+	}
+	if _, ok := sets[0][0].(map[string]interface{})["compareDigest"]; ok {
+		t.Errorf("Expected no compareDigest for unconditional writes")
+	}
+}
+
+func TestSyncNamespaceGraphQL(t *testing.T) {
+	srv := graphQLServer(t, func(req shopifytest.GraphQLRequest) string {
+		switch {
+		case strings.Contains(req.Query, "shop {"):
+			return `{"shop":{"id":"gid://shopify/Shop/1"}}`
+		case strings.Contains(req.Query, "nodes(ids:"):
+			if req.Variables["namespace"] != "specs" {
+				t.Errorf("Expected the namespace to be read, got %v", req.Variables)
+			}
+			return `{"nodes":[
+				{"id":"gid://shopify/Product/1","metafields":{"nodes":[
+					{"namespace":"specs","key":"a","type":"single_line_text_field","value":"1","compareDigest":"d-a"},
+					{"namespace":"specs","key":"b","type":"single_line_text_field","value":"2","compareDigest":"d-b"}
+				],"pageInfo":{"hasNextPage":true,"endCursor":"c1"}}},
+				{"id":"gid://shopify/Product/2","metafields":{"nodes":[
+					{"namespace":"specs","key":"old","type":"single_line_text_field","value":"x","compareDigest":"d-old"}
+				],"pageInfo":{"hasNextPage":false}}},
+				null]}`
+		case strings.Contains(req.Query, "node(id:"):
+			if req.Variables["after"] != "c1" {
+				t.Errorf("Expected the next page after c1, got %v", req.Variables)
+			}
+			return `{"node":{"id":"gid://shopify/Product/1","metafields":{"nodes":[
+				{"namespace":"specs","key":"stale","type":"single_line_text_field","value":"s","compareDigest":"d-stale"}
+			],"pageInfo":{"hasNextPage":false}}}}`
+		case strings.Contains(req.Query, "metafieldsSet("):
+			for i, input := range req.Variables["metafields"].([]interface{}) {
+				if input.(map[string]interface{})["key"] == "c" {
+					return fmt.Sprintf(`{"metafieldsSet":{"metafields":null,"userErrors":[{"field":["metafields","%d"],"message":"The metafield has been modified","code":"STALE_OBJECT"}]}}`, i)
+				}
+			}
+			return `{"metafieldsSet":{"metafields":[],"userErrors":[]}}`
+		case strings.Contains(req.Query, "metafieldsDelete("):
+			return `{"metafieldsDelete":{"deletedMetafields":[],"userErrors":[]}}`
+		}
+		t.Errorf("Unexpected query %s", req.Query)
+		return `{}`
+	})
+
+	two, none := "2", ""
+	text := shopify.MetafieldTypeSingleLineText
+	writes := []Write{
+		{Owner: product(1), Namespace: "specs", Key: "a", Type: text, Value: "1"},
+		{Owner: product(1), Namespace: "specs", Key: "b", Type: text, Value: "3", Expected: &two},
+		{Owner: product(1), Namespace: "specs", Key: "c", Type: text, Value: "4", Expected: &none},
+		{Owner: product(1), Namespace: "specs", Key: "d", Type: text, Value: "5", Expected: &two},
+		{Owner: product(1), Namespace: "other", Key: "e", Type: text, Value: "6"},
+	}
+	report, err := NewWriter(srv.API()).SyncNamespace(context.Background(), "specs", []shopify.MetafieldOwner{product(2), product(3)}, writes)
+	if err != nil {
+		t.Fatalf("Error syncing: %v", err)
+	}
+
+	if got := keys(report.Unchanged); len(got) != 1 || got[0] != "product/1/a" {
+		t.Errorf("Expected a to be unchanged, got %v", got)
+	}
+	if got := keys(report.Written); len(got) != 1 || got[0] != "product/1/b" {
+		t.Errorf("Expected b to be written, got %v", got)
+	}
+	if got := keys(report.Deleted); len(got) != 2 || got[0] != "product/2/old" || got[1] != "product/1/stale" {
+		t.Errorf("Expected the keys not written to be deleted, got %v", got)
+	}
+	if len(report.Failed) != 3 || !errors.Is(report.Failed[0].Err, ErrConflict) || !errors.Is(report.Failed[1].Err, ErrConflict) || report.Failed[2].Key != "e" {
+		t.Fatalf("Expected c and d to conflict and e to be outside the namespace, got %v", report.Failed)
+	}
+
+	sets := inputs(srv.GraphQLRequests(), "metafieldsSet")
+	if len(sets) != 2 {
+		t.Fatalf("Expected the batch to be sent again without c, got %v", sets)
+	}
+	digests := map[interface{}]interface{}{}
+	for _, input := range sets[0] {
+		m := input.(map[string]interface{})
+		digests[m["key"]] = m["compareDigest"]
+	}
+	if len(digests) != 2 || digests["b"] != "d-b" || digests["c"] != nil {
+		t.Errorf("Expected b to be compared with its digest and c with null, got %v", digests)
+	}
+	if deletes := inputs(srv.GraphQLRequests(), "metafieldsDelete"); len(deletes) != 1 || len(deletes[0]) != 2 {
+		t.Errorf("Expected one deletion of 2 metafields, got %v", deletes)
+	}
+}
+
+func TestReadBatches(t *testing.T) {
+	first := regexp.MustCompile(`metafields\(first: (\d+)`)
+	srv := graphQLServer(t, func(req shopifytest.GraphQLRequest) string {
+		if strings.Contains(req.Query, "shop {") {
+			return `{"shop":{"id":"gid://shopify/Shop/1"}}`
+		}
+		return `{"nodes":[]}`
+	})
+
+	owners := []shopify.MetafieldOwner{}
+	for id := int64(1); id <= 40; id++ {
+		owners = append(owners, product(id))
+	}
+	if _, err := NewWriter(srv.API()).SyncNamespace(context.Background(), "specs", owners, nil); err != nil {
+		t.Fatalf("Error syncing: %v", err)
+	}
+
+	// the requested cost of a query is about first+2 per owner
+	read := 0
+	for _, req := range srv.GraphQLRequests() {
+		if !strings.Contains(req.Query, "nodes(ids:") {
+			continue
+		}
+		ids := len(req.Variables["ids"].([]interface{}))
+		read += ids
+		n, _ := strconv.Atoi(first.FindStringSubmatch(req.Query)[1])
+		if cost := ids * (n + 2); cost > maxQueryCost {
+			t.Errorf("Expected reads to cost at most %d, got %d ids costing %d", maxQueryCost, ids, cost)
+		}
+	}
+	if read != len(owners) {
+		t.Errorf("Expected every owner to be read once, got %d", read)
+	}
+}
+
+func TestWriteREST(t *testing.T) {
+	srv := shopifytest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	shirt := srv.Seed("products", shopifytest.Object{"title": "T-shirt"})
+	mug := srv.Seed("products", shopifytest.Object{"title": "Mug"})
+	seedMetafield := func(owner int64, key, value string) {
+		srv.Seed("metafields", shopifytest.Object{"owner_resource": "product", "owner_id": owner, "namespace": "specs", "key": key, "value": value, "type": "single_line_text_field"})
+	}
+	seedMetafield(shirt, "fabric", "cotton")
+	seedMetafield(shirt, "fit", "slim")
+	seedMetafield(mug, "volume", "300ml")
+
+	text := shopify.MetafieldTypeSingleLineText
+	slim := "slim"
+	writes := []Write{
+		{Owner: product(shirt), Namespace: "specs", Key: "fabric", Type: text, Value: "cotton"},
+		{Owner: product(shirt), Namespace: "specs", Key: "fit", Type: text, Value: "regular", Expected: &slim},
+		{Owner: product(shirt), Namespace: "specs", Key: "care", Type: text, Value: "30°C"},
+	}
+	owners := []shopify.MetafieldOwner{product(mug)}
+
+	writer := NewWriter(api)
+	writer.DryRun = true
+	report, err := writer.SyncNamespace(context.Background(), "specs", owners, writes)
+	if err != nil {
+		t.Fatalf("Error in dry run: %v", err)
+	}
+	if len(report.Written) != 2 || len(report.Unchanged) != 1 || len(report.Deleted) != 1 || len(srv.Objects("metafields")) != 3 {
+		t.Fatalf("Expected a dry run to report 2 writes and a deletion without making them, got %#v", report)
+	}
+
+	writer.DryRun = false
+	report, err = writer.SyncNamespace(context.Background(), "specs", owners, writes)
+	if err != nil {
+		t.Fatalf("Error syncing: %v", err)
+	}
+	if len(report.Written) != 2 || len(report.Deleted) != 1 || len(report.Failed) != 0 {
+		t.Fatalf("Expected 2 writes and a deletion, got %#v", report)
+	}
+
+	values := map[string]string{}
+	for _, m := range srv.Objects("metafields") {
+		values[fmt.Sprintf("%v/%v", m["owner_id"], m["key"])] = fmt.Sprint(m["value"])
+	}
+	expected := map[string]string{
+		fmt.Sprintf("%d/fabric", shirt): "cotton",
+		fmt.Sprintf("%d/fit", shirt):    "regular",
+		fmt.Sprintf("%d/care", shirt):   "30°C",
+	}
+	if fmt.Sprint(values) != fmt.Sprint(expected) {
+		t.Errorf("Expected metafields %v, got %v", expected, values)
+	}
+
+	// GraphQL isn't served by the fake, so the writer fell back to REST
+	graphQL := 0
+	for _, req := range srv.Requests() {
+		if strings.HasSuffix(req.Path, "graphql.json") {
+			graphQL++
+		}
+	}
+	if graphQL != 2 {
+		t.Errorf("Expected a GraphQL probe per run, got %d", graphQL)
+	}
+
+	// a compare-and-set reads the metafields of its owner first, and fit
+	// was written above
+	report, err = writer.Write(context.Background(), []Write{
+		{Owner: product(shirt), Namespace: "specs", Key: "fit", Type: text, Value: "loose", Expected: &slim},
+		{Owner: product(mug), Namespace: "specs", Key: "volume", Type: text, Value: "350ml"},
+	})
+	if err != nil {
+		t.Fatalf("Error writing: %v", err)
+	}
+	if len(report.Failed) != 1 || !errors.Is(report.Failed[0].Err, ErrConflict) || len(report.Written) != 1 {
+		t.Errorf("Expected the stale compare-and-set to conflict, got %#v", report)
+	}
+	if n := len(srv.Objects("metafields")); n != 4 {
+		t.Errorf("Expected the mug's volume to be created, got %d metafields", n)
+	}
+}
+
+func TestWriteCanceled(t *testing.T) {
+	srv := shopifytest.NewServer()
+	defer srv.Close()
+
+	shirt := srv.Seed("products", shopifytest.Object{"title": "T-shirt"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	writer := NewWriter(srv.API())
+	writer.REST = true
+	report, err := writer.Write(ctx, []Write{{Owner: product(shirt), Namespace: "specs", Key: "fit", Type: shopify.MetafieldTypeSingleLineText, Value: "slim"}})
+	if !errors.Is(err, context.Canceled) || len(report.Failed) != 1 || len(srv.Objects("metafields")) != 0 {
+		t.Errorf("Expected nothing to be written once canceled, got %v, %#v", err, report)
+	}
+}
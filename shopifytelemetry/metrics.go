@@ -0,0 +1,124 @@
+package shopifytelemetry
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/boourns/go_shopify"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics collects Prometheus metrics about requests to the Shopify API:
+//
+//	shopify_request_duration_seconds{shop,resource,method,status}  histogram
+//	shopify_request_errors_total{shop,resource,method,reason}       counter
+//	shopify_throttled_requests_total{shop,resource}                 counter
+//	shopify_call_limit_used{shop}                                   gauge
+//	shopify_call_limit{shop}                                        gauge
+//	shopify_call_limit_fill_ratio{shop}                             gauge
+//
+// status is "error" for requests which got no response.
+type Metrics struct {
+	Duration  *prometheus.HistogramVec
+	Errors    *prometheus.CounterVec
+	Throttled *prometheus.CounterVec
+	CallsMade *prometheus.GaugeVec
+	CallLimit *prometheus.GaugeVec
+	Fill      *prometheus.GaugeVec
+}
+
+// NewMetrics creates the metrics and registers them with reg, unless nil.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "shopify",
+			Name:      "request_duration_seconds",
+			Help:      "Time spent waiting for responses from the Shopify API.",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"shop", "resource", "method", "status"}),
+		Errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "shopify",
+			Name:      "request_errors_total",
+			Help:      "Failed requests to the Shopify API, by reason.",
+		}, []string{"shop", "resource", "method", "reason"}),
+		Throttled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "shopify",
+			Name:      "throttled_requests_total",
+			Help:      "Requests to the Shopify API answered with 429 Too Many Requests.",
+		}, []string{"shop", "resource"}),
+		CallsMade: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "shopify",
+			Name:      "call_limit_used",
+			Help:      "Calls in the REST call-limit bucket after the last response.",
+		}, []string{"shop"}),
+		CallLimit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "shopify",
+			Name:      "call_limit",
+			Help:      "Size of the REST call-limit bucket.",
+		}, []string{"shop"}),
+		Fill: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "shopify",
+			Name:      "call_limit_fill_ratio",
+			Help:      "Fill ratio of the REST call-limit bucket, from 0 to 1.",
+		}, []string{"shop"}),
+	}
+
+	if reg != nil {
+		for _, c := range m.collectors() {
+			if err := reg.Register(c); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.Duration, m.Errors, m.Throttled, m.CallsMade, m.CallLimit, m.Fill}
+}
+
+// Hook returns the hook to register with API.Use.
+func (m *Metrics) Hook() shopify.Hook {
+	return shopify.Hook{
+		AfterResponse: func(event *shopify.RequestEvent) {
+			m.Duration.WithLabelValues(event.Shop, event.Resource, event.Method, strconv.Itoa(event.StatusCode)).Observe(event.Duration.Seconds())
+
+			if event.StatusCode == 429 {
+				m.Throttled.WithLabelValues(event.Shop, event.Resource).Inc()
+			}
+
+			// GraphQL responses have no call-limit header
+			if event.ResponseHeader.Get("X-Shopify-Shop-Api-Call-Limit") != "" && event.CallLimit > 0 {
+				m.CallsMade.WithLabelValues(event.Shop).Set(float64(event.CallsMade))
+				m.CallLimit.WithLabelValues(event.Shop).Set(float64(event.CallLimit))
+				m.Fill.WithLabelValues(event.Shop).Set(float64(event.CallsMade) / float64(event.CallLimit))
+			}
+		},
+		OnError: func(event *shopify.RequestEvent, err error) {
+			if event.StatusCode == 0 {
+				m.Duration.WithLabelValues(event.Shop, event.Resource, event.Method, "error").Observe(event.Duration.Seconds())
+			}
+			m.Errors.WithLabelValues(event.Shop, event.Resource, event.Method, errorReason(event, err)).Inc()
+		},
+	}
+}
+
+// errorReason returns a low cardinality label for err.
+func errorReason(event *shopify.RequestEvent, err error) string {
+	var validationErr *shopify.ValidationError
+	switch {
+	case errors.Is(err, shopify.ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, shopify.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, shopify.ErrUnauthorized):
+		return "unauthorized"
+	case errors.As(err, &validationErr):
+		return "validation"
+	case event.StatusCode >= 500:
+		return "server"
+	case event.StatusCode >= 400:
+		return "client"
+	}
+	return "network"
+}
@@ -0,0 +1,133 @@
+package shopifytelemetry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/boourns/go_shopify/shopifytest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	result := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		result[kv.Key] = kv.Value
+	}
+	return result
+}
+
+func TestTracing(t *testing.T) {
+	srv := shopifytest.NewServer()
+	defer srv.Close()
+	srv.RetryAfter = 100 * time.Millisecond
+
+	recorder := tracetest.NewSpanRecorder()
+	tracing := NewTracing(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	tracing.Propagator = propagation.TraceContext{}
+	api := srv.API()
+	api.Use(tracing.Hook())
+
+	srv.ThrottleNext(1)
+	if _, err := api.CurrentShop(); err != nil {
+		t.Fatalf("Error fetching shop: %v", err)
+	}
+	if _, err := api.Product(12345); err == nil {
+		t.Fatalf("Expected error fetching missing product")
+	}
+
+	// attempts end before the request they belong to
+	spans := recorder.Ended()
+	if len(spans) != 5 {
+		t.Fatalf("Expected 5 spans, got %d", len(spans))
+	}
+
+	expected := []struct {
+		name    string
+		kind    trace.SpanKind
+		parent  int
+		status  int64
+		retries int64
+		code    codes.Code
+	}{
+		{"shopify GET shop", trace.SpanKindClient, 2, 429, 0, codes.Error},
+		{"shopify GET shop", trace.SpanKindClient, 2, 200, 1, codes.Unset},
+		{"shopify GET shop", trace.SpanKindInternal, -1, 200, 1, codes.Unset},
+		{"shopify GET products", trace.SpanKindClient, 4, 404, 0, codes.Error},
+		{"shopify GET products", trace.SpanKindInternal, -1, 404, 0, codes.Error},
+	}
+	for i, e := range expected {
+		attrs := attributes(spans[i])
+		if spans[i].Name() != e.name || spans[i].SpanKind() != e.kind || attrs[StatusKey].AsInt64() != e.status || attrs[RetryCountKey].AsInt64() != e.retries || spans[i].Status().Code != e.code {
+			t.Errorf("Span %d: expected %v, got %s %v %v %v", i, e, spans[i].Name(), spans[i].SpanKind(), attrs, spans[i].Status())
+		}
+		if attrs[ShopKey].AsString() != shopifytest.DefaultShop || attrs[MethodKey].AsString() != "GET" {
+			t.Errorf("Span %d: unexpected attributes %v", i, attrs)
+		}
+		if e.parent >= 0 && spans[i].Parent().SpanID() != spans[e.parent].SpanContext().SpanID() {
+			t.Errorf("Span %d: expected span %d as parent", i, e.parent)
+		}
+	}
+
+	// every attempt carries the context of its own span
+	attempts := []int{0, 1, 3}
+	for i, request := range srv.Requests() {
+		sc := spans[attempts[i]].SpanContext()
+		want := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"
+		if got := request.Header.Get("Traceparent"); got != want {
+			t.Errorf("Request %d: expected traceparent %s, got %q", i, want, got)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	srv := shopifytest.NewServer()
+	defer srv.Close()
+	srv.RetryAfter = 100 * time.Millisecond
+
+	reg := prometheus.NewRegistry()
+	metrics, err := NewMetrics(reg)
+	if err != nil {
+		t.Fatalf("Error creating metrics: %v", err)
+	}
+	api := srv.API()
+	api.Use(metrics.Hook())
+
+	srv.ThrottleNext(1)
+	if _, err = api.CurrentShop(); err != nil {
+		t.Fatalf("Error fetching shop: %v", err)
+	}
+	if _, err = api.Product(12345); err == nil {
+		t.Fatalf("Expected error fetching missing product")
+	}
+
+	shop := shopifytest.DefaultShop
+	if n := testutil.ToFloat64(metrics.Throttled.WithLabelValues(shop, "shop")); n != 1 {
+		t.Errorf("Expected 1 throttled request, got %v", n)
+	}
+	if n := testutil.ToFloat64(metrics.Errors.WithLabelValues(shop, "products", "GET", "not_found")); n != 1 {
+		t.Errorf("Expected 1 not found error, got %v", n)
+	}
+	if n := testutil.ToFloat64(metrics.Errors.WithLabelValues(shop, "shop", "GET", "rate_limited")); n != 1 {
+		t.Errorf("Expected 1 rate limited error, got %v", n)
+	}
+	if n := testutil.ToFloat64(metrics.CallLimit.WithLabelValues(shop)); n != shopifytest.DefaultBucketSize {
+		t.Errorf("Expected call limit %d, got %v", shopifytest.DefaultBucketSize, n)
+	}
+	if n := testutil.ToFloat64(metrics.Fill.WithLabelValues(shop)); n <= 0 || n > 1 {
+		t.Errorf("Expected fill ratio between 0 and 1, got %v", n)
+	}
+	if n := testutil.CollectAndCount(metrics.Duration); n != 3 {
+		t.Errorf("Expected durations for 3 label sets, got %d", n)
+	}
+
+	if _, err = NewMetrics(reg); err == nil {
+		t.Errorf("Expected registering the metrics twice to fail")
+	}
+}
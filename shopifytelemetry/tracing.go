@@ -0,0 +1,160 @@
+// Package shopifytelemetry instruments a shopify.API with OpenTelemetry
+// tracing and Prometheus metrics, through the hooks registered with
+// API.Use. It lives in its own package so that the shopify package doesn't
+// depend on either library.
+//
+//	tracing := shopifytelemetry.NewTracing(nil)
+//	metrics, err := shopifytelemetry.NewMetrics(prometheus.DefaultRegisterer)
+//	...
+//	api.Use(tracing.Hook(), metrics.Hook())
+package shopifytelemetry
+
+import (
+	"sync"
+
+	"github.com/boourns/go_shopify"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName identifies the tracer.
+const InstrumentationName = "github.com/boourns/go_shopify/shopifytelemetry"
+
+// Attribute keys set on spans.
+const (
+	ShopKey       = attribute.Key("shopify.shop")
+	ResourceKey   = attribute.Key("shopify.resource")
+	EndpointKey   = attribute.Key("shopify.endpoint")
+	RetryCountKey = attribute.Key("shopify.retry_count")
+	CallsMadeKey  = attribute.Key("shopify.calls_made")
+	CallLimitKey  = attribute.Key("shopify.call_limit")
+	MethodKey     = attribute.Key("http.request.method")
+	StatusKey     = attribute.Key("http.response.status_code")
+)
+
+// Tracing creates a span for every request, with a child span for every
+// attempt at it. Requests carry no context, so request spans are roots. The
+// context of each attempt's span is injected into its request headers, so
+// that downstream work links to it.
+type Tracing struct {
+	// Propagator injects the span context into requests. If nil, the global
+	// propagator is used.
+	Propagator propagation.TextMapPropagator
+
+	tracer trace.Tracer
+
+	mu sync.Mutex
+	// requests are the spans of requests, by the event of their first
+	// attempt, and attempts the spans of their attempts.
+	requests map[*shopify.RequestEvent]trace.Span
+	attempts map[*shopify.RequestEvent]trace.Span
+}
+
+// NewTracing returns a Tracing using provider, or the global provider if nil.
+func NewTracing(provider trace.TracerProvider) *Tracing {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &Tracing{
+		tracer:   provider.Tracer(InstrumentationName),
+		requests: map[*shopify.RequestEvent]trace.Span{},
+		attempts: map[*shopify.RequestEvent]trace.Span{},
+	}
+}
+
+// Hook returns the hook to register with API.Use.
+func (t *Tracing) Hook() shopify.Hook {
+	return shopify.Hook{
+		BeforeRequest: t.start,
+		AfterResponse: func(event *shopify.RequestEvent) {
+			span := t.span(t.attempts, event, false)
+			if span == nil {
+				return
+			}
+			span.SetAttributes(
+				StatusKey.Int(event.StatusCode),
+				CallsMadeKey.Int(event.CallsMade),
+				CallLimitKey.Int(event.CallLimit),
+			)
+			// failed responses are ended by OnError
+			if event.StatusCode < 400 {
+				t.span(t.attempts, event, true).End()
+			}
+		},
+		OnError: func(event *shopify.RequestEvent, err error) {
+			if span := t.span(t.attempts, event, true); span != nil {
+				end(span, err)
+			}
+		},
+		AfterRequest: func(event *shopify.RequestEvent, err error) {
+			span := t.span(t.requests, event.First, true)
+			if span == nil {
+				return
+			}
+			span.SetAttributes(RetryCountKey.Int(event.Attempt - 1))
+			if event.StatusCode != 0 {
+				span.SetAttributes(StatusKey.Int(event.StatusCode))
+			}
+			end(span, err)
+		},
+	}
+}
+
+func (t *Tracing) start(event *shopify.RequestEvent) error {
+	name := "shopify " + event.Method + " " + event.Resource
+	attributes := []attribute.KeyValue{
+		ShopKey.String(event.Shop),
+		ResourceKey.String(event.Resource),
+		EndpointKey.String(event.Endpoint),
+		MethodKey.String(event.Method),
+	}
+
+	// the request span is started with its first attempt
+	t.mu.Lock()
+	request := t.requests[event.First]
+	if request == nil {
+		_, request = t.tracer.Start(event.Request.Context(), name, trace.WithAttributes(attributes...))
+		t.requests[event.First] = request
+	}
+	t.mu.Unlock()
+
+	ctx, span := t.tracer.Start(trace.ContextWithSpan(event.Request.Context(), request), name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attributes, RetryCountKey.Int(event.Attempt-1))...),
+	)
+	propagator := t.Propagator
+	if propagator == nil {
+		propagator = otel.GetTextMapPropagator()
+	}
+	propagator.Inject(ctx, propagation.HeaderCarrier(event.Request.Header))
+	event.Request = event.Request.WithContext(ctx)
+
+	t.mu.Lock()
+	t.attempts[event] = span
+	t.mu.Unlock()
+	return nil
+}
+
+// span returns the span started for event in spans, forgetting it if
+// remove is set.
+func (t *Tracing) span(spans map[*shopify.RequestEvent]trace.Span, event *shopify.RequestEvent, remove bool) trace.Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := spans[event]
+	if remove {
+		delete(spans, event)
+	}
+	return span
+}
+
+// end ends span, recording err if it isn't nil.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,298 @@
+package shopifytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// Scrubbed replaces credentials in recorded interactions.
+const Scrubbed = "[SCRUBBED]"
+
+// headers whose values are replaced with Scrubbed when recording
+var scrubbedHeaders = []string{
+	"X-Shopify-Access-Token",
+	"Authorization",
+	"X-Shopify-Hmac-Sha256",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// query parameters whose values are replaced with Scrubbed when recording
+var scrubbedParams = []string{"hmac", "signature", "code", "access_token"}
+
+// JSON body fields whose values are replaced with Scrubbed when recording
+var scrubbedFields = regexp.MustCompile(`("(?:access_token|client_secret|subject_token|code)"\s*:\s*)"[^"]*"`)
+
+// RecordedRequest is the request half of an Interaction.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URI    string      `json:"uri"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the response half of an Interaction.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Interaction is a recorded request/response pair.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Cassette is a list of interactions stored in a fixture file.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette reads a cassette from a fixture file.
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{}
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Save writes the cassette to a fixture file.
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// Recorder is an http.RoundTripper that records every interaction passing
+// through it, with access tokens, basic auth and HMAC values scrubbed.
+//
+//	rec := shopifytest.NewRecorder(http.DefaultTransport)
+//	api.Client = rec.Client()
+//	...
+//	rec.Save("testdata/products.json")
+type Recorder struct {
+	// Transport sends the requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder returns a Recorder sending requests with transport.
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	return &Recorder{Transport: transport}
+}
+
+// Client returns an http.Client using the recorder, for use as API.Client.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Cassette returns a copy of the interactions recorded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]Interaction{}, r.cassette.Interactions...)}
+}
+
+// Save writes the interactions recorded so far to a fixture file.
+func (r *Recorder) Save(path string) error {
+	return r.Cassette().Save(path)
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URI:    scrubURI(req.URL),
+			Header: scrubHeader(req.Header),
+			Body:   scrubBody(reqBody),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     scrubHeader(resp.Header),
+			Body:       scrubBody(respBody),
+		},
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// readBody reads and replaces body so that it can be read again.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+func scrubURI(u *url.URL) string {
+	query := u.Query()
+	for _, param := range scrubbedParams {
+		if query.Get(param) != "" {
+			query.Set(param, Scrubbed)
+		}
+	}
+	uri := u.Path
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+	return uri
+}
+
+func scrubHeader(header http.Header) http.Header {
+	result := header.Clone()
+	for _, name := range scrubbedHeaders {
+		if result.Get(name) != "" {
+			result.Set(name, Scrubbed)
+		}
+	}
+	return result
+}
+
+func scrubBody(body []byte) string {
+	return string(scrubbedFields.ReplaceAll(body, []byte(`$1"`+Scrubbed+`"`)))
+}
+
+// Replayer is an http.RoundTripper that answers requests from a cassette,
+// without network access. Requests are matched on method, path and query and
+// body; JSON bodies are compared semantically. Each interaction is replayed
+// at most once, in recorded order.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer returns a Replayer for the interactions in cassette.
+func NewReplayer(cassette *Cassette) *Replayer {
+	return &Replayer{
+		interactions: cassette.Interactions,
+		used:         make([]bool, len(cassette.Interactions)),
+	}
+}
+
+// LoadReplayer returns a Replayer for the cassette stored in a fixture file.
+func LoadReplayer(path string) (*Replayer, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(cassette), nil
+}
+
+// Client returns an http.Client using the replayer, for use as API.Client.
+func (r *Replayer) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Unused returns the interactions that haven't been replayed yet.
+func (r *Replayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := []Interaction{}
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			result = append(result, interaction)
+		}
+	}
+	return result
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	uri := scrubURI(req.URL)
+	reqBody := scrubBody(body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Request.Method != req.Method || interaction.Request.URI != uri {
+			continue
+		}
+		if !sameBody(interaction.Request.Body, reqBody) {
+			continue
+		}
+		r.used[i] = true
+
+		header := interaction.Response.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader([]byte(interaction.Response.Body))),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("shopifytest: no recorded interaction for %s %s", req.Method, uri)
+}
+
+func sameBody(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
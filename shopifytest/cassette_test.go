@@ -0,0 +1,143 @@
+package shopifytest
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boourns/go_shopify"
+)
+
+func replayAPI(t *testing.T, fixture string) (*shopify.API, *Replayer) {
+	replayer, err := LoadReplayer(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatalf("Error loading cassette: %v", err)
+	}
+	// unrecorded requests fail like network errors, don't retry them
+	return &shopify.API{Shop: DefaultShop, AccessToken: "shpat_replay", Client: replayer.Client(), RetryPolicy: shopify.NoRetry}, replayer
+}
+
+func TestReplayProductSave(t *testing.T) {
+	api, replayer := replayAPI(t, "products.json")
+
+	product := api.NewProduct()
+	product.Title = stringPtr("Burton Custom Freestyle 151")
+	product.BodyHTML = stringPtr("<strong>Good snowboard!</strong>")
+	product.Vendor = stringPtr("Burton")
+	product.ProductType = stringPtr("Snowboard")
+	if err := product.Save(nil); err != nil {
+		t.Fatalf("Error saving product: %v", err)
+	}
+	if product.ID != 1071559582 || len(product.Variants) != 1 || product.Variants[0].InventoryItemID != 1070325019 {
+		t.Errorf("Unexpected product %#v", product)
+	}
+
+	if err := product.Save(&shopify.Product{Title: stringPtr("Burton Custom Freestyle 152")}); err != nil {
+		t.Fatalf("Error updating product: %v", err)
+	}
+	if *product.Title != "Burton Custom Freestyle 152" || *product.Vendor != "Burton" {
+		t.Errorf("Unexpected product after update %#v", product)
+	}
+
+	invalid := api.NewProduct()
+	invalid.Vendor = stringPtr("Burton")
+	err := invalid.Save(nil)
+	var validationErr *shopify.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected validation error, got %v", err)
+	}
+	if len(validationErr.Fields["title"]) == 0 {
+		t.Errorf("Expected title error, got %v", validationErr.Fields)
+	}
+
+	if unused := replayer.Unused(); len(unused) != 0 {
+		t.Errorf("Expected every interaction to be replayed, %d left", len(unused))
+	}
+}
+
+func TestReplayOrderDecoding(t *testing.T) {
+	api, _ := replayAPI(t, "orders.json")
+
+	order, err := api.Order(450789469)
+	if err != nil {
+		t.Fatalf("Error fetching order: %v", err)
+	}
+
+	if order.Name != "#1001" || order.TotalPrice != "598.94" || order.SubtotalPrice != "597.00" {
+		t.Errorf("Unexpected order totals %#v", order)
+	}
+	if len(order.LineItems) != 2 || order.LineItems[0].Sku != "IPOD2008GREEN" || len(order.LineItems[0].Properties) != 1 {
+		t.Errorf("Unexpected line items %#v", order.LineItems)
+	}
+	if order.ShippingLines[0].Price != "0.00" || order.BillingAddress.ProvinceCode != "KY" {
+		t.Errorf("Unexpected shipping %#v", order.ShippingLines)
+	}
+	if order.Customer.Id != 207119551 || order.Customer.LastOrderId != 450789469 || !order.Customer.DefaultAddress.Default {
+		t.Errorf("Unexpected customer %#v", order.Customer)
+	}
+
+	if _, err = api.Order(1); !errors.Is(err, shopify.ErrNotFound) {
+		t.Errorf("Expected not found error fetching missing order, got %v", err)
+	}
+
+	if _, err = api.Order(2); err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+		t.Errorf("Expected unrecorded request to fail, got %v", err)
+	}
+}
+
+func TestRecorderScrubsCredentials(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	recorder := NewRecorder(http.DefaultTransport)
+	api := srv.API()
+	api.Client = recorder.Client()
+
+	hook := api.NewWebhook()
+	hook.Address = "https://example.com/hook?hmac=abcdef"
+	hook.Topic = "orders/create"
+	if err := hook.Save(nil); err != nil {
+		t.Fatalf("Error creating webhook: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatalf("Error saving cassette: %v", err)
+	}
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("Error loading cassette: %v", err)
+	}
+	if len(cassette.Interactions) != 1 {
+		t.Fatalf("Expected 1 interaction, got %d", len(cassette.Interactions))
+	}
+	req := cassette.Interactions[0].Request
+	if req.Header.Get("X-Shopify-Access-Token") != Scrubbed {
+		t.Errorf("Expected access token to be scrubbed, got %q", req.Header.Get("X-Shopify-Access-Token"))
+	}
+
+	// replaying the recording gives the same result
+	replayer := NewReplayer(cassette)
+	replayed := &shopify.API{Shop: DefaultShop, Client: replayer.Client()}
+	again := replayed.NewWebhook()
+	again.Address = hook.Address
+	again.Topic = hook.Topic
+	if err = again.Save(nil); err != nil {
+		t.Fatalf("Error replaying webhook creation: %v", err)
+	}
+	if again.Id != hook.Id {
+		t.Errorf("Expected replayed webhook %d, got %d", hook.Id, again.Id)
+	}
+}
+
+func TestScrubURI(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://shop.myshopify.com/admin/oauth?code=abc&hmac=def&shop=shop.myshopify.com", nil)
+	uri := scrubURI(req.URL)
+
+	if strings.Contains(uri, "abc") || strings.Contains(uri, "def") || !strings.Contains(uri, "shop=shop.myshopify.com") {
+		t.Errorf("Unexpected scrubbed URI %s", uri)
+	}
+}
@@ -0,0 +1,1337 @@
+// Package shopifytest provides an in-process fake of the Shopify Admin REST
+// API, so that code built on the shopify package can be tested without
+// network access or live credentials.
+//
+//	srv := shopifytest.NewServer()
+//	defer srv.Close()
+//
+//	api := srv.API()
+//	product := api.NewProduct()
+//	...
+//
+// The fake keeps in-memory state for products, variants, orders, customers,
+// webhooks, metafields, inventory items and levels, locations, fulfillment
+// services, collections and collects, including the products of collections
+// and the manual order of smart collections. It answers with the status
+// codes, error bodies and call-limit headers Shopify uses, including 429
+// responses once the leaky bucket is full. GraphQL requests are answered by
+// the GraphQL function of the server, if set.
+package shopifytest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boourns/go_shopify"
+)
+
+const (
+	// DefaultShop is the shop domain served by a new Server.
+	DefaultShop = "shopifytest.myshopify.com"
+	// DefaultAccessToken is the access token accepted by a new Server.
+	DefaultAccessToken = "shpat_shopifytest"
+	// DefaultBucketSize is the size of the leaky bucket used for rate limiting.
+	DefaultBucketSize = 40
+	// DefaultLeakRate is the number of calls leaked from the bucket per second.
+	DefaultLeakRate = 2
+	// DefaultLocationID is the ID of the location every new Server starts with.
+	DefaultLocationID = 1
+)
+
+// Object is a resource stored by the fake, as decoded from JSON.
+type Object map[string]interface{}
+
+// Request is a request received by the fake.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// GraphQLRequest is a request to the GraphQL endpoint.
+type GraphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// Server is a fake Shopify Admin API. Its exported fields may be changed
+// before the first request is made.
+type Server struct {
+	*httptest.Server
+
+	Shop        string
+	AccessToken string
+
+	// BucketSize and LeakRate configure the leaky bucket rate limiter. Set
+	// LeakRate to 0 to disable rate limiting.
+	BucketSize int
+	LeakRate   float64
+	// RetryAfter is sent in the Retry-After header of 429 responses.
+	RetryAfter time.Duration
+	// GraphQL, if set, answers the requests to the GraphQL endpoint with the
+	// data of the response, as JSON. Without it the endpoint isn't found.
+	// GraphQL requests don't take calls from the bucket. It is called with
+	// the server locked, so it must not call the methods of the server.
+	GraphQL func(req GraphQLRequest) string
+
+	mu        sync.Mutex
+	nextID    int64
+	resources map[string]map[int64]Object
+	levels    map[levelKey]Object
+	orders    map[int64][]int64
+	bucket    float64
+	lastLeak  time.Time
+	throttle  int
+	failures  []int
+	// lateFailures fail requests after applying them
+	lateFailures []int
+	requests     []Request
+}
+
+type levelKey struct {
+	inventoryItemID int64
+	locationID      int64
+}
+
+var resourceNames = map[string]string{
+	"products":             "product",
+	"variants":             "variant",
+	"orders":               "order",
+	"customers":            "customer",
+	"webhooks":             "webhook",
+	"metafields":           "metafield",
+	"inventory_items":      "inventory_item",
+	"locations":            "location",
+	"fulfillment_services": "fulfillment_service",
+	"custom_collections":   "custom_collection",
+	"smart_collections":    "smart_collection",
+	"collects":             "collect",
+}
+
+// resources that can't be created through the API
+var readOnlyResources = map[string]bool{
+	"inventory_items": true,
+	"locations":       true,
+}
+
+// NewServer starts a fake Shopify server with a default shop and location.
+func NewServer() *Server {
+	s := &Server{
+		Shop:        DefaultShop,
+		AccessToken: DefaultAccessToken,
+		BucketSize:  DefaultBucketSize,
+		LeakRate:    DefaultLeakRate,
+		RetryAfter:  2 * time.Second,
+		nextID:      1000,
+		resources:   map[string]map[int64]Object{},
+		levels:      map[levelKey]Object{},
+		orders:      map[int64][]int64{},
+		lastLeak:    time.Now(),
+	}
+	for name := range resourceNames {
+		s.resources[name] = map[int64]Object{}
+	}
+	s.resources["locations"][DefaultLocationID] = Object{
+		"id":                      int64(DefaultLocationID),
+		"name":                    "Main Warehouse",
+		"address1":                "150 Elgin Street",
+		"city":                    "Ottawa",
+		"country_code":            "CA",
+		"country_name":            "Canada",
+		"province":                "Ontario",
+		"province_code":           "ON",
+		"localized_country_name":  "Canada",
+		"localized_province_name": "Ontario",
+		"active":                  true,
+		"legacy":                  false,
+		"created_at":              now(),
+		"updated_at":              now(),
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// API returns an API client configured to talk to the fake.
+func (s *Server) API() *shopify.API {
+	return &shopify.API{
+		Shop:        s.Shop,
+		AccessToken: s.AccessToken,
+		BaseURL:     s.URL,
+	}
+}
+
+// Seed stores obj under resource (e.g. "products") and returns its ID. An ID
+// is assigned if obj doesn't have one. Products are seeded with their
+// variants and inventory items, as if created through the API.
+func (s *Server) Seed(resource string, obj Object) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj = clone(obj)
+	if id := toInt64(obj["id"]); id != 0 {
+		if id >= s.nextID {
+			s.nextID = id + 1
+		}
+	} else {
+		obj["id"] = s.newID()
+	}
+	if resource == "products" {
+		s.storeVariants(obj, toInt64(obj["id"]))
+	}
+	if s.resources[resource] == nil {
+		s.resources[resource] = map[int64]Object{}
+	}
+	s.resources[resource][toInt64(obj["id"])] = obj
+	return toInt64(obj["id"])
+}
+
+// Object returns a copy of the stored object, or nil if there is none.
+func (s *Server) Object(resource string, id int64) Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, ok := s.resources[resource][id]
+	if !ok {
+		return nil
+	}
+	return clone(s.render(resource, obj))
+}
+
+// Objects returns copies of every stored object of resource, ordered by ID.
+func (s *Server) Objects(resource string) []Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []Object{}
+	for _, obj := range s.sorted(resource) {
+		result = append(result, clone(s.render(resource, obj)))
+	}
+	return result
+}
+
+// InventoryLevel returns the available quantity stored for an inventory
+// item at a location, and whether they are connected.
+func (s *Server) InventoryLevel(inventoryItemID, locationID int64) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	level, ok := s.levels[levelKey{inventoryItemID, locationID}]
+	if !ok {
+		return 0, false
+	}
+	return toInt64(level["available"]), true
+}
+
+// ThrottleNext makes the next n requests fail with 429 Too Many Requests.
+func (s *Server) ThrottleNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttle += n
+}
+
+// FailNext makes the next request fail with status, without applying it.
+func (s *Server) FailNext(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, status)
+}
+
+// FailNextAfterApplying makes the next request fail with status after
+// applying it, as when the response to a write is lost to a timeout.
+func (s *Server) FailNextAfterApplying(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lateFailures = append(s.lateFailures, status)
+}
+
+// Requests returns every request received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+// GraphQLRequests returns every request to the GraphQL endpoint received so
+// far.
+func (s *Server) GraphQLRequests() []GraphQLRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []GraphQLRequest{}
+	for _, r := range s.requests {
+		if !isGraphQL(r.Path) {
+			continue
+		}
+		req := GraphQLRequest{}
+		json.Unmarshal(r.Body, &req)
+		result = append(result, req)
+	}
+	return result
+}
+
+func isGraphQL(path string) bool {
+	return strings.HasPrefix(path, "/admin/api/") && strings.HasSuffix(path, "/graphql.json")
+}
+
+func (s *Server) newID() int64 {
+	id := s.nextID
+	s.nextID++
+	return id
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+type response struct {
+	status int
+	body   interface{}
+}
+
+// page is the body of a paginated list, linking to the next page.
+type page struct {
+	body interface{}
+	next string
+}
+
+func errorResponse(status int, errors interface{}) response {
+	return response{status, map[string]interface{}{"errors": errors}}
+}
+
+var notFound = errorResponse(404, "Not Found")
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeResponse(w, errorResponse(400, err.Error()))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+
+	if r.Header.Get("X-Shopify-Access-Token") != s.AccessToken {
+		writeResponse(w, errorResponse(401, "[API] Invalid API key or access token (unrecognized login or wrong password)"))
+		return
+	}
+
+	if isGraphQL(r.URL.Path) {
+		writeResponse(w, s.graphQL(body))
+		return
+	}
+
+	if !s.takeCall() {
+		w.Header().Set("X-Shopify-Shop-Api-Call-Limit", fmt.Sprintf("%d/%d", s.BucketSize, s.BucketSize))
+		w.Header().Set("Retry-After", strconv.FormatFloat(s.RetryAfter.Seconds(), 'f', 1, 64))
+		writeResponse(w, errorResponse(429, "Exceeded 2 calls per second for api client. Reduce request rates to resume uninterrupted service."))
+		return
+	}
+	w.Header().Set("X-Shopify-Shop-Api-Call-Limit", fmt.Sprintf("%d/%d", int(math.Ceil(s.bucket)), s.BucketSize))
+
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		writeResponse(w, errorResponse(status, http.StatusText(status)))
+		return
+	}
+
+	res := s.route(r, body)
+	if len(s.lateFailures) > 0 {
+		status := s.lateFailures[0]
+		s.lateFailures = s.lateFailures[1:]
+		res = errorResponse(status, http.StatusText(status))
+	}
+	writeResponse(w, res)
+}
+
+func (s *Server) graphQL(body []byte) response {
+	if s.GraphQL == nil {
+		return notFound
+	}
+	req := GraphQLRequest{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return errorResponse(400, err.Error())
+	}
+	return response{200, json.RawMessage(`{"data":` + s.GraphQL(req) + `}`)}
+}
+
+func writeResponse(w http.ResponseWriter, res response) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if p, ok := res.body.(page); ok {
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, p.next))
+		res.body = p.body
+	}
+	w.WriteHeader(res.status)
+	if res.body != nil {
+		json.NewEncoder(w).Encode(res.body)
+	}
+}
+
+// takeCall adds a call to the leaky bucket, returning false if it is full.
+func (s *Server) takeCall() bool {
+	if s.throttle > 0 {
+		s.throttle--
+		return false
+	}
+	if s.LeakRate <= 0 {
+		return true
+	}
+
+	t := time.Now()
+	s.bucket = math.Max(0, s.bucket-t.Sub(s.lastLeak).Seconds()*s.LeakRate)
+	s.lastLeak = t
+
+	if s.bucket+1 > float64(s.BucketSize) {
+		return false
+	}
+	s.bucket++
+	return true
+}
+
+func (s *Server) route(r *http.Request, body []byte) response {
+	path := strings.TrimPrefix(r.URL.Path, "/admin")
+	if strings.HasPrefix(path, "/api/") {
+		// strip the API version, e.g. /api/2024-01
+		parts := strings.SplitN(strings.TrimPrefix(path, "/api/"), "/", 2)
+		if len(parts) != 2 {
+			return notFound
+		}
+		path = "/" + parts[1]
+	}
+	if !strings.HasSuffix(path, ".json") {
+		return notFound
+	}
+	segs := strings.Split(strings.TrimSuffix(strings.TrimPrefix(path, "/"), ".json"), "/")
+	query := r.URL.Query()
+
+	switch {
+	case len(segs) == 1 && segs[0] == "shop":
+		return s.shop(r.Method)
+	case segs[0] == "inventory_levels":
+		return s.inventoryLevels(r.Method, segs[1:], query, body)
+	case len(segs) == 3 && segs[0] == "collections" && segs[2] == "products" && r.Method == "GET":
+		id, _ := strconv.ParseInt(segs[1], 10, 64)
+		if !s.exists("collections", id) {
+			return notFound
+		}
+		return s.collectionProducts(id, query)
+	case len(segs) == 3 && segs[0] == "smart_collections" && segs[2] == "order" && r.Method == "PUT":
+		id, _ := strconv.ParseInt(segs[1], 10, 64)
+		return s.orderSmartCollection(id, query)
+	}
+
+	// nested resources, e.g. /products/1/metafields.json
+	var parent string
+	var parentID int64
+	if len(segs) >= 3 {
+		id, err := strconv.ParseInt(segs[1], 10, 64)
+		if err != nil || !s.exists(segs[0], id) {
+			return notFound
+		}
+		parent, parentID = segs[0], id
+		segs = segs[2:]
+	}
+
+	resource := segs[0]
+	if _, ok := resourceNames[resource]; !ok {
+		return notFound
+	}
+	if parent != "" && resource != "metafields" && !(parent == "products" && resource == "variants") {
+		return notFound
+	}
+
+	switch {
+	case len(segs) == 1 && r.Method == "GET":
+		return s.list(resource, parent, parentID, query)
+	case len(segs) == 1 && r.Method == "POST":
+		return s.create(resource, parent, parentID, body)
+	case len(segs) == 2 && segs[1] == "count" && r.Method == "GET":
+		return s.count(resource, parent, parentID, query)
+	case len(segs) == 2:
+		id, err := strconv.ParseInt(segs[1], 10, 64)
+		if err != nil {
+			return notFound
+		}
+		obj, ok := s.resources[resource][id]
+		if !ok || !s.ownedBy(resource, obj, parent, parentID) {
+			return notFound
+		}
+		switch r.Method {
+		case "GET":
+			return response{200, map[string]interface{}{resourceNames[resource]: s.render(resource, obj)}}
+		case "PUT":
+			return s.update(resource, obj, body)
+		case "DELETE":
+			s.delete(resource, id)
+			return response{200, map[string]interface{}{}}
+		}
+	}
+
+	return notFound
+}
+
+func (s *Server) shop(method string) response {
+	if method != "GET" {
+		return notFound
+	}
+	return response{200, map[string]interface{}{"shop": Object{
+		"id":                  int64(1),
+		"name":                "Shopify Test",
+		"email":               "owner@" + s.Shop,
+		"domain":              s.Shop,
+		"myshopify_domain":    s.Shop,
+		"currency":            "USD",
+		"primary_location_id": int64(DefaultLocationID),
+		"plan_name":           "partner_test",
+	}}}
+}
+
+// exists returns true if the parent of a nested resource exists. Custom and
+// smart collections own metafields as collections.
+func (s *Server) exists(parent string, id int64) bool {
+	if parent == "collections" {
+		_, custom := s.resources["custom_collections"][id]
+		_, smart := s.resources["smart_collections"][id]
+		return custom || smart
+	}
+	_, ok := s.resources[parent][id]
+	return ok
+}
+
+// ownerResource returns the owner_resource of the metafields nested under
+// parent.
+func ownerResource(parent string) string {
+	if parent == "collections" {
+		return "collection"
+	}
+	return resourceNames[parent]
+}
+
+// ownedBy returns true if obj belongs to the parent of a nested route, or if
+// the route isn't nested.
+func (s *Server) ownedBy(resource string, obj Object, parent string, parentID int64) bool {
+	if parent == "" {
+		return true
+	}
+	switch resource {
+	case "metafields":
+		return obj["owner_resource"] == ownerResource(parent) && toInt64(obj["owner_id"]) == parentID
+	case "variants":
+		return toInt64(obj["product_id"]) == parentID
+	}
+	return false
+}
+
+func (s *Server) sorted(resource string) []Object {
+	ids := []int64{}
+	for id := range s.resources[resource] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	result := []Object{}
+	for _, id := range ids {
+		result = append(result, s.resources[resource][id])
+	}
+	return result
+}
+
+var nonFilterParams = map[string]bool{
+	"limit": true, "page": true, "since_id": true, "ids": true, "fields": true, "page_info": true,
+	"created_at_min": true, "created_at_max": true, "updated_at_min": true, "updated_at_max": true,
+	"published_at_min": true, "published_at_max": true, "published_status": true, "status": true,
+}
+
+func (s *Server) filter(resource string, parent string, parentID int64, query url.Values) []Object {
+	ids := map[int64]bool{}
+	if query.Get("ids") != "" {
+		for _, id := range strings.Split(query.Get("ids"), ",") {
+			n, _ := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+			ids[n] = true
+		}
+	}
+	sinceID, _ := strconv.ParseInt(query.Get("since_id"), 10, 64)
+
+	result := []Object{}
+	for _, obj := range s.sorted(resource) {
+		id := toInt64(obj["id"])
+		if len(ids) > 0 && !ids[id] {
+			continue
+		}
+		if id <= sinceID {
+			continue
+		}
+		if !s.ownedBy(resource, obj, parent, parentID) {
+			continue
+		}
+		if resource == "metafields" && parent == "" && obj["owner_resource"] != "shop" {
+			continue
+		}
+		match := true
+		for key, values := range query {
+			if nonFilterParams[key] {
+				continue
+			}
+			if v, ok := obj[key]; ok && fmt.Sprint(v) != values[0] {
+				match = false
+			}
+		}
+		if match {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
+
+func (s *Server) list(resource string, parent string, parentID int64, query url.Values) response {
+	objs := s.filter(resource, parent, parentID, query)
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 250 {
+		return errorResponse(400, map[string]interface{}{"limit": "Limit must not exceed 250"})
+	}
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page > 1 {
+		offset := (page - 1) * limit
+		if offset > len(objs) {
+			offset = len(objs)
+		}
+		objs = objs[offset:]
+	}
+	if len(objs) > limit {
+		objs = objs[:limit]
+	}
+
+	result := []Object{}
+	for _, obj := range objs {
+		result = append(result, s.render(resource, obj))
+	}
+	return response{200, map[string]interface{}{resource: result}}
+}
+
+func (s *Server) count(resource string, parent string, parentID int64, query url.Values) response {
+	return response{200, map[string]interface{}{"count": len(s.filter(resource, parent, parentID, query))}}
+}
+
+// decodeBody decodes the {"<singular>": {...}} wrapper of a create or update.
+func decodeBody(resource string, body []byte) (Object, *response) {
+	singular := resourceNames[resource]
+	wrapper := map[string]Object{}
+	dec := json.NewDecoder(strings.NewReader(string(body)))
+	dec.UseNumber()
+	if err := dec.Decode(&wrapper); err != nil || wrapper[singular] == nil {
+		res := errorResponse(400, map[string]interface{}{singular: "Required parameter missing or invalid"})
+		return nil, &res
+	}
+	return wrapper[singular], nil
+}
+
+func (s *Server) create(resource string, parent string, parentID int64, body []byte) response {
+	if readOnlyResources[resource] {
+		return notFound
+	}
+	obj, errRes := decodeBody(resource, body)
+	if errRes != nil {
+		return *errRes
+	}
+
+	if errs := s.validate(resource, obj, 0); len(errs) > 0 {
+		return errorResponse(422, errs)
+	}
+
+	// like Shopify, creating a metafield with the namespace and key of an
+	// existing one of the owner updates it
+	if resource == "metafields" {
+		for _, existing := range s.filter(resource, parent, parentID, url.Values{}) {
+			if existing["namespace"] == obj["namespace"] && existing["key"] == obj["key"] {
+				res := s.update(resource, existing, body)
+				if res.status == 200 {
+					res.status = 201
+				}
+				return res
+			}
+		}
+	}
+
+	id := s.newID()
+	obj["id"] = id
+	obj["created_at"] = now()
+	obj["updated_at"] = now()
+
+	switch resource {
+	case "products":
+		if isBlank(obj["handle"]) {
+			obj["handle"] = strings.ToLower(strings.Join(strings.Fields(fmt.Sprint(obj["title"])), "-"))
+		}
+		if _, ok := obj["variants"]; !ok {
+			obj["variants"] = []interface{}{map[string]interface{}{"title": "Default Title", "option1": "Default Title", "price": "0.00"}}
+		}
+		s.storeVariants(obj, id)
+	case "variants":
+		obj["product_id"] = parentID
+		s.storeVariant(obj, parentID)
+		return response{201, map[string]interface{}{"variant": s.render(resource, obj)}}
+	case "orders":
+		number := len(s.resources["orders"]) + 1
+		obj["number"] = number
+		obj["order_number"] = 1000 + number
+		obj["name"] = fmt.Sprintf("#%d", 1000+number)
+		if isBlank(obj["financial_status"]) {
+			obj["financial_status"] = "paid"
+		}
+		if items, ok := obj["line_items"].([]interface{}); ok {
+			for _, item := range items {
+				if m, ok := item.(map[string]interface{}); ok {
+					m["id"] = s.newID()
+				}
+			}
+		}
+	case "customers":
+		if isBlank(obj["state"]) {
+			obj["state"] = "disabled"
+		}
+	case "webhooks":
+		if isBlank(obj["format"]) {
+			obj["format"] = "json"
+		}
+	case "fulfillment_services":
+		name := fmt.Sprint(obj["name"])
+		obj["handle"] = strings.ToLower(strings.Join(strings.Fields(name), "-"))
+		obj["service_name"] = name
+		if isBlank(obj["format"]) {
+			obj["format"] = "json"
+		}
+		// like Shopify, the service gets a location to stock its inventory
+		locationID := s.newID()
+		s.resources["locations"][locationID] = Object{
+			"id":         locationID,
+			"name":       name,
+			"active":     true,
+			"legacy":     true,
+			"created_at": now(),
+			"updated_at": now(),
+		}
+		obj["location_id"] = locationID
+	case "collects":
+		position := 1
+		for _, other := range s.resources["collects"] {
+			if toInt64(other["collection_id"]) == toInt64(obj["collection_id"]) && int(toInt64(other["position"])) >= position {
+				position = int(toInt64(other["position"])) + 1
+			}
+		}
+		obj["position"] = position
+		obj["sort_value"] = fmt.Sprintf("%010d", position)
+	case "metafields":
+		if parent == "" {
+			obj["owner_resource"] = "shop"
+			obj["owner_id"] = int64(1)
+		} else {
+			obj["owner_resource"] = ownerResource(parent)
+			obj["owner_id"] = parentID
+		}
+	}
+
+	s.resources[resource][id] = obj
+	return response{201, map[string]interface{}{resourceNames[resource]: s.render(resource, obj)}}
+}
+
+func (s *Server) update(resource string, obj Object, body []byte) response {
+	changes, errRes := decodeBody(resource, body)
+	if errRes != nil {
+		return *errRes
+	}
+
+	id := toInt64(obj["id"])
+	merged := clone(obj)
+	for key, value := range changes {
+		if key == "id" || key == "created_at" {
+			continue
+		}
+		if resource == "metafields" && (key == "owner_id" || key == "owner_resource") {
+			continue
+		}
+		merged[key] = value
+	}
+
+	if errs := s.validate(resource, merged, id); len(errs) > 0 {
+		return errorResponse(422, errs)
+	}
+	merged["updated_at"] = now()
+
+	if resource == "products" {
+		if _, ok := changes["variants"]; ok {
+			s.storeVariants(merged, id)
+		}
+	}
+	if resource == "fulfillment_services" {
+		if location, ok := s.resources["locations"][toInt64(merged["location_id"])]; ok {
+			location["name"] = merged["name"]
+			location["updated_at"] = now()
+		}
+	}
+	if resource == "variants" {
+		s.storeVariant(merged, toInt64(merged["product_id"]))
+		return response{200, map[string]interface{}{"variant": s.render(resource, merged)}}
+	}
+
+	s.resources[resource][id] = merged
+	return response{200, map[string]interface{}{resourceNames[resource]: s.render(resource, merged)}}
+}
+
+func (s *Server) delete(resource string, id int64) {
+	obj := s.resources[resource][id]
+	delete(s.resources[resource], id)
+
+	switch resource {
+	case "products":
+		for variantID, variant := range s.resources["variants"] {
+			if toInt64(variant["product_id"]) == id {
+				delete(s.resources["variants"], variantID)
+			}
+		}
+		for collectID, collect := range s.resources["collects"] {
+			if toInt64(collect["product_id"]) == id {
+				delete(s.resources["collects"], collectID)
+			}
+		}
+	case "custom_collections", "smart_collections":
+		delete(s.orders, id)
+		for collectID, collect := range s.resources["collects"] {
+			if toInt64(collect["collection_id"]) == id {
+				delete(s.resources["collects"], collectID)
+			}
+		}
+	case "fulfillment_services":
+		locationID := toInt64(obj["location_id"])
+		delete(s.resources["locations"], locationID)
+		for key := range s.levels {
+			if key.locationID == locationID {
+				delete(s.levels, key)
+			}
+		}
+	}
+}
+
+// validate returns the field errors Shopify would respond with for obj.
+func (s *Server) validate(resource string, obj Object, id int64) map[string][]string {
+	errs := map[string][]string{}
+	required := func(fields ...string) {
+		for _, field := range fields {
+			if isBlank(obj[field]) {
+				errs[field] = append(errs[field], "can't be blank")
+			}
+		}
+	}
+
+	switch resource {
+	case "products", "custom_collections", "smart_collections":
+		required("title")
+	case "webhooks":
+		required("address", "topic")
+	case "fulfillment_services":
+		required("name")
+		if obj["inventory_management"] == true || obj["tracking_support"] == true {
+			required("callback_url")
+		}
+		for otherID, other := range s.resources["fulfillment_services"] {
+			if otherID != id && strings.EqualFold(fmt.Sprint(other["name"]), fmt.Sprint(obj["name"])) {
+				errs["name"] = append(errs["name"], "has already been taken")
+			}
+		}
+	case "metafields":
+		required("namespace", "key", "value")
+	case "orders":
+		if items, _ := obj["line_items"].([]interface{}); len(items) == 0 {
+			errs["line_items"] = append(errs["line_items"], "must have at least one line item")
+		}
+	case "customers":
+		if isBlank(obj["email"]) && isBlank(obj["phone"]) && isBlank(obj["first_name"]) && isBlank(obj["last_name"]) {
+			errs["customer"] = append(errs["customer"], "Email, phone, first name or last name is required")
+		}
+		if email := fmt.Sprint(obj["email"]); !isBlank(obj["email"]) {
+			for otherID, other := range s.resources["customers"] {
+				if otherID != id && strings.EqualFold(fmt.Sprint(other["email"]), email) {
+					errs["email"] = append(errs["email"], "has already been taken")
+				}
+			}
+		}
+	case "collects":
+		required("product_id", "collection_id")
+		if len(errs) > 0 {
+			break
+		}
+		productID, collectionID := toInt64(obj["product_id"]), toInt64(obj["collection_id"])
+		if _, ok := s.resources["products"][productID]; !ok {
+			errs["product"] = append(errs["product"], "can't be blank")
+		}
+		if _, ok := s.resources["custom_collections"][collectionID]; !ok {
+			errs["collection"] = append(errs["collection"], "can't be blank")
+		}
+		for otherID, other := range s.resources["collects"] {
+			if otherID != id && toInt64(other["product_id"]) == productID && toInt64(other["collection_id"]) == collectionID {
+				errs["product_id"] = append(errs["product_id"], "already exists in this collection")
+			}
+		}
+	}
+	return errs
+}
+
+// storeVariants moves the variants of a product into the variants resource,
+// creating inventory items for new ones.
+func (s *Server) storeVariants(product Object, productID int64) {
+	variants, _ := product["variants"].([]interface{})
+	delete(product, "variants")
+
+	keep := map[int64]bool{}
+	for i, v := range variants {
+		variant, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		variant = clone(variant)
+		if _, ok := variant["position"]; !ok {
+			variant["position"] = i + 1
+		}
+		s.storeVariant(variant, productID)
+		keep[toInt64(variant["id"])] = true
+	}
+
+	for id, variant := range s.resources["variants"] {
+		if toInt64(variant["product_id"]) == productID && !keep[id] {
+			delete(s.resources["variants"], id)
+		}
+	}
+}
+
+func (s *Server) storeVariant(variant Object, productID int64) {
+	id := toInt64(variant["id"])
+	if existing, ok := s.resources["variants"][id]; ok {
+		for key, value := range variant {
+			existing[key] = value
+		}
+		variant = existing
+	} else {
+		if id == 0 {
+			id = s.newID()
+		}
+		variant["id"] = id
+		variant["created_at"] = now()
+	}
+	variant["product_id"] = productID
+	variant["updated_at"] = now()
+	if _, ok := variant["inventory_quantity"]; !ok {
+		variant["inventory_quantity"] = 0
+	}
+
+	itemID := toInt64(variant["inventory_item_id"])
+	if _, ok := s.resources["inventory_items"][itemID]; !ok {
+		itemID = s.newID()
+		s.resources["inventory_items"][itemID] = Object{
+			"id":                itemID,
+			"sku":               variant["sku"],
+			"tracked":           variant["inventory_management"] == "shopify",
+			"requires_shipping": true,
+			"created_at":        now(),
+			"updated_at":        now(),
+		}
+	} else if sku, ok := variant["sku"]; ok {
+		s.resources["inventory_items"][itemID]["sku"] = sku
+	}
+	variant["inventory_item_id"] = itemID
+
+	s.resources["variants"][id] = variant
+}
+
+// render returns obj as it is served, e.g. products with their variants.
+func (s *Server) render(resource string, obj Object) Object {
+	if resource != "products" {
+		return obj
+	}
+
+	product := Object{}
+	for key, value := range obj {
+		product[key] = value
+	}
+	variants := []Object{}
+	for _, variant := range s.sorted("variants") {
+		if toInt64(variant["product_id"]) == toInt64(obj["id"]) {
+			variants = append(variants, variant)
+		}
+	}
+	sort.SliceStable(variants, func(i, j int) bool {
+		return toInt64(variants[i]["position"]) < toInt64(variants[j]["position"])
+	})
+	product["variants"] = variants
+	return product
+}
+
+func (s *Server) inventoryLevels(method string, segs []string, query url.Values, body []byte) response {
+	if len(segs) == 0 {
+		switch method {
+		case "GET":
+			return s.listInventoryLevels(query)
+		case "DELETE":
+			key := levelKey{toInt64(query.Get("inventory_item_id")), toInt64(query.Get("location_id"))}
+			if _, ok := s.levels[key]; !ok {
+				return notFound
+			}
+			delete(s.levels, key)
+			return response{204, nil}
+		}
+		return notFound
+	}
+	if len(segs) != 1 || method != "POST" {
+		return notFound
+	}
+
+	wrapper := map[string]interface{}{}
+	dec := json.NewDecoder(strings.NewReader(string(body)))
+	dec.UseNumber()
+	if err := dec.Decode(&wrapper); err != nil {
+		return errorResponse(400, "Invalid JSON")
+	}
+	// Shopify expects the fields at the top level; older versions of the
+	// library wrapped them in {"inventory_level": {...}}, so accept both.
+	fields := wrapper
+	if inner, ok := wrapper["inventory_level"].(map[string]interface{}); ok {
+		fields = inner
+	}
+
+	itemID, locationID := toInt64(fields["inventory_item_id"]), toInt64(fields["location_id"])
+	if _, ok := s.resources["inventory_items"][itemID]; !ok {
+		return errorResponse(422, []string{"Inventory item does not exist"})
+	}
+	if _, ok := s.resources["locations"][locationID]; !ok {
+		return errorResponse(422, []string{"Location does not exist"})
+	}
+	key := levelKey{itemID, locationID}
+	level, connected := s.levels[key]
+
+	switch segs[0] {
+	case "connect":
+		if connected {
+			return response{201, map[string]interface{}{"inventory_level": level}}
+		}
+		level = s.newLevel(key, 0)
+		return response{201, map[string]interface{}{"inventory_level": level}}
+	case "set":
+		available, ok := fields["available"]
+		if !ok {
+			return errorResponse(422, map[string][]string{"available": {"Required parameter missing or invalid"}})
+		}
+		if !connected {
+			level = s.newLevel(key, 0)
+		}
+		level["available"] = toInt64(available)
+	case "adjust":
+		adjustment, ok := fields["available_adjustment"]
+		if !ok {
+			return errorResponse(422, map[string][]string{"available_adjustment": {"Required parameter missing or invalid"}})
+		}
+		if !connected {
+			return errorResponse(422, []string{"Inventory item is not stocked at the location"})
+		}
+		level["available"] = toInt64(level["available"]) + toInt64(adjustment)
+	default:
+		return notFound
+	}
+
+	level["updated_at"] = now()
+	return response{200, map[string]interface{}{"inventory_level": level}}
+}
+
+func (s *Server) newLevel(key levelKey, available int64) Object {
+	level := Object{
+		"inventory_item_id": key.inventoryItemID,
+		"location_id":       key.locationID,
+		"available":         available,
+		"updated_at":        now(),
+	}
+	s.levels[key] = level
+	return level
+}
+
+func (s *Server) listInventoryLevels(query url.Values) response {
+	// like Shopify, the filters of the first page are kept in the cursor,
+	// which replaces them for the following pages
+	filters := query
+	var after levelKey
+	if pageInfo := query.Get("page_info"); pageInfo != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(pageInfo)
+		if err != nil {
+			return errorResponse(400, "Invalid page_info")
+		}
+		if filters, err = url.ParseQuery(string(decoded)); err != nil {
+			return errorResponse(400, "Invalid page_info")
+		}
+		after = levelKey{toInt64(filters.Get("last_inventory_item_id")), toInt64(filters.Get("last_location_id"))}
+	}
+
+	itemIDs := idSet(filters.Get("inventory_item_ids"))
+	locationIDs := idSet(filters.Get("location_ids"))
+	if len(itemIDs) == 0 && len(locationIDs) == 0 {
+		return errorResponse(422, "inventory_item_ids or location_ids must be present")
+	}
+
+	keys := []levelKey{}
+	for key := range s.levels {
+		if len(itemIDs) > 0 && !itemIDs[key.inventoryItemID] {
+			continue
+		}
+		if len(locationIDs) > 0 && !locationIDs[key.locationID] {
+			continue
+		}
+		if after != (levelKey{}) && !after.less(key) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+	more := len(keys) > limit
+	if more {
+		keys = keys[:limit]
+	}
+
+	result := []Object{}
+	for _, key := range keys {
+		result = append(result, s.levels[key])
+	}
+	body := map[string]interface{}{"inventory_levels": result}
+	if !more {
+		return response{200, body}
+	}
+
+	last := keys[len(keys)-1]
+	cursor := url.Values{
+		"inventory_item_ids":     {filters.Get("inventory_item_ids")},
+		"location_ids":           {filters.Get("location_ids")},
+		"last_inventory_item_id": {strconv.FormatInt(last.inventoryItemID, 10)},
+		"last_location_id":       {strconv.FormatInt(last.locationID, 10)},
+	}
+	next := url.Values{
+		"limit":     {strconv.Itoa(limit)},
+		"page_info": {base64.RawURLEncoding.EncodeToString([]byte(cursor.Encode()))},
+	}
+	return response{200, page{body, "/admin/inventory_levels.json?" + next.Encode()}}
+}
+
+// collectionProducts lists the products of a collection: those of its
+// collects in order for custom collections, and those matching the rules
+// for smart collections, with any manually ordered ones first. Pages follow
+// each other through an offset in page_info.
+func (s *Server) collectionProducts(id int64, query url.Values) response {
+	ids := []int64{}
+	if _, ok := s.resources["custom_collections"][id]; ok {
+		collects := []Object{}
+		for _, collect := range s.resources["collects"] {
+			if toInt64(collect["collection_id"]) == id {
+				collects = append(collects, collect)
+			}
+		}
+		// seeded collects have no position, and follow each other by id
+		sort.Slice(collects, func(i, j int) bool {
+			if a, b := toInt64(collects[i]["position"]), toInt64(collects[j]["position"]); a != b {
+				return a < b
+			}
+			return toInt64(collects[i]["id"]) < toInt64(collects[j]["id"])
+		})
+		for _, collect := range collects {
+			ids = append(ids, toInt64(collect["product_id"]))
+		}
+	} else {
+		ids = s.smartCollectionProducts(id)
+	}
+
+	offset := 0
+	if pageInfo := query.Get("page_info"); pageInfo != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(pageInfo)
+		if err != nil {
+			return errorResponse(400, "Invalid page_info")
+		}
+		offset, _ = strconv.Atoi(string(decoded))
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 250 {
+		return errorResponse(400, map[string]interface{}{"limit": "Limit must not exceed 250"})
+	}
+
+	if offset > len(ids) {
+		offset = len(ids)
+	}
+	ids = ids[offset:]
+	more := len(ids) > limit
+	if more {
+		ids = ids[:limit]
+	}
+	products := []Object{}
+	for _, productID := range ids {
+		products = append(products, s.render("products", s.resources["products"][productID]))
+	}
+	body := map[string]interface{}{"products": products}
+	if !more {
+		return response{200, body}
+	}
+	next := url.Values{
+		"limit":     {strconv.Itoa(limit)},
+		"page_info": {base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset + limit)))},
+	}
+	return response{200, page{body, fmt.Sprintf("/admin/collections/%d/products.json?%s", id, next.Encode())}}
+}
+
+// smartCollectionProducts returns the products matching the rules of a
+// smart collection, the manually ordered ones first.
+func (s *Server) smartCollectionProducts(id int64) []int64 {
+	collection := shopify.SmartCollection{}
+	if data, err := json.Marshal(s.resources["smart_collections"][id]); err == nil {
+		json.Unmarshal(data, &collection)
+	}
+
+	matched := map[int64]bool{}
+	ids := []int64{}
+	for _, obj := range s.sorted("products") {
+		product := &shopify.Product{}
+		data, _ := json.Marshal(s.render("products", obj))
+		// products seeded with unusual types still match what they can
+		json.Unmarshal(data, product)
+		if ok, err := collection.Matches(product); ok && err == nil {
+			matched[toInt64(obj["id"])] = true
+			ids = append(ids, toInt64(obj["id"]))
+		}
+	}
+
+	result := []int64{}
+	ordered := map[int64]bool{}
+	for _, productID := range s.orders[id] {
+		if matched[productID] && !ordered[productID] {
+			ordered[productID] = true
+			result = append(result, productID)
+		}
+	}
+	for _, productID := range ids {
+		if !ordered[productID] {
+			result = append(result, productID)
+		}
+	}
+	return result
+}
+
+func (s *Server) orderSmartCollection(id int64, query url.Values) response {
+	collection, ok := s.resources["smart_collections"][id]
+	if !ok {
+		return notFound
+	}
+	if sortOrder := query.Get("sort_order"); sortOrder != "" {
+		collection["sort_order"] = sortOrder
+	}
+	order := []int64{}
+	for _, productID := range query["products[]"] {
+		n, err := strconv.ParseInt(productID, 10, 64)
+		if err != nil {
+			return errorResponse(400, map[string]interface{}{"products": "must be product IDs"})
+		}
+		order = append(order, n)
+	}
+	if len(order) > 0 && collection["sort_order"] != "manual" {
+		return errorResponse(422, map[string]interface{}{"sort_order": []string{"must be manual to order products"}})
+	}
+	s.orders[id] = order
+	collection["updated_at"] = now()
+	return response{200, map[string]interface{}{}}
+}
+
+func (k levelKey) less(other levelKey) bool {
+	if k.inventoryItemID != other.inventoryItemID {
+		return k.inventoryItemID < other.inventoryItemID
+	}
+	return k.locationID < other.locationID
+}
+
+func idSet(list string) map[int64]bool {
+	ids := map[int64]bool{}
+	for _, id := range strings.Split(list, ",") {
+		if n, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64); err == nil {
+			ids[n] = true
+		}
+	}
+	return ids
+}
+
+func isBlank(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	if s, ok := v.(string); ok {
+		return strings.TrimSpace(s) == ""
+	}
+	return false
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int:
+		return int64(n)
+	case float64:
+		return int64(n)
+	case json.Number:
+		i, err := n.Int64()
+		if err != nil {
+			f, _ := n.Float64()
+			return int64(f)
+		}
+		return i
+	case string:
+		i, _ := strconv.ParseInt(n, 10, 64)
+		return i
+	}
+	return 0
+}
+
+func clone(obj map[string]interface{}) Object {
+	b, _ := json.Marshal(obj)
+	result := Object{}
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.UseNumber()
+	dec.Decode(&result)
+	for key, value := range result {
+		if n, ok := value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				result[key] = i
+			}
+		}
+	}
+	return result
+}
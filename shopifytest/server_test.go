@@ -0,0 +1,576 @@
+package shopifytest
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/boourns/go_shopify"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestProductLifecycle(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	product := api.NewProduct()
+	product.Title = stringPtr("T-shirt")
+	product.Variants = []shopify.Variant{{SKU: stringPtr("TS-1"), Price: "10.00"}}
+	if err := product.Save(nil); err != nil {
+		t.Fatalf("Error saving product: %v", err)
+	}
+	if product.ID == 0 || len(product.Variants) != 1 || product.Variants[0].InventoryItemID == 0 {
+		t.Fatalf("Expected product with a variant and inventory item, got %#v", product)
+	}
+
+	item, err := api.InventoryItem(product.Variants[0].InventoryItemID)
+	if err != nil {
+		t.Fatalf("Error fetching inventory item: %v", err)
+	}
+	if *item.Sku != "TS-1" {
+		t.Errorf("Expected inventory item SKU TS-1, got %s", *item.Sku)
+	}
+
+	if err = product.Save(&shopify.Product{Vendor: stringPtr("Acme")}); err != nil {
+		t.Fatalf("Error updating product: %v", err)
+	}
+	if *product.Vendor != "Acme" || *product.Title != "T-shirt" {
+		t.Errorf("Expected partial update to keep the title, got %#v", product)
+	}
+
+	products, err := api.Products(&shopify.ProductsOptions{Vendor: "Acme"})
+	if err != nil {
+		t.Fatalf("Error listing products: %v", err)
+	}
+	if len(products) != 1 || products[0].ID != product.ID {
+		t.Errorf("Expected to list the product, got %#v", products)
+	}
+
+	count, err := api.ProductsCount(nil)
+	if err != nil || count != 1 {
+		t.Errorf("Expected count 1, got %d (%v)", count, err)
+	}
+
+	if err = product.Delete(); err != nil {
+		t.Fatalf("Error deleting product: %v", err)
+	}
+	if _, err = api.Product(product.ID); !errors.Is(err, shopify.ErrNotFound) {
+		t.Errorf("Expected not found error fetching deleted product, got %v", err)
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	err := srv.API().NewProduct().Save(nil)
+	var validationErr *shopify.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+	if validationErr.Response.StatusCode != 422 {
+		t.Errorf("Expected status 422, got %d", validationErr.Response.StatusCode)
+	}
+	if len(validationErr.Fields["title"]) == 0 {
+		t.Errorf("Expected an error for title, got %v", validationErr.Fields)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	hook := api.NewWebhook()
+	hook.Address = "https://example.com/hook"
+	hook.Topic = "orders/create"
+	if err := hook.Save(nil); err != nil {
+		t.Fatalf("Error creating webhook: %v", err)
+	}
+	if hook.Format != "json" {
+		t.Errorf("Expected default format json, got %s", hook.Format)
+	}
+
+	hooks, err := api.Webhooks()
+	if err != nil || len(hooks) != 1 {
+		t.Fatalf("Expected 1 webhook, got %d (%v)", len(hooks), err)
+	}
+
+	if err = hooks[0].Delete(); err != nil {
+		t.Fatalf("Error deleting webhook: %v", err)
+	}
+	if obj := srv.Object("webhooks", hook.Id); obj != nil {
+		t.Errorf("Expected webhook to be deleted, got %v", obj)
+	}
+}
+
+func TestSeededOrders(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	id := srv.Seed("orders", Object{
+		"email":      "customer@example.com",
+		"line_items": []interface{}{map[string]interface{}{"title": "T-shirt", "quantity": 2}},
+	})
+
+	order, err := srv.API().Order(id)
+	if err != nil {
+		t.Fatalf("Error fetching order: %v", err)
+	}
+	if order.Email != "customer@example.com" || len(order.LineItems) != 1 || order.LineItems[0].Quantity != 2 {
+		t.Errorf("Unexpected order %#v", order)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	api := srv.API()
+	api.AccessToken = "wrong"
+	err := api.NewWebhook().Save(nil)
+	if !errors.Is(err, shopify.ErrUnauthorized) {
+		t.Errorf("Expected unauthorized error, got %v", err)
+	}
+}
+
+func TestCallLimitHeaders(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.BucketSize = 2
+	srv.LeakRate = 0.001
+
+	get := func() *http.Response {
+		req, _ := http.NewRequest("GET", srv.URL+"/admin/shop.json", nil)
+		req.Header.Set("X-Shopify-Access-Token", srv.AccessToken)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error fetching shop: %v", err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	for _, expected := range []string{"1/2", "2/2"} {
+		res := get()
+		if res.StatusCode != 200 || res.Header.Get("X-Shopify-Shop-Api-Call-Limit") != expected {
+			t.Errorf("Expected 200 with call limit %s, got %d %s", expected, res.StatusCode, res.Header.Get("X-Shopify-Shop-Api-Call-Limit"))
+		}
+	}
+
+	res := get()
+	if res.StatusCode != 429 || res.Header.Get("Retry-After") != "2.0" {
+		t.Errorf("Expected 429 with Retry-After 2.0, got %d %q", res.StatusCode, res.Header.Get("Retry-After"))
+	}
+}
+
+func TestThrottledRequestIsRetried(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	srv.RetryAfter = 100 * time.Millisecond
+	srv.ThrottleNext(1)
+	if _, err := srv.API().CurrentShop(); err != nil {
+		t.Fatalf("Expected throttled request to be retried, got %v", err)
+	}
+	if len(srv.Requests()) != 2 {
+		t.Errorf("Expected 2 requests, got %d", len(srv.Requests()))
+	}
+}
+
+func TestFailNextAfterApplying(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	srv.FailNextAfterApplying(504)
+	webhook := srv.API().NewWebhook()
+	webhook.Topic = "orders/create"
+	webhook.Address = "https://example.com/hooks"
+	var errorResponse *shopify.ErrorResponse
+	if err := webhook.Save(nil); !errors.As(err, &errorResponse) || errorResponse.StatusCode != 504 {
+		t.Fatalf("Expected a 504, got %v", err)
+	}
+	if len(srv.Objects("webhooks")) != 1 {
+		t.Errorf("Expected the webhook to be created anyway, got %d", len(srv.Objects("webhooks")))
+	}
+}
+
+func TestInventoryLevels(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	warehouse := srv.Seed("locations", Object{"name": "Second Warehouse"})
+	srv.Seed("products", Object{"title": "T-shirt", "variants": []interface{}{
+		map[string]interface{}{"sku": "TS-S"},
+		map[string]interface{}{"sku": "TS-M"},
+		map[string]interface{}{"sku": "TS-L"},
+	}})
+
+	itemIDs := []int64{}
+	for i, variant := range srv.Objects("variants") {
+		itemID := variant["inventory_item_id"].(int64)
+		itemIDs = append(itemIDs, itemID)
+
+		level := api.NewInventoryLevel()
+		level.InventoryItemID = itemID
+		level.LocationID = warehouse
+		if err := level.Connect(); err != nil {
+			t.Fatalf("Error connecting item %d: %v", itemID, err)
+		}
+		// setting zero must still send the quantity
+		level.Available = int64(i * 10)
+		if err := level.Set(); err != nil {
+			t.Fatalf("Error setting level of item %d: %v", itemID, err)
+		}
+	}
+
+	levels, next, err := api.InventoryLevelsPage(&shopify.InventoryLevelsOptions{LocationIDs: []int64{warehouse}, Limit: 2})
+	if err != nil {
+		t.Fatalf("Error listing inventory levels: %v", err)
+	}
+	if len(levels) != 2 || next == nil || next.PageInfo == "" || next.Limit != 2 {
+		t.Fatalf("Expected a first page of 2 levels, got %d and next %#v", len(levels), next)
+	}
+
+	levels, err = api.InventoryLevels(&shopify.InventoryLevelsOptions{LocationIDs: []int64{warehouse}, Limit: 2})
+	if err != nil {
+		t.Fatalf("Error listing inventory levels: %v", err)
+	}
+	if len(levels) != 3 {
+		t.Fatalf("Expected 3 levels across pages, got %d", len(levels))
+	}
+	for i, level := range levels {
+		if level.InventoryItemID != itemIDs[i] || level.Available != int64(i*10) {
+			t.Errorf("Unexpected level %#v", level)
+		}
+	}
+
+	// listed levels can be adjusted
+	levels[2].AvailableAdjustment = -5
+	if err = levels[2].Adjust(); err != nil || levels[2].Available != 15 {
+		t.Errorf("Expected adjusted level of 15, got %d (%v)", levels[2].Available, err)
+	}
+
+	location, err := api.Location(warehouse)
+	if err != nil {
+		t.Fatalf("Error fetching location: %v", err)
+	}
+	if levels, err = location.InventoryLevels(); err != nil || len(levels) != 3 {
+		t.Errorf("Expected the location's 3 levels, got %d (%v)", len(levels), err)
+	}
+
+	byItem, err := api.InventoryLevels(&shopify.InventoryLevelsOptions{InventoryItemIDs: itemIDs[:1]})
+	if err != nil || len(byItem) != 1 || byItem[0].LocationID != warehouse {
+		t.Errorf("Expected 1 level for the first item, got %v (%v)", byItem, err)
+	}
+}
+
+func TestInventoryItemUpdate(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	srv.Seed("products", Object{"title": "T-shirt", "variants": []interface{}{map[string]interface{}{"sku": "TS-1"}}})
+	item, err := api.InventoryItem(srv.Objects("variants")[0]["inventory_item_id"].(int64))
+	if err != nil {
+		t.Fatalf("Error fetching inventory item: %v", err)
+	}
+
+	item.Cost = stringPtr("4.50")
+	item.CountryCodeOfOrigin = stringPtr("CA")
+	item.HarmonizedSystemCode = stringPtr("610910")
+	item.CountryHarmonizedSystemCodes = []shopify.CountryHarmonizedSystemCode{{HarmonizedSystemCode: "6109100010", CountryCode: "US"}}
+	requiresShipping := true
+	item.RequiresShipping = &requiresShipping
+	if err = item.Update(); err != nil {
+		t.Fatalf("Error updating inventory item: %v", err)
+	}
+
+	items, err := api.InventoryItemsWithOptions(&shopify.InventoryItemsOptions{IDs: []int64{item.ID}})
+	if err != nil || len(items) != 1 {
+		t.Fatalf("Error listing inventory items: %v", err)
+	}
+	got := items[0]
+	if *got.Cost != "4.50" || *got.CountryCodeOfOrigin != "CA" || *got.HarmonizedSystemCode != "610910" || !*got.RequiresShipping || *got.Sku != "TS-1" {
+		t.Errorf("Unexpected inventory item %#v", got)
+	}
+	if len(got.CountryHarmonizedSystemCodes) != 1 || got.CountryHarmonizedSystemCodes[0].CountryCode != "US" {
+		t.Errorf("Unexpected HS codes %v", got.CountryHarmonizedSystemCodes)
+	}
+}
+
+func TestFulfillmentServices(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	api := srv.API()
+	api.Cache = shopify.NewMemoryCache()
+	api.CacheTTLs = shopify.DefaultCacheTTLs()
+
+	if count, err := api.LocationsCount(); err != nil || count != 1 {
+		t.Fatalf("Expected 1 location, got %d (%v)", count, err)
+	}
+	locations, err := api.Locations()
+	if err != nil || len(locations) != 1 {
+		t.Fatalf("Expected 1 location, got %d (%v)", len(locations), err)
+	}
+	if l := locations[0]; !l.Active || l.Legacy || l.LocalizedCountryName != "Canada" || l.LocalizedProvinceName != "Ontario" {
+		t.Errorf("Unexpected default location %#v", l)
+	}
+
+	service := api.NewFulfillmentService()
+	service.Name = "Acme 3PL"
+	service.InventoryManagement = true
+	if err = service.Save(); err == nil {
+		t.Errorf("Expected a callback URL to be required to manage inventory")
+	}
+	service.CallbackURL = "https://3pl.example.com/shopify"
+	if err = service.Save(); err != nil {
+		t.Fatalf("Error creating fulfillment service: %v", err)
+	}
+	if service.Id == 0 || service.Handle != "acme-3pl" || service.LocationID == 0 {
+		t.Errorf("Unexpected fulfillment service %#v", service)
+	}
+
+	// creating the service invalidates the cached locations
+	location, err := service.Location()
+	if err != nil || !location.Legacy || location.Name != "Acme 3PL" {
+		t.Fatalf("Expected a legacy location for the service, got %#v (%v)", location, err)
+	}
+	if locations, err = api.ActiveLocations(false); err != nil || len(locations) != 1 {
+		t.Errorf("Expected the service's location to be left out, got %d (%v)", len(locations), err)
+	}
+	if locations, err = api.ActiveLocations(true); err != nil || len(locations) != 2 {
+		t.Errorf("Expected the service's location, got %d (%v)", len(locations), err)
+	}
+
+	service.Name = "Acme Logistics"
+	service.TrackingSupport = true
+	if err = service.Save(); err != nil {
+		t.Fatalf("Error updating fulfillment service: %v", err)
+	}
+	services, err := api.FulfillmentServices(&shopify.FulfillmentServicesOptions{Scope: "all"})
+	if err != nil || len(services) != 1 || !services[0].TrackingSupport || services[0].Name != "Acme Logistics" {
+		t.Fatalf("Expected the updated service, got %#v (%v)", services, err)
+	}
+
+	if err = services[0].Delete(); err != nil {
+		t.Fatalf("Error deleting fulfillment service: %v", err)
+	}
+	if count, err := api.LocationsCount(); err != nil || count != 1 {
+		t.Errorf("Expected the service's location to be deleted, got %d (%v)", count, err)
+	}
+}
+
+func TestOwnerMetafields(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	customerID := srv.Seed("customers", Object{"email": "customer@example.com"})
+	collectionID := srv.Seed("custom_collections", Object{"title": "Sale"})
+	owners := []shopify.MetafieldOwner{
+		shopify.ShopMetafieldOwner,
+		{Resource: "customer", ID: customerID},
+		{Resource: "collection", ID: collectionID},
+		{Resource: "location", ID: DefaultLocationID},
+	}
+
+	for i, owner := range owners {
+		metafield := api.NewOwnerMetafield(owner)
+		metafield.Namespace = "custom"
+		metafield.Key = "rank"
+		if err := metafield.SetValue(shopify.MetafieldTypeNumberInteger, i); err != nil {
+			t.Fatalf("Error setting value: %v", err)
+		}
+		if err := metafield.Save(); err != nil {
+			t.Fatalf("%s: error creating metafield: %v", owner.Resource, err)
+		}
+		if metafield.Id == 0 || metafield.OwnerResource != owner.Resource {
+			t.Errorf("%s: unexpected metafield %#v", owner.Resource, metafield)
+		}
+	}
+
+	for i, owner := range owners {
+		metafields, err := api.OwnerMetafields(owner, &shopify.MetafieldsOptions{Namespace: "custom"})
+		if err != nil || len(metafields) != 1 {
+			t.Fatalf("%s: expected 1 metafield, got %d (%v)", owner.Resource, len(metafields), err)
+		}
+		var rank int
+		if err = metafields[0].DecodeValue(&rank); err != nil || rank != i {
+			t.Errorf("%s: expected rank %d, got %d (%v)", owner.Resource, i, rank, err)
+		}
+		if count, err := api.OwnerMetafieldsCount(owner, nil); err != nil || count != 1 {
+			t.Errorf("%s: expected a count of 1, got %d (%v)", owner.Resource, count, err)
+		}
+	}
+
+	// creating a metafield with an existing key updates it
+	again := api.NewOwnerMetafield(shopify.ShopMetafieldOwner)
+	again.Namespace, again.Key, again.Value = "custom", "rank", "5"
+	if err := again.Save(); err != nil || again.Value != "5" || again.OwnerId != 1 {
+		t.Fatalf("Error creating an existing metafield: %v, %#v", err, again)
+	}
+	if count, _ := api.OwnerMetafieldsCount(shopify.ShopMetafieldOwner, nil); count != 1 {
+		t.Errorf("Expected the existing metafield to be updated, got %d metafields", count)
+	}
+
+	// any metafield is updated and deleted by id
+	metafields, _ := api.OwnerMetafields(owners[1], nil)
+	metafield := metafields[0]
+	metafield.SetValue(shopify.MetafieldTypeNumberInteger, 10)
+	if err := metafield.Save(); err != nil || metafield.Value != "10" {
+		t.Fatalf("Error updating metafield: %v", err)
+	}
+	if err := metafield.Delete(); err != nil {
+		t.Fatalf("Error deleting metafield: %v", err)
+	}
+	if count, _ := api.OwnerMetafieldsCount(owners[1], nil); count != 0 {
+		t.Errorf("Expected the metafield to be deleted, got %d", count)
+	}
+
+	if _, err := api.OwnerMetafields(shopify.MetafieldOwner{Resource: "theme", ID: 1}, nil); err == nil {
+		t.Errorf("Expected an error for an unknown owner")
+	}
+	if err := api.NewOwnerMetafield(shopify.MetafieldOwner{Resource: "article", ID: 1}).Save(); err == nil {
+		t.Errorf("Expected an error for an article without its blog")
+	}
+}
+
+func TestCollects(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	// the collects of more than a page of products are created one by one
+	srv.LeakRate = 0
+	api := srv.API()
+
+	collectionID := srv.Seed("custom_collections", Object{"title": "Sale"})
+	collection, err := api.CustomCollection(collectionID)
+	if err != nil {
+		t.Fatalf("Error fetching collection: %v", err)
+	}
+	productIDs := []int64{}
+	for i := 0; i < 260; i++ {
+		productIDs = append(productIDs, srv.Seed("products", Object{"title": "Product", "vendor": "Acme"}))
+	}
+
+	collect := api.NewCollect()
+	collect.CollectionId, collect.ProductId = collectionID, productIDs[0]
+	if err := collect.Save(); err != nil || collect.Id == 0 {
+		t.Fatalf("Error creating collect: %v", err)
+	}
+	if err := collect.Save(); err == nil {
+		t.Errorf("Expected an error saving an existing collect")
+	}
+	again := api.NewCollect()
+	again.CollectionId, again.ProductId = collectionID, productIDs[0]
+	var validationErr *shopify.ValidationError
+	if err := again.Save(); !errors.As(err, &validationErr) {
+		t.Errorf("Expected a validation error adding a product twice, got %v", err)
+	}
+
+	// membership is synced by creating and deleting only the collects which differ
+	before := len(srv.Requests())
+	added, removed, err := collection.SetProducts(productIDs[1:])
+	if err != nil {
+		t.Fatalf("Error setting products: %v", err)
+	}
+	if len(added) != 259 || len(removed) != 1 || removed[0] != productIDs[0] {
+		t.Errorf("Expected 259 products added and the first removed, got %d and %v", len(added), removed)
+	}
+	posts := 0
+	for _, r := range srv.Requests()[before:] {
+		if r.Method == "POST" {
+			posts++
+		}
+	}
+	if posts != 259 {
+		t.Errorf("Expected 259 collects created, got %d", posts)
+	}
+	added, removed, err = collection.SetProducts(productIDs[1:])
+	if err != nil || len(added) != 0 || len(removed) != 0 {
+		t.Errorf("Expected no changes setting the same products, got %v, %v (%v)", added, removed, err)
+	}
+
+	collects, err := api.CollectsWithOptions(&shopify.CollectOptions{ProductID: productIDs[5]})
+	if err != nil || len(collects) != 1 || collects[0].CollectionId != collectionID {
+		t.Errorf("Expected the collect of product %d, got %#v (%v)", productIDs[5], collects, err)
+	}
+	if count, err := api.CollectsCount(&shopify.CollectOptions{CollectionID: collectionID}); err != nil || count != 259 {
+		t.Errorf("Expected 259 collects, got %d (%v)", count, err)
+	}
+
+	// products are listed over several pages, in the order they were added
+	products, err := collection.Products()
+	if err != nil || len(products) != 259 {
+		t.Fatalf("Expected 259 products, got %d (%v)", len(products), err)
+	}
+	for i, product := range products {
+		if product.ID != productIDs[i+1] {
+			t.Fatalf("Expected product %d at %d, got %d", productIDs[i+1], i, product.ID)
+		}
+	}
+
+	collects, _ = api.CollectsWithOptions(&shopify.CollectOptions{ProductID: productIDs[1]})
+	if err := collects[0].Delete(); err != nil {
+		t.Fatalf("Error deleting collect: %v", err)
+	}
+	if count, _ := api.CollectsCount(&shopify.CollectOptions{CollectionID: collectionID}); count != 258 {
+		t.Errorf("Expected 258 collects after deleting one, got %d", count)
+	}
+}
+
+func TestSmartCollectionProducts(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	acme1 := srv.Seed("products", Object{"title": "Anvil", "vendor": "Acme"})
+	srv.Seed("products", Object{"title": "Hat", "vendor": "Other"})
+	acme2 := srv.Seed("products", Object{"title": "Rocket", "vendor": "ACME"})
+	acme3 := srv.Seed("products", Object{"title": "Magnet", "vendor": "Acme"})
+	collectionID := srv.Seed("smart_collections", Object{
+		"title":      "Acme",
+		"sort_order": "best-selling",
+		"rules":      []interface{}{Object{"column": "vendor", "relation": "equals", "condition": "acme"}},
+	})
+	collection, err := api.SmartCollection(collectionID)
+	if err != nil {
+		t.Fatalf("Error fetching collection: %v", err)
+	}
+
+	ids := func() []int64 {
+		products, err := collection.Products()
+		if err != nil {
+			t.Fatalf("Error listing products: %v", err)
+		}
+		result := []int64{}
+		for _, product := range products {
+			result = append(result, product.ID)
+		}
+		return result
+	}
+	if got := ids(); !reflect.DeepEqual(got, []int64{acme1, acme2, acme3}) {
+		t.Errorf("Expected the matching products, got %v", got)
+	}
+
+	if err := collection.Order([]int64{acme3, acme2}); err != nil {
+		t.Fatalf("Error ordering collection: %v", err)
+	}
+	if collection.SortOrder != "manual" || srv.Object("smart_collections", collectionID)["sort_order"] != "manual" {
+		t.Errorf("Expected the collection to be sorted manually, got %q", collection.SortOrder)
+	}
+	if got := ids(); !reflect.DeepEqual(got, []int64{acme3, acme2, acme1}) {
+		t.Errorf("Expected the ordered products first, got %v", got)
+	}
+
+	if err := api.NewSmartCollection().Order(nil); !errors.Is(err, shopify.ErrNotFound) {
+		t.Errorf("Expected not found ordering a collection which doesn't exist, got %v", err)
+	}
+}
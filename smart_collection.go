@@ -1,12 +1,9 @@
 package shopify
 
 import (
-	"bytes"
-
-	"encoding/json"
-
 	"fmt"
-
+	"net/url"
+	"strconv"
 	"time"
 )
 
@@ -46,63 +43,20 @@ type CollectionOptions struct {
 	ProductID string `url:"product_id,omitempty"`
 }
 
+var smartCollectionResource = newRESTResource[SmartCollection]("/admin/smart_collections", "smart_collection", "smart_collections")
+
+func (obj *SmartCollection) setAPI(api *API) { obj.api = api }
+
 func (api *API) SmartCollections() ([]SmartCollection, error) {
 	return api.SmartCollectionsWithOptions(&CollectionOptions{})
 }
 
 func (api *API) SmartCollectionsWithOptions(options *CollectionOptions) ([]SmartCollection, error) {
-	qs := encodeOptions(options)
-	endpoint := fmt.Sprintf("/admin/smart_collections.json?%v", qs)
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := &map[string][]SmartCollection{}
-	err = json.NewDecoder(res).Decode(r)
-
-	result := (*r)["smart_collections"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	for _, v := range result {
-		v.api = api
-	}
-
-	return result, nil
+	return smartCollectionResource.listValues(api, options)
 }
 
 func (api *API) SmartCollection(id int64) (*SmartCollection, error) {
-	endpoint := fmt.Sprintf("/admin/smart_collections/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]SmartCollection{}
-	err = json.NewDecoder(res).Decode(&r)
-	result := r["smart_collection"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+	return smartCollectionResource.get(api, id, nil)
 }
 
 func (api *API) NewSmartCollection() *SmartCollection {
@@ -110,44 +64,91 @@ func (api *API) NewSmartCollection() *SmartCollection {
 }
 
 func (obj *SmartCollection) Save() error {
-	endpoint := fmt.Sprintf("/admin/smart_collections/%d.json", obj.ID)
-	method := "PUT"
-	expectedStatus := 201
-
-	if obj.ID == 0 {
-		endpoint = fmt.Sprintf("/admin/smart_collections.json")
-		method = "POST"
-		expectedStatus = 201
-	}
-
-	body := map[string]*SmartCollection{}
-	body["smart_collection"] = obj
+	return smartCollectionResource.save(obj.api, obj.ID, obj, nil)
+}
 
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
+// Validate checks the rules of the collection as Shopify does when it's
+// saved, returning a *ValidationError listing the invalid ones.
+func (obj *SmartCollection) Validate() error {
+	return validateRules(obj.Rules)
+}
 
-	if err != nil {
-		return err
+// Matches returns true if product would be in the collection: if it
+// satisfies all its rules, or any of them if it's disjunctive. See
+// Rule.Matches.
+func (obj *SmartCollection) Matches(product *Product) (bool, error) {
+	if err := obj.Validate(); err != nil {
+		return false, err
 	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
+	for _, rule := range obj.Rules {
+		match, err := rule.Matches(product)
+		if err != nil {
+			return false, err
+		}
+		if match == obj.Disjunctive {
+			return match, nil
+		}
 	}
+	return !obj.Disjunctive, nil
+}
 
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
+// Preview returns the products which would be in the collection, in their
+// order, to check its rules before saving it.
+func (obj *SmartCollection) Preview(products []*Product) ([]*Product, error) {
+	result := []*Product{}
+	for _, product := range products {
+		match, err := obj.Matches(product)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			result = append(result, product)
+		}
 	}
+	return result, nil
+}
 
-	r := map[string]SmartCollection{}
-	err = json.NewDecoder(res).Decode(&r)
+// Products returns the products of the collection, in its sort order.
+func (obj *SmartCollection) Products() ([]*Product, error) {
+	return obj.api.collectionProducts(obj.ID)
+}
 
-	if err != nil {
+// Order sorts the collection manually, with the products of productIDs
+// first, in that order.
+func (obj *SmartCollection) Order(productIDs []int64) error {
+	query := url.Values{"sort_order": {"manual"}}
+	for _, id := range productIDs {
+		query.Add("products[]", strconv.FormatInt(id, 10))
+	}
+	endpoint := fmt.Sprintf("/admin/smart_collections/%d/order.json?%s", obj.ID, query.Encode())
+	if err := smartCollectionResource.do(obj.api, endpoint, "PUT", nil, 200, "", nil); err != nil {
 		return err
 	}
+	obj.SortOrder = "manual"
+	return nil
+}
 
-	*obj = r["smart_collection"]
+// collectionProductsOptions selects a page of the products of a collection.
+type collectionProductsOptions struct {
+	Limit    int    `url:"limit,omitempty"`
+	PageInfo string `url:"page_info,omitempty"`
+}
 
-	return nil
+// collectionProducts returns the products of the custom or smart collection
+// with id, following the pages of results.
+func (api *API) collectionProducts(id int64) ([]*Product, error) {
+	products := productResource.nested(fmt.Sprintf("/admin/collections/%d", id))
+	options := &collectionProductsOptions{Limit: 250}
+	result := []*Product{}
+	for {
+		page, next, err := products.listPage(api, options)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, page...)
+		if next == "" {
+			return result, nil
+		}
+		options.PageInfo = next
+	}
 }
@@ -0,0 +1,12 @@
+// Code generated by shopify-gen from schema/resources.json. DO NOT EDIT.
+
+package shopify
+
+// TaxLine is a tax applied to an order, line item or shipping line.
+type TaxLine struct {
+	ChannelLiable bool      `json:"channel_liable"`
+	Price         string    `json:"price"`
+	PriceSet      *PriceSet `json:"price_set,omitempty"`
+	Rate          float64   `json:"rate"`
+	Title         string    `json:"title"`
+}
@@ -1,10 +1,5 @@
 package shopify
 
-import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-)
 
 //Variant struct to present Shopify's variant
 type Variant struct {
@@ -38,6 +33,10 @@ type Variant struct {
 	api *API
 }
 
+var variantResource = newRESTResource[Variant]("/admin/variants", "variant", "variants")
+
+func (obj *Variant) setAPI(api *API) { obj.api = api }
+
 // NewVariant function to construct a new variant
 func (api *API) NewVariant() *Variant {
 	return &Variant{api: api}
@@ -45,63 +44,10 @@ func (api *API) NewVariant() *Variant {
 
 // GET get one variant based on variant id
 func (api *API) Get(id int64) (*Variant, error) {
-	endpoint := fmt.Sprintf("/admin/variants/%d.json", id)
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := &struct {
-		Variant Variant `json:"variant"`
-	}{}
-	err = json.NewDecoder(res).Decode(r)
-	if err != nil {
-		return nil, err
-	}
-	result := r.Variant
-	result.api = api
-	return &result, nil
+	return variantResource.get(api, id, nil)
 }
 
 // Save changes to variants
 func (obj *Variant) Save() error {
-	endpoint := fmt.Sprintf("/admin/variants/%d.json", obj.ID)
-	expectedStatus := 200
-
-	var buf bytes.Buffer
-	body := map[string]*Variant{
-		"variant": obj,
-	}
-	err := json.NewEncoder(&buf).Encode(body)
-	if err != nil {
-		return err
-	}
-	reqBody := buf.Bytes()
-
-	res, status, err := obj.api.request(endpoint, "PUT", nil, &buf)
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, reqBody, res)
-	}
-
-	r := &struct {
-		Variant Variant `json:"variant"`
-	}{}
-	err = json.NewDecoder(res).Decode(r)
-	if err != nil {
-		return err
-	}
-
-	api := obj.api
-	*obj = r.Variant
-	obj.api = api
-
-	return nil
+	return variantResource.update(obj.api, obj.ID, obj, nil)
 }
@@ -1,9 +1,6 @@
 package shopify
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
 	"time"
 )
 
@@ -19,58 +16,16 @@ type Webhook struct {
 	api                 *API
 }
 
-func (api *API) Webhooks() ([]*Webhook, error) {
-	res, status, err := api.request("/admin/webhooks.json", "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := &map[string][]*Webhook{}
-	err = json.NewDecoder(res).Decode(r)
+var webhookResource = newRESTResource[Webhook]("/admin/webhooks", "webhook", "webhooks")
 
-	result := (*r)["webhooks"]
+func (obj *Webhook) setAPI(api *API) { obj.api = api }
 
-	if err != nil {
-		return nil, err
-	}
-
-	for _, v := range result {
-		v.api = api
-	}
-
-	return result, nil
+func (api *API) Webhooks() ([]*Webhook, error) {
+	return webhookResource.list(api, nil)
 }
 
 func (api *API) Webhook(id int64) (*Webhook, error) {
-	endpoint := fmt.Sprintf("/admin/webhooks/%d.json", id)
-
-	res, status, err := api.request(endpoint, "GET", nil, nil)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if status != 200 {
-		return nil, fmt.Errorf("Status returned: %d", status)
-	}
-
-	r := map[string]Webhook{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	result := r["webhook"]
-
-	if err != nil {
-		return nil, err
-	}
-
-	result.api = api
-
-	return &result, nil
+	return webhookResource.get(api, id, nil)
 }
 
 func (api *API) NewWebhook() *Webhook {
@@ -78,68 +33,13 @@ func (api *API) NewWebhook() *Webhook {
 }
 
 func (obj *Webhook) Save(partial *Webhook) error {
-	endpoint := fmt.Sprintf("/admin/webhooks/%d.json", obj.Id)
-	method := "PUT"
-	expectedStatus := 200
-
-	if obj.Id == 0 {
-		endpoint = fmt.Sprintf("/admin/webhooks.json")
-		method = "POST"
-		expectedStatus = 201
-	}
-
-	body := map[string]*Webhook{}
-	if partial == nil {
-		body["webhook"] = obj
-	} else {
-		body["webhook"] = partial
-	}
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(body)
-
-	if err != nil {
-		return err
-	}
-
-	res, status, err := obj.api.request(endpoint, method, nil, buf)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
+	var body interface{}
+	if partial != nil {
+		body = partial
 	}
-
-	r := map[string]Webhook{}
-	err = json.NewDecoder(res).Decode(&r)
-
-	if err != nil {
-		return err
-	}
-
-	api := obj.api
-	*obj = r["webhook"]
-	obj.api = api
-
-	return nil
+	return webhookResource.save(obj.api, obj.Id, obj, body)
 }
 
 func (obj *Webhook) Delete() error {
-	endpoint := fmt.Sprintf("/admin/webhooks/%d.json", obj.Id)
-	method := "DELETE"
-	expectedStatus := 200
-
-	res, status, err := obj.api.request(endpoint, method, nil, nil)
-
-	if err != nil {
-		return err
-	}
-
-	if status != expectedStatus {
-		return newErrorResponse(status, nil, res)
-	}
-
-	return nil
+	return webhookResource.delete(obj.api, obj.Id)
 }